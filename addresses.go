@@ -0,0 +1,118 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arachnid/dnsprove/ens"
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/registrar"
+	"github.com/arachnid/dnsprove/root"
+	"github.com/ethereum/go-ethereum/common"
+	log "github.com/inconshreveable/log15"
+)
+
+// resolveAddress returns the address given by arg, which is either hex or an
+// ENS name resolved through the registry given by -ens.
+func resolveAddress(conn *chainClient, arg string) (common.Address, error) {
+	if common.IsHexAddress(arg) {
+		return common.HexToAddress(arg), nil
+	}
+	if !common.IsHexAddress(*ensAddress) {
+		return common.Address{}, fmt.Errorf("-ens must be a hex address, not %q", *ensAddress)
+	}
+
+	registry, err := ens.New(common.HexToAddress(*ensAddress), conn)
+	if err != nil {
+		return common.Address{}, err
+	}
+	resolver, err := registry.Resolver(arg)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("Could not resolve %q: %v", arg, err)
+	}
+	addr, err := resolver.Addr()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("Could not resolve %q: %v", arg, err)
+	}
+	if addr == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("Name %q has no address", arg)
+	}
+	log.Debug("Resolved address", "name", arg, "address", addr.String())
+	return addr, nil
+}
+
+// openRegistry returns the ENS registry given by arg, an address or ENS name,
+// or by -ens if arg is empty.
+func openRegistry(conn *chainClient, arg string) (*ens.ENS, error) {
+	if arg == "" {
+		arg = *ensAddress
+	}
+	addr, err := resolveAddress(conn, arg)
+	if err != nil {
+		return nil, err
+	}
+	return ens.New(addr, conn)
+}
+
+// openOracle returns the oracle given by arg, an address or ENS name. If arg is
+// empty, the oracle is discovered from the registrar that owns name's TLD in
+// the registry given by -ens, falling back to the root contract from the
+// profile or, failing that, the owner of the root.
+func openOracle(conn *chainClient, arg, name string) (*oracle.Oracle, error) {
+	if arg != "" {
+		addr, err := resolveAddress(conn, arg)
+		if err != nil {
+			return nil, err
+		}
+		return oracle.New(addr, conn)
+	}
+
+	registry, err := openRegistry(conn, *ensAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	if tld := labels[len(labels)-1]; tld != "" {
+		candidates = append(candidates, tld)
+	}
+	candidates = append(candidates, "")
+
+	for _, candidate := range candidates {
+		owner, err := registry.Owner(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == "" && rootAddress != "" {
+			// The profile names the root contract to use.
+			if owner, err = resolveAddress(conn, rootAddress); err != nil {
+				return nil, err
+			}
+		}
+		if owner == (common.Address{}) {
+			continue
+		}
+
+		var o *oracle.Oracle
+		if reg, err := registrar.New(owner, conn); err == nil {
+			o, err = reg.GetOracle()
+			if err != nil {
+				return nil, err
+			}
+		} else if r, err := root.New(owner, conn); err == nil {
+			o, err = r.GetOracle()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			continue
+		}
+		log.Info("Discovered oracle", "name", candidate+".", "owner", owner.String())
+		return o, nil
+	}
+	return nil, fmt.Errorf("No oracle address given, and none could be found through the ENS registry")
+}
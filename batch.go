@@ -0,0 +1,437 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/ethereum/go-ethereum/common"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// batchEntry tracks a single name being proven as part of a batch run.
+type batchEntry struct {
+	qtype  uint16
+	name   string
+	paths  [][]proofs.SignedSet
+	sets   []proofs.SignedSet
+	found  bool
+	err    error
+	code   int
+	status string
+	keys   []setKey
+	txs    []common.Hash
+}
+
+// setKey identifies an RRSet independently of the chain it was fetched for.
+type setKey struct {
+	name   string
+	rrtype uint16
+}
+
+func keyFor(set proofs.SignedSet) setKey {
+	header := set.Rrs[0].Header()
+	return setKey{strings.ToLower(header.Name), header.Rrtype}
+}
+
+// batchNode is a unique RRSet shared between one or more proof chains.
+type batchNode struct {
+	set    proofs.SignedSet
+	parent *batchNode
+	needed bool
+	failed bool
+	tx     common.Hash
+}
+
+// batchPlan is the set of transactions needed to prove a batch of names.
+type batchPlan struct {
+	nodes map[setKey]*batchNode
+	runs  []*batchRun
+}
+
+// batchRun is a sequence of nodes that can be submitted in one transaction.
+type batchRun struct {
+	proof *batchNode
+	nodes []*batchNode
+}
+
+// readBatch parses a list of "qtype qname" pairs, one per line. Blank lines
+// and lines starting with '#' are ignored.
+func readBatch(r io.Reader) ([]*batchEntry, error) {
+	var entries []*batchEntry
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"qtype qname\", got %q", line, text)
+		}
+		qtype, ok := dns.StringToType[strings.ToUpper(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unrecognised query type %q", line, fields[0])
+		}
+		entries = append(entries, &batchEntry{qtype: qtype, name: dns.Fqdn(fields[1])})
+	}
+	return entries, scanner.Err()
+}
+
+func openBatch(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(path)
+}
+
+// planBatch de-duplicates the proof chains of all entries, works out which
+// sets need submitting, and splits them into runs that each fit within
+// gasLimit.
+func planBatch(o *oracle.Oracle, entries []*batchEntry, gasLimit uint64) (*batchPlan, error) {
+	nodes := make(map[setKey]*batchNode)
+	planner := o.NewPlanner()
+	var order []*batchNode
+
+	for _, entry := range entries {
+		if entry.err != nil {
+			continue
+		}
+
+		if !entry.found {
+			// We're deleting a record. If it's not already there, there's nothing to do.
+			_, _, hash, err := o.Rrdata(nil, entry.qtype, entry.name)
+			if err != nil {
+				entry.err, entry.code = err, ExitChain
+				continue
+			}
+			if hash == [20]byte{} {
+				entry.status = "absent from oracle"
+				continue
+			}
+		}
+
+		plan, err := planner.Plan(entry.sets)
+		if err != nil {
+			entry.err, entry.code = err, ExitChain
+			continue
+		}
+		plan.Log()
+
+		chain := entry.sets
+		if !entry.found {
+			// The NSEC record itself is sent separately to delete the record.
+			chain = chain[:len(chain)-1]
+		}
+
+		var parent *batchNode
+		for i, set := range chain {
+			key := keyFor(set)
+			entry.keys = append(entry.keys, key)
+
+			node, ok := nodes[key]
+			if !ok {
+				node = &batchNode{set: set, parent: parent}
+				nodes[key] = node
+				order = append(order, node)
+			}
+			if plan.Steps[i].Submit {
+				node.needed = true
+			}
+			parent = node
+		}
+
+		if entry.found && plan.Submissions() == 0 {
+			entry.status = "up to date"
+		} else {
+			entry.status = "pending"
+		}
+	}
+
+	var runs []*batchRun
+	var current *batchRun
+	var currentData []byte
+	for _, node := range order {
+		if !node.needed {
+			continue
+		}
+
+		data, err := oracle.SerializeSets([]proofs.SignedSet{node.set})
+		if err != nil {
+			return nil, err
+		}
+
+		extends := current != nil && current.nodes[len(current.nodes)-1] == node.parent
+		if extends && oracle.EstimateSubmitGas(append(currentData, data...), len(current.nodes)+1) > gasLimit {
+			// Continue the chain in a new transaction, proven by the last set sent.
+			current = &batchRun{proof: node.parent}
+			currentData = nil
+			runs = append(runs, current)
+		} else if !extends {
+			current = &batchRun{proof: node.parent}
+			currentData = nil
+			runs = append(runs, current)
+		}
+		current.nodes = append(current.nodes, node)
+		currentData = append(currentData, data...)
+	}
+
+	return &batchPlan{nodes, runs}, nil
+}
+
+func proveBatch(path string) {
+	r, err := openBatch(path)
+	if err != nil {
+		report.fail(ExitError, "Could not open batch file", "path", path, "err", err)
+	}
+	entries, err := readBatch(r)
+	r.Close()
+	if err != nil {
+		report.fail(ExitError, "Could not read batch file", "path", path, "err", err)
+	}
+	if len(entries) == 0 {
+		report.fail(ExitError, "Batch file lists no names", "path", path)
+	}
+
+	for _, entry := range entries {
+		entry.paths, entry.found, entry.err = getProofPaths(entry.qtype, entry.name)
+		if entry.err != nil {
+			entry.code = proofExitCode(entry.err)
+			log.Error("Error resolving", "qtype", dns.TypeToString[entry.qtype], "name", entry.name, "err", entry.err)
+			continue
+		}
+		entry.sets = entry.paths[0]
+	}
+
+	if *print {
+		printed := make(map[setKey]bool)
+		for _, entry := range entries {
+			for _, proof := range entry.sets {
+				if key := keyFor(proof); !printed[key] && !report.JSON() {
+					printed[key] = true
+					printProof(proof)
+				}
+			}
+		}
+		finishBatch(entries, ExitOK, "printed", "")
+	}
+
+	conn, err := dial()
+	if err != nil {
+		report.fail(ExitChain, "Error connecting to Ethereum node", "err", err)
+	}
+
+	o, err := openOracle(conn, *oracleAddress, entries[0].name)
+	if err != nil {
+		report.fail(ExitChain, "Error creating oracle", "err", err)
+	}
+
+	for _, entry := range entries {
+		if entry.err != nil {
+			continue
+		}
+		if entry.sets, entry.err = bestPath(o, entry.paths); entry.err != nil {
+			entry.code = ExitValidation
+			log.Error("Error choosing proof path", "qtype", dns.TypeToString[entry.qtype], "name", entry.name, "err", entry.err)
+		}
+	}
+
+	if o.Stateless() {
+		for _, entry := range entries {
+			switch {
+			case entry.err != nil:
+			case !entry.found:
+				entry.status = "absent from oracle"
+			default:
+				if _, _, err := o.Verify(entry.sets); err != nil {
+					entry.err, entry.code = err, ExitValidation
+				} else {
+					entry.status = "verified"
+				}
+			}
+		}
+		finishBatch(entries, ExitNothingToDo, "verified", "")
+	}
+
+	plan, err := planBatch(o, entries, *gasLimit)
+	if err != nil {
+		report.fail(ExitChain, "Error planning batch", "err", err)
+	}
+
+	deletions := 0
+	for _, entry := range entries {
+		if !entry.found && entry.status == "pending" {
+			deletions++
+		}
+	}
+
+	if len(plan.runs) == 0 && deletions == 0 {
+		report.decide("All records already match the oracle")
+		finishBatch(entries, ExitNothingToDo, "nothing-to-do", "Nothing to do; exiting.")
+	}
+	report.decide(fmt.Sprintf("Sending %d batch transactions and %d deletions", len(plan.runs), deletions))
+
+	if !*yes {
+		proofCount := 0
+		for _, run := range plan.runs {
+			proofCount += len(run.nodes)
+		}
+		if !confirm("Send %d transactions to prove %d names (%d proofs, %d deletions) onchain?", len(plan.runs)+deletions, len(entries), proofCount, deletions) {
+			finishBatch(entries, ExitAborted, "aborted", "Exiting at user request.")
+		}
+	}
+
+	auth, err := makeTransactor(conn)
+	if err != nil {
+		report.fail(ExitError, "Could not create transactor", "err", err)
+	}
+
+	for _, run := range plan.runs {
+		if run.proof != nil && run.proof.failed {
+			for _, node := range run.nodes {
+				node.failed = true
+			}
+			continue
+		}
+
+		b := oracle.Batch{}
+		if run.proof != nil {
+			b.Proof = &run.proof.set
+		}
+		for _, node := range run.nodes {
+			b.Sets = append(b.Sets, node.set)
+		}
+
+		tx, err := o.SendBatch(auth, b)
+		if err != nil {
+			log.Error("Error sending proofs", "count", len(b.Sets), "err", err)
+			for _, node := range run.nodes {
+				node.failed = true
+			}
+			continue
+		}
+		auth.Nonce = auth.Nonce.Add(auth.Nonce, big.NewInt(1))
+		for _, node := range run.nodes {
+			node.tx = tx.Hash()
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.err != nil || entry.status != "pending" {
+			continue
+		}
+
+		failed := false
+		for _, key := range entry.keys {
+			node := plan.nodes[key]
+			if node.failed {
+				failed = true
+			}
+			if node.tx != (common.Hash{}) && !containsHash(entry.txs, node.tx) {
+				entry.txs = append(entry.txs, node.tx)
+			}
+		}
+		if failed {
+			entry.err, entry.code = fmt.Errorf("a proof this record depends on could not be submitted"), ExitChain
+			continue
+		}
+
+		if entry.found {
+			entry.status = "submitted"
+			continue
+		}
+
+		proof, err := entry.sets[len(entry.sets)-2].PackRRSet()
+		if err != nil {
+			entry.err, entry.code = err, ExitError
+			continue
+		}
+		tx, err := o.DeleteRRSet(auth, entry.qtype, entry.name, entry.sets[len(entry.sets)-1], proof)
+		if err != nil {
+			entry.err, entry.code = err, ExitChain
+			continue
+		}
+		entry.txs = append(entry.txs, tx.Hash())
+		entry.status = "deleted"
+	}
+
+	for _, entry := range entries {
+		report.addTransactionHashes(entry.txs...)
+	}
+	finishBatch(entries, ExitOK, "sent", "")
+}
+
+func containsHash(hashes []common.Hash, h common.Hash) bool {
+	for _, hash := range hashes {
+		if hash == h {
+			return true
+		}
+	}
+	return false
+}
+
+// batchResult is the JSON form of a batch entry's outcome.
+type batchResult struct {
+	Type         string        `json:"type"`
+	Name         string        `json:"name"`
+	Result       string        `json:"result"`
+	Error        *resultError  `json:"error,omitempty"`
+	Transactions []common.Hash `json:"transactions,omitempty"`
+}
+
+// finishBatch reports the outcome of every entry and ends the command. If any
+// entry failed, the exit code is that of the first failure.
+func finishBatch(entries []*batchEntry, code int, outcome, message string) {
+	var results []batchResult
+	for _, entry := range entries {
+		result := batchResult{Type: dns.TypeToString[entry.qtype], Name: entry.name, Result: entry.status, Transactions: entry.txs}
+		if entry.err != nil {
+			result.Result = "error"
+			result.Error = &resultError{Code: exitCodeNames[entry.code], Exit: entry.code, Message: entry.err.Error()}
+			if code == ExitOK || code == ExitNothingToDo {
+				code, outcome = entry.code, "partial"
+			}
+		} else if result.Result == "" {
+			result.Result = "resolved"
+		}
+		results = append(results, result)
+	}
+
+	if report.JSON() {
+		report.result.Status = results
+	} else {
+		printBatchResults(entries)
+	}
+	report.finish(code, outcome, message)
+}
+
+func printBatchResults(entries []*batchEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "TYPE\tNAME\tRESULT\tTRANSACTIONS\n")
+	for _, entry := range entries {
+		result := entry.status
+		if entry.err != nil {
+			result = "error: " + entry.err.Error()
+		} else if result == "" {
+			result = "resolved"
+		}
+		txids := make([]string, 0, len(entry.txs))
+		for _, tx := range entry.txs {
+			txids = append(txids, tx.String())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", dns.TypeToString[entry.qtype], entry.name, result, strings.Join(txids, ","))
+	}
+	w.Flush()
+}
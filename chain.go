@@ -0,0 +1,139 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"strings"
+	"sync"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// chainOption is the EDNS0 option code of CHAIN (RFC 7901), which asks a
+// server to include in its response every record needed to validate the
+// answer from a given trust point down.
+const chainOption = 13
+
+// prefetchWorkers limits how many prefetch queries are in flight at once.
+const prefetchWorkers = 8
+
+// chainRequest returns a CHAIN option naming the root as the closest trust
+// point, since it is the only one the client has.
+func chainRequest() dns.EDNS0 {
+	return &dns.EDNS0_LOCAL{Code: chainOption, Data: []byte{0}}
+}
+
+// prefetch sends the query for qtype name, and then queries in parallel for
+// the DNSKEY and DS records of name and each of its ancestors that the server
+// didn't include in its response. Validation needs them one at a time, but
+// can't know which until it has the one before; with them fetched up front, a
+// proof takes one or two round trips rather than one per record. Queries that
+// fail are left to be retried when they are needed.
+func (client *Client) prefetch(qtype uint16, name string) {
+	name = strings.ToLower(name)
+	target := queryKey{name, qtype}
+	if r, from, err := client.exchange(qtype, dns.ClassINET, name); err == nil {
+		client.mu.Lock()
+		client.responses[target] = response{r, from}
+		client.mu.Unlock()
+	}
+
+	// A server supporting CHAIN includes the records of the zones above the
+	// answer, but even one that doesn't includes the answer's own, so each
+	// record is checked for rather than the chain as a whole.
+	var queries []queryKey
+	client.mu.Lock()
+	for _, query := range proofQueries(name) {
+		if _, ok := client.chain[query]; !ok && query != target {
+			queries = append(queries, query)
+		}
+	}
+	client.mu.Unlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, prefetchWorkers)
+	for _, query := range queries {
+		wg.Add(1)
+		go func(query queryKey) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			r, from, err := client.exchange(query.qtype, dns.ClassINET, query.name)
+			if err != nil {
+				log.Debug("Prefetch failed", "type", dns.TypeToString[query.qtype], "name", query.name, "err", err)
+				return
+			}
+			client.mu.Lock()
+			client.responses[query] = response{r, from}
+			client.mu.Unlock()
+		}(query)
+	}
+	wg.Wait()
+}
+
+// proofQueries returns the DNSKEY and DS queries that proving a record at name
+// may need, from the root down.
+func proofQueries(name string) []queryKey {
+	queries := []queryKey{{".", dns.TypeDNSKEY}}
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - 1; i >= 0; i-- {
+		zone := dns.Fqdn(strings.Join(labels[i:], "."))
+		queries = append(queries, queryKey{zone, dns.TypeDS}, queryKey{zone, dns.TypeDNSKEY})
+	}
+	return queries
+}
+
+// prefetched returns the response prefetch got for a query, if any.
+func (client *Client) prefetched(qtype uint16, name string) response {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.responses[queryKey{strings.ToLower(dns.Fqdn(name)), qtype}]
+}
+
+// harvest keeps the signed DNSKEY and DS RRSets found anywhere in r, such as
+// those a server supporting CHAIN adds, so they need not be queried for.
+func (client *Client) harvest(r *dns.Msg) {
+	sets := make(map[queryKey]*dns.Msg)
+	for _, section := range [][]dns.RR{r.Answer, r.Ns, r.Extra} {
+		for _, rr := range section {
+			key := queryKey{strings.ToLower(rr.Header().Name), rr.Header().Rrtype}
+			if sig, ok := rr.(*dns.RRSIG); ok {
+				key.qtype = sig.TypeCovered
+			}
+			if key.qtype != dns.TypeDNSKEY && key.qtype != dns.TypeDS {
+				continue
+			}
+			if sets[key] == nil {
+				sets[key] = new(dns.Msg)
+				sets[key].SetQuestion(key.name, key.qtype)
+			}
+			sets[key].Answer = append(sets[key].Answer, rr)
+		}
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for key, msg := range sets {
+		if len(findSignatures(msg.Answer, msg.Answer[0].Header().Name)) == 0 || len(getRRset(msg.Answer, key.name, key.qtype)) == 0 {
+			continue
+		}
+		if _, ok := client.chain[key]; !ok {
+			client.chain[key] = msg
+		}
+	}
+}
+
+// harvested returns a response built from records harvest kept for a query,
+// if any. Unlike a real response, the RRSet may be incomplete, so it must be
+// queried for if it doesn't validate.
+func (client *Client) harvested(qtype uint16, name string) *dns.Msg {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	key := queryKey{strings.ToLower(dns.Fqdn(name)), qtype}
+	msg := client.chain[key]
+	delete(client.chain, key)
+	return msg
+}
@@ -0,0 +1,36 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestPrefetch(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		qtype uint16
+		qname string
+	}{
+		{"TXT record", dns.TypeTXT, "_ens.example.com."},
+		// The answer includes the DNSKEY set it asks for, which mustn't be
+		// mistaken for a CHAIN response covering the zones above it.
+		{"DNSKEY record", dns.TypeDNSKEY, "example.com."},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			serve(t, newExampleServer(t))
+			client := NewClient(resolvers, trustAnchors, map[uint8]struct{}{dns.RSASHA256: {}}, map[uint8]struct{}{dns.SHA256: {}})
+			client.prefetch(tc.qtype, tc.qname)
+
+			for _, q := range []queryKey{{".", dns.TypeDNSKEY}, {"com.", dns.TypeDS}, {"com.", dns.TypeDNSKEY}, {"example.com.", dns.TypeDS}, {"example.com.", dns.TypeDNSKEY}} {
+				_, harvested := client.chain[q]
+				if client.responses[q].msg == nil && !harvested {
+					t.Errorf("%s %s was not prefetched", dns.TypeToString[q.qtype], q.name)
+				}
+			}
+		})
+	}
+}
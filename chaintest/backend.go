@@ -0,0 +1,507 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package chaintest simulates the DNSSEC oracle, DNS registrar, root and ENS
+// registry contracts in Go, behind a bind.ContractBackend, so the packages
+// that drive them can be exercised without a node or compiled contracts.
+package chaintest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrReverted is returned for calls that a contract rejects.
+	ErrReverted = errors.New("execution reverted")
+	// ErrNonceTooLow is returned for transactions whose nonce has been used.
+	ErrNonceTooLow = errors.New("nonce too low")
+	// ErrNonceGap is returned for transactions whose nonce skips one. A real
+	// node would queue them forever.
+	ErrNonceGap = errors.New("nonce too high; an earlier nonce was never sent")
+	// ErrNoCode is returned for calls to addresses with no contract.
+	ErrNoCode = errors.New("no contract at address")
+)
+
+// revert returns an error describing why a contract rejected a call.
+func revert(format string, args ...interface{}) error {
+	return fmt.Errorf("%v: %s", ErrReverted, fmt.Sprintf(format, args...))
+}
+
+// contract is a simulated contract. Calls are made on a copy of every
+// contract, which replaces the original only if the call succeeds.
+type contract interface {
+	definition() *abi.ABI
+	call(ctx *callContext, method string, args []interface{}) ([]interface{}, error)
+	clone() contract
+}
+
+// callContext is the environment of a call.
+type callContext struct {
+	world  map[common.Address]contract
+	self   common.Address
+	sender common.Address
+	time   uint64
+	logs   []*types.Log
+}
+
+// at returns the contract at addr, as seen by this call.
+func (ctx *callContext) at(addr common.Address) contract {
+	return ctx.world[addr]
+}
+
+// emit records an event log from the calling contract.
+func (ctx *callContext) emit(event string, args ...interface{}) error {
+	c := ctx.world[ctx.self]
+	ev, ok := c.definition().Events[event]
+	if !ok {
+		return fmt.Errorf("no event %s", event)
+	}
+	topics := []common.Hash{ev.ID()}
+	var data []interface{}
+	var nonIndexed abi.Arguments
+	for i, input := range ev.Inputs {
+		if input.Indexed {
+			switch v := args[i].(type) {
+			case [32]byte:
+				topics = append(topics, v)
+			case common.Address:
+				topics = append(topics, common.BytesToHash(v.Bytes()))
+			default:
+				return fmt.Errorf("unsupported indexed argument %T", v)
+			}
+			continue
+		}
+		nonIndexed = append(nonIndexed, input)
+		data = append(data, args[i])
+	}
+	packed, err := nonIndexed.Pack(data...)
+	if err != nil {
+		return err
+	}
+	ctx.logs = append(ctx.logs, &types.Log{Address: ctx.self, Topics: topics, Data: packed})
+	return nil
+}
+
+// minedBlock is a block's header and the state of the world after it.
+type minedBlock struct {
+	header *types.Header
+	state  map[common.Address]contract
+}
+
+// Backend is a bind.ContractBackend backed by simulated contracts. Every
+// transaction is mined into its own block as soon as it is sent.
+type Backend struct {
+	mu        sync.Mutex
+	contracts map[common.Address]contract
+	nonces    map[common.Address]uint64
+	receipts  map[common.Hash]*types.Receipt
+	logs      []types.Log
+	sent      []*types.Transaction
+	chain     []minedBlock
+	block     uint64
+	fork      uint64
+	time      uint64
+	next      uint64
+}
+
+// NewBackend returns an empty backend whose clock starts at the current time.
+func NewBackend() *Backend {
+	b := &Backend{
+		contracts: make(map[common.Address]contract),
+		nonces:    make(map[common.Address]uint64),
+		receipts:  make(map[common.Hash]*types.Receipt),
+		time:      uint64(time.Now().Unix()),
+	}
+	b.chain = []minedBlock{{&types.Header{Number: new(big.Int), Time: b.time}, b.contracts}}
+	return b
+}
+
+// mine adds a block leaving the world in state, returning its header.
+func (b *Backend) mine(state map[common.Address]contract) *types.Header {
+	b.block++
+	header := &types.Header{
+		ParentHash: b.chain[len(b.chain)-1].header.Hash(),
+		Number:     new(big.Int).SetUint64(b.block),
+		Time:       b.time,
+		Extra:      encodeFork(b.fork),
+	}
+	b.contracts = state
+	b.chain = append(b.chain, minedBlock{header, state})
+	return header
+}
+
+func encodeFork(fork uint64) []byte {
+	return new(big.Int).SetUint64(fork).Bytes()
+}
+
+// deploy adds c to the backend at a new address.
+func (b *Backend) deploy(c contract) common.Address {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next++
+	addr := common.BigToAddress(new(big.Int).SetUint64(0x1000 + b.next))
+	b.contracts[addr] = c
+	return addr
+}
+
+// Time returns the backend's current block time.
+func (b *Backend) Time() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.time
+}
+
+// Advance moves the backend's clock forward.
+func (b *Backend) Advance(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.time += uint64(d / time.Second)
+}
+
+// SetTime sets the backend's clock, which unlike Advance can move it back, so
+// sets can be inserted long enough ago to have expired.
+func (b *Backend) SetTime(t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.time = uint64(t.Unix())
+}
+
+// Mine adds n empty blocks.
+func (b *Backend) Mine(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := 0; i < n; i++ {
+		b.mine(b.contracts)
+	}
+}
+
+// Reorg replaces the last depth blocks with as many empty ones, discarding
+// their transactions, logs and state changes.
+func (b *Backend) Reorg(depth int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if uint64(depth) > b.block {
+		panic("reorg deeper than the chain")
+	}
+	keep := b.block - uint64(depth)
+
+	var logs []types.Log
+	for _, l := range b.logs {
+		if l.BlockNumber <= keep {
+			logs = append(logs, l)
+		}
+	}
+	b.logs = logs
+
+	var sent []*types.Transaction
+	for _, tx := range b.sent {
+		if b.receipts[tx.Hash()].BlockNumber.Uint64() <= keep {
+			sent = append(sent, tx)
+			continue
+		}
+		delete(b.receipts, tx.Hash())
+		if from, err := sender(tx); err == nil {
+			b.nonces[from]--
+		}
+	}
+	b.sent = sent
+
+	b.chain = b.chain[:keep+1]
+	b.block = keep
+	b.contracts = b.chain[keep].state
+	b.fork++
+	for i := 0; i < depth; i++ {
+		b.mine(b.contracts)
+	}
+}
+
+// Sent returns every transaction sent so far, in order.
+func (b *Backend) Sent() []*types.Transaction {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*types.Transaction(nil), b.sent...)
+}
+
+// Method returns the name of the method a transaction calls.
+func (b *Backend) Method(tx *types.Transaction) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if tx.To() == nil || len(tx.Data()) < 4 {
+		return ""
+	}
+	c, ok := b.contracts[*tx.To()]
+	if !ok {
+		return ""
+	}
+	method, err := c.definition().MethodById(tx.Data()[:4])
+	if err != nil {
+		return ""
+	}
+	return method.Name
+}
+
+// execute runs a call against a copy of the world, returning the copy so the
+// caller can decide whether to keep it.
+func (b *Backend) execute(state map[common.Address]contract, from common.Address, to *common.Address, input []byte) ([]byte, map[common.Address]contract, []*types.Log, error) {
+	if to == nil {
+		return nil, nil, nil, errors.New("contract creation is not supported")
+	}
+	if _, ok := state[*to]; !ok {
+		return nil, nil, nil, ErrNoCode
+	}
+
+	world := make(map[common.Address]contract, len(state))
+	for addr, c := range state {
+		world[addr] = c.clone()
+	}
+	ctx := &callContext{world: world, self: *to, sender: from, time: b.time}
+
+	c := world[*to]
+	if len(input) < 4 {
+		return nil, nil, nil, revert("no method selector")
+	}
+	method, err := c.definition().MethodById(input[:4])
+	if err != nil {
+		return nil, nil, nil, revert("unknown method %x", input[:4])
+	}
+	args, err := method.Inputs.UnpackValues(input[4:])
+	if err != nil {
+		return nil, nil, nil, revert("bad arguments to %s: %v", method.Name, err)
+	}
+	results, err := c.call(ctx, method.Name, args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	output, err := method.Outputs.Pack(results...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return output, world, ctx.logs, nil
+}
+
+// CodeAt implements bind.ContractCaller.
+func (b *Backend) CodeAt(ctx context.Context, addr common.Address, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.contracts[addr]; ok {
+		return []byte{0xfe}, nil
+	}
+	return nil, nil
+}
+
+// CallContract implements bind.ContractCaller. Calls never change state, and
+// are made against the state after blockNumber if it is given. Changes made
+// outside transactions, such as deploys, are not tracked by block.
+func (b *Backend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.contracts
+	if blockNumber != nil {
+		if !blockNumber.IsUint64() || blockNumber.Uint64() > b.block {
+			return nil, fmt.Errorf("unknown block %v", blockNumber)
+		}
+		state = b.chain[blockNumber.Uint64()].state
+	}
+	output, _, _, err := b.execute(state, call.From, call.To, call.Data)
+	return output, err
+}
+
+// HeaderByNumber returns the header of a block, or of the latest block if
+// number is nil.
+func (b *Backend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if number == nil {
+		return b.chain[b.block].header, nil
+	}
+	if !number.IsUint64() || number.Uint64() > b.block {
+		return nil, ethereum.NotFound
+	}
+	return b.chain[number.Uint64()].header, nil
+}
+
+// PendingCodeAt implements bind.ContractTransactor.
+func (b *Backend) PendingCodeAt(ctx context.Context, addr common.Address) ([]byte, error) {
+	return b.CodeAt(ctx, addr, nil)
+}
+
+// PendingNonceAt implements bind.ContractTransactor.
+func (b *Backend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nonces[account], nil
+}
+
+// SuggestGasPrice implements bind.ContractTransactor.
+func (b *Backend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1000000000), nil
+}
+
+// EstimateGas implements bind.ContractTransactor. Calls that would fail are
+// reported as errors, as a node would.
+func (b *Backend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, _, _, err := b.execute(b.contracts, call.From, call.To, call.Data); err != nil {
+		return 0, fmt.Errorf("gas required exceeds allowance or always failing transaction: %v", err)
+	}
+	return 21000 + 68*uint64(len(call.Data)) + 100000, nil
+}
+
+func sender(tx *types.Transaction) (common.Address, error) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	return types.Sender(signer, tx)
+}
+
+// SendTransaction implements bind.ContractTransactor. The transaction is mined
+// immediately; if the call fails, it is mined with a failed receipt.
+func (b *Backend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from, err := sender(tx)
+	if err != nil {
+		return err
+	}
+	switch nonce := b.nonces[from]; {
+	case tx.Nonce() < nonce:
+		return fmt.Errorf("%v: got %d, want %d", ErrNonceTooLow, tx.Nonce(), nonce)
+	case tx.Nonce() > nonce:
+		return fmt.Errorf("%v: got %d, want %d", ErrNonceGap, tx.Nonce(), nonce)
+	}
+
+	_, world, logs, err := b.execute(b.contracts, from, tx.To(), tx.Data())
+	if err != nil {
+		world, logs = b.contracts, nil
+	}
+
+	b.nonces[from]++
+	b.sent = append(b.sent, tx)
+	header := b.mine(world)
+	receipt := &types.Receipt{
+		Status:      types.ReceiptStatusFailed,
+		TxHash:      tx.Hash(),
+		BlockHash:   header.Hash(),
+		BlockNumber: header.Number,
+		GasUsed:     tx.Gas(),
+	}
+	b.receipts[tx.Hash()] = receipt
+	if err != nil {
+		return nil
+	}
+
+	receipt.Status = types.ReceiptStatusSuccessful
+	for i, l := range logs {
+		l.BlockNumber = b.block
+		l.BlockHash = header.Hash()
+		l.TxHash = tx.Hash()
+		l.Index = uint(i)
+		receipt.Logs = append(receipt.Logs, l)
+		b.logs = append(b.logs, *l)
+	}
+	return nil
+}
+
+// TransactionReceipt implements bind.DeployBackend.
+func (b *Backend) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	receipt, ok := b.receipts[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+// FilterLogs implements bind.ContractFilterer.
+func (b *Backend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ret []types.Log
+	for _, l := range b.logs {
+		if query.FromBlock != nil && l.BlockNumber < query.FromBlock.Uint64() {
+			continue
+		}
+		if query.ToBlock != nil && l.BlockNumber > query.ToBlock.Uint64() {
+			continue
+		}
+		if len(query.Addresses) > 0 && !containsAddress(query.Addresses, l.Address) {
+			continue
+		}
+		if !matchTopics(query.Topics, l.Topics) {
+			continue
+		}
+		ret = append(ret, l)
+	}
+	return ret, nil
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer. Subscriptions are not
+// supported; use FilterLogs.
+func (b *Backend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("subscriptions are not supported")
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTopics(query [][]common.Hash, topics []common.Hash) bool {
+	if len(query) > len(topics) {
+		return false
+	}
+	for i, options := range query {
+		if len(options) == 0 {
+			continue
+		}
+		match := false
+		for _, t := range options {
+			if t == topics[i] {
+				match = true
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// parseABI parses one of the generated bindings' ABIs. They are constants, so
+// failure is a programming error.
+func parseABI(definition string) *abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return &parsed
+}
+
+// keccak returns the Keccak-256 hash of data.
+func keccak(data ...[]byte) [32]byte {
+	var ret [32]byte
+	copy(ret[:], crypto.Keccak256(data...))
+	return ret
+}
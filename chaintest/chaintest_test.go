@@ -0,0 +1,667 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package chaintest_test
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arachnid/dnsprove/chaintest"
+	"github.com/arachnid/dnsprove/dnstest"
+	"github.com/arachnid/dnsprove/ens"
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/arachnid/dnsprove/registrar"
+	"github.com/arachnid/dnsprove/root"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	log.Root().SetHandler(log.DiscardHandler())
+}
+
+// query returns the signed set answering a query, or denying it.
+func query(t *testing.T, s *dnstest.Server, name string, qtype uint16) proofs.SignedSet {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	r := s.Answer(m)
+	rrs := r.Answer
+	if len(rrs) == 0 {
+		rrs = r.Ns
+	}
+	var set proofs.SignedSet
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.RRSIG:
+			set.Sig = rr
+		case *dns.SOA:
+		default:
+			set.Rrs = append(set.Rrs, rr)
+		}
+	}
+	if set.Sig == nil || len(set.Rrs) == 0 {
+		t.Fatalf("no signed answer for %s %s", dns.TypeToString[qtype], name)
+	}
+	set.Name = set.Rrs[0].Header().Name
+	return set
+}
+
+// chain returns the proof chain for a query, through each of zones.
+func chain(t *testing.T, s *dnstest.Server, zones []string, name string, qtype uint16) []proofs.SignedSet {
+	t.Helper()
+	sets := []proofs.SignedSet{query(t, s, ".", dns.TypeDNSKEY)}
+	for _, zone := range zones {
+		sets = append(sets, query(t, s, zone, dns.TypeDS), query(t, s, zone, dns.TypeDNSKEY))
+	}
+	return append(sets, query(t, s, name, qtype))
+}
+
+func transactor(t *testing.T) *bind.TransactOpts {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := bind.NewKeyedTransactor(key)
+	opts.Nonce = big.NewInt(0)
+	return opts
+}
+
+// mined checks that each transaction succeeded, with consecutive nonces from
+// first, and returns the methods they called.
+func mined(t *testing.T, b *chaintest.Backend, first uint64, txs ...*types.Transaction) []string {
+	t.Helper()
+	var methods []string
+	for i, tx := range txs {
+		r, err := b.TransactionReceipt(context.Background(), tx.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("transaction %d (%s) failed", i, b.Method(tx))
+		}
+		if tx.Nonce() != first+uint64(i) {
+			t.Errorf("transaction %d (%s) has nonce %d, want %d", i, b.Method(tx), tx.Nonce(), first+uint64(i))
+		}
+		methods = append(methods, b.Method(tx))
+	}
+	return methods
+}
+
+// checkNonce checks that opts.Nonce was left at the last transaction's, as a
+// caller sending a single one would expect.
+func checkNonce(t *testing.T, opts *bind.TransactOpts, txs []*types.Transaction) {
+	t.Helper()
+	if last := txs[len(txs)-1].Nonce(); opts.Nonce.Uint64() != last {
+		t.Errorf("opts.Nonce is %d after sending, want %d", opts.Nonce.Uint64(), last)
+	}
+}
+
+// legacySetup is a legacy oracle, registrar for .com and registry, and the
+// proof chains for _ens.example.com before and after its TXT record is added.
+type legacySetup struct {
+	b         *chaintest.Backend
+	opts      *bind.TransactOpts
+	oracle    *oracle.Oracle
+	registrar *registrar.DNSRegistrar
+	ens       *ens.ENS
+	txt, nsec []proofs.SignedSet
+}
+
+func newLegacy(t *testing.T) *legacySetup {
+	t.Helper()
+	s, err := dnstest.NewServer(dns.ECDSAP256SHA256, "com.", "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := chaintest.NewBackend()
+	opts := transactor(t)
+	oracleAddr, err := b.DeployOracle(s.Anchors()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensAddr := b.DeployENS(opts.From)
+	registrarAddr, err := b.DeployRegistrar(oracleAddr, ensAddr, "com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zones := []string{"com.", "example.com."}
+	nsec := chain(t, s, zones, "_ens.example.com.", dns.TypeTXT)
+	if err := s.Zone("example.com.").Add(`_ens.example.com. 3600 IN TXT "a=` + opts.From.Hex() + `"`); err != nil {
+		t.Fatal(err)
+	}
+	txt := chain(t, s, zones, "_ens.example.com.", dns.TypeTXT)
+
+	o, err := oracle.New(oracleAddr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := registrar.New(registrarAddr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := ens.New(ensAddr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &legacySetup{b, opts, o, reg, e, txt, nsec}
+}
+
+func TestRecordMatches(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		submit bool
+		change bool
+		want   bool
+	}{
+		{"not submitted", false, false, false},
+		{"submitted", true, false, true},
+		{"changed since submitted", true, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newLegacy(t)
+			if tc.submit {
+				tx, err := l.oracle.SendProofs(l.opts, l.txt, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				mined(t, l.b, 0, tx)
+			}
+			set := l.txt[len(l.txt)-1]
+			if tc.change {
+				set = l.nsec[len(l.nsec)-1]
+			}
+			matches, err := l.oracle.RecordMatches(set)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matches != tc.want {
+				t.Errorf("RecordMatches = %v, want %v", matches, tc.want)
+			}
+		})
+	}
+}
+
+func TestSendProofs(t *testing.T) {
+	l := newLegacy(t)
+	tx, err := l.oracle.SendProofs(l.opts, l.txt[:3], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mined(t, l.b, 0, tx)
+	known, err := l.oracle.FindFirstUnknownProof(l.txt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if known != 3 {
+		t.Fatalf("oracle knows %d sets after submitting 3", known)
+	}
+
+	l.opts.Nonce.Add(l.opts.Nonce, big.NewInt(1))
+	tx, err = l.oracle.SendProofs(l.opts, l.txt, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mined(t, l.b, 1, tx)
+	if known, err = l.oracle.FindFirstUnknownProof(l.txt); err != nil || known != len(l.txt) {
+		t.Fatalf("oracle knows %d of %d sets after submitting all of them (%v)", known, len(l.txt), err)
+	}
+}
+
+func TestDeleteRRSet(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		qtype   uint16
+		qname   string
+		deleted bool
+	}{
+		{"NSEC denies the set", dns.TypeTXT, "_ens.example.com", true},
+		{"NSEC is for another name", dns.TypeSOA, "example.com", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newLegacy(t)
+			tx, err := l.oracle.SendProofs(l.opts, l.txt, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mined(t, l.b, 0, tx)
+
+			// The NSEC's signer is already known from the TXT record's chain.
+			l.opts.Nonce.Add(l.opts.Nonce, big.NewInt(1))
+			proof, err := l.nsec[len(l.nsec)-2].PackRRSet()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, err = l.oracle.DeleteRRSet(l.opts, tc.qtype, tc.qname, l.nsec[len(l.nsec)-1], proof)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l.opts.Nonce.Uint64() != 2 {
+				t.Errorf("DeleteRRSet left opts.Nonce at %d, want 2", l.opts.Nonce.Uint64())
+			}
+			r, err := l.b.TransactionReceipt(context.Background(), tx.Hash())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if succeeded := r.Status == types.ReceiptStatusSuccessful; succeeded != tc.deleted {
+				t.Fatalf("deleteRRSet succeeded = %v, want %v", succeeded, tc.deleted)
+			}
+			_, _, hash, err := l.oracle.Rrdata(nil, dns.TypeTXT, "_ens.example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted := hash == [20]byte{}; deleted != tc.deleted {
+				t.Errorf("TXT record deleted = %v, want %v", deleted, tc.deleted)
+			}
+		})
+	}
+}
+
+func TestDeleteRRSetNotSent(t *testing.T) {
+	l := newLegacy(t)
+	proof, err := l.nsec[len(l.nsec)-2].PackRRSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The node rejects a transaction that leaves a gap in the nonces.
+	l.opts.Nonce = big.NewInt(1)
+	if _, err := l.oracle.DeleteRRSet(l.opts, dns.TypeTXT, "_ens.example.com", l.nsec[len(l.nsec)-1], proof); err == nil {
+		t.Fatal("DeleteRRSet sent a transaction with a nonce gap")
+	}
+	if l.opts.Nonce.Uint64() != 1 {
+		t.Errorf("DeleteRRSet left opts.Nonce at %d after failing, want 1", l.opts.Nonce.Uint64())
+	}
+}
+
+func TestClaim(t *testing.T) {
+	l := newLegacy(t)
+	if !l.registrar.Legacy() {
+		t.Fatal("registrar not detected as legacy")
+	}
+	for i := uint64(0); i < 2; i++ {
+		// The second claim finds the record already in the oracle.
+		tx, err := l.registrar.Claim(l.opts, "example.com", l.txt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mined(t, l.b, i, tx)
+		l.opts.Nonce.Add(l.opts.Nonce, big.NewInt(1))
+
+		owner, err := l.ens.Owner("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owner != l.opts.From {
+			t.Fatalf("claim %d: owner is %s, want %s", i, owner.Hex(), l.opts.From.Hex())
+		}
+	}
+}
+
+func TestUnclaim(t *testing.T) {
+	l := newLegacy(t)
+	tx, err := l.registrar.Claim(l.opts, "example.com", l.txt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mined(t, l.b, 0, tx)
+
+	l.opts.Nonce.Add(l.opts.Nonce, big.NewInt(1))
+	txs, err := l.registrar.Unclaim(l.opts, "example.com", l.nsec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if methods := mined(t, l.b, 1, txs...); !reflect.DeepEqual(methods, []string{"deleteRRSet", "claim"}) {
+		t.Errorf("Unclaim sent %v", methods)
+	}
+	checkNonce(t, l.opts, txs)
+
+	owner, err := l.ens.Owner("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != (common.Address{}) {
+		t.Errorf("owner is %s after unclaiming", owner.Hex())
+	}
+	if _, _, hash, err := l.oracle.Rrdata(nil, dns.TypeTXT, "_ens.example.com"); err != nil || hash != [20]byte{} {
+		t.Errorf("TXT record still in oracle after unclaiming (%v)", err)
+	}
+}
+
+func TestClaimDefault(t *testing.T) {
+	defaultRegistrar := common.HexToAddress("0xdef")
+	for _, tc := range []struct {
+		name     string
+		claimed  bool
+		unsigned bool
+		methods  []string
+	}{
+		{"claimed through DNS", true, false, []string{"deleteRRSet", "registerTLD"}},
+		{"never claimed", false, false, []string{"proveAndRegisterDefaultTLD"}},
+		{"claimed, TXT now unsigned", true, true, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := dnstest.NewServer(dns.ECDSAP256SHA256, "xyz.")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := chaintest.NewBackend()
+			opts := transactor(t)
+			oracleAddr, err := b.DeployOracle(s.Anchors()[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensAddr := b.DeployENS(opts.From)
+			rootAddr, err := b.DeployRoot(oracleAddr, ensAddr, defaultRegistrar)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := root.New(rootAddr, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, err := ens.New(ensAddr, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nsec := chain(t, s, []string{"xyz."}, "_ens.nic.xyz.", dns.TypeTXT)
+			ds := chain(t, s, nil, "xyz.", dns.TypeDS)
+			if tc.claimed {
+				if err := s.Zone("xyz.").Add(`_ens.nic.xyz. 3600 IN TXT "a=0x0000000000000000000000000000000000000abc"`); err != nil {
+					t.Fatal(err)
+				}
+				tx, err := r.Claim(opts, "xyz", chain(t, s, []string{"xyz."}, "_ens.nic.xyz.", dns.TypeTXT))
+				if err != nil {
+					t.Fatal(err)
+				}
+				mined(t, b, 0, tx)
+				opts.Nonce.Add(opts.Nonce, big.NewInt(1))
+				if owner, _ := e.Owner("xyz"); owner != common.HexToAddress("0xabc") {
+					t.Fatalf("owner is %s after claiming", owner.Hex())
+				}
+			}
+
+			if tc.unsigned {
+				// There is no NSEC to delete the oracle's record with.
+				txs, err := r.ClaimDefault(opts, "xyz", nil, ds)
+				if err != root.UndeletableRecordError || len(txs) != 0 {
+					t.Errorf("ClaimDefault sent %d transactions, err = %v; want %v", len(txs), err, root.UndeletableRecordError)
+				}
+				return
+			}
+
+			first := opts.Nonce.Uint64()
+			txs, err := r.ClaimDefault(opts, "xyz", nsec, ds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if methods := mined(t, b, first, txs...); !reflect.DeepEqual(methods, tc.methods) {
+				t.Errorf("ClaimDefault sent %v, want %v", methods, tc.methods)
+			}
+			checkNonce(t, opts, txs)
+
+			owner, err := e.Owner("xyz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if owner != defaultRegistrar {
+				t.Errorf("owner is %s, want the default registrar", owner.Hex())
+			}
+			o, err := r.GetOracle()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, hash, err := o.Rrdata(nil, dns.TypeTXT, "_ens.nic.xyz"); err != nil || hash != [20]byte{} {
+				t.Errorf("_ens.nic.xyz still in oracle (%v)", err)
+			}
+		})
+	}
+}
+
+func TestStatelessClaim(t *testing.T) {
+	s, err := dnstest.NewServer(dns.ED25519, "com.", "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := chaintest.NewBackend()
+	opts := transactor(t)
+	oracleAddr, err := b.DeployStatelessOracle(s.Anchors()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensAddr := b.DeployENS(opts.From)
+	registrarAddr, err := b.DeployRegistrar2(oracleAddr, ensAddr, "com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolverAddr := b.DeployResolver(ensAddr)
+	if err := s.Zone("example.com.").Add(`_ens.example.com. 3600 IN TXT "a=` + opts.From.Hex() + `"`); err != nil {
+		t.Fatal(err)
+	}
+	txt := chain(t, s, []string{"com.", "example.com."}, "_ens.example.com.", dns.TypeTXT)
+
+	o, err := oracle.New(oracleAddr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !o.Stateless() {
+		t.Fatal("oracle not detected as stateless")
+	}
+	if _, _, err := o.Verify(txt); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := registrar.New(registrarAddr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.Legacy() {
+		t.Fatal("registrar detected as legacy")
+	}
+	tx, err := reg.ClaimWithResolver(opts, "example.com", txt, resolverAddr, common.HexToAddress("0x1234"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mined(t, b, 0, tx)
+
+	e, err := ens.New(ensAddr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner, _ := e.Owner("example.com"); owner != opts.From {
+		t.Errorf("owner is %s", owner.Hex())
+	}
+	res, err := e.Resolver("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr, _ := res.Addr(); addr != common.HexToAddress("0x1234") {
+		t.Errorf("addr is %s", addr.Hex())
+	}
+
+	if err := b.SetAlgorithms(oracleAddr, dns.RSASHA256); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := o.Verify(txt); err == nil || !strings.Contains(err.Error(), "unsupported algorithm") {
+		t.Errorf("Verify with ED25519 unsupported: %v", err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	now := time.Now()
+	// Sets inserted this long ago have outlived their TTL in the oracle.
+	past := now.Add(-2 * dnstest.TTL * time.Second)
+
+	for _, tc := range []struct {
+		name string
+		// setup submits to the oracle, and returns the chain to plan for.
+		setup   func(t *testing.T, p *planSetup) []proofs.SignedSet
+		err     string
+		states  []oracle.SetState
+		first   int
+		reasons map[int]string
+	}{
+		{
+			name: "nothing in oracle",
+			setup: func(t *testing.T, p *planSetup) []proofs.SignedSet {
+				return p.txt
+			},
+			states:  []oracle.SetState{oracle.StateMissing, oracle.StateMissing, oracle.StateMissing, oracle.StateMissing, oracle.StateMissing, oracle.StateMissing},
+			first:   0,
+			reasons: map[int]string{0: "oracle's copy is missing", 5: "oracle's copy is missing"},
+		},
+		{
+			name: "chain already current",
+			setup: func(t *testing.T, p *planSetup) []proofs.SignedSet {
+				p.submit(t, p.txt, 0)
+				return p.txt
+			},
+			states:  []oracle.SetState{oracle.StateCurrent, oracle.StateCurrent, oracle.StateCurrent, oracle.StateCurrent, oracle.StateCurrent, oracle.StateCurrent},
+			first:   6,
+			reasons: map[int]string{0: "not needed as a later set is already usable", 5: "already current in oracle"},
+		},
+		{
+			name: "proven by the previous set",
+			setup: func(t *testing.T, p *planSetup) []proofs.SignedSet {
+				p.submit(t, p.txt[:3], 0)
+				return p.txt
+			},
+			states:  []oracle.SetState{oracle.StateCurrent, oracle.StateCurrent, oracle.StateCurrent, oracle.StateMissing, oracle.StateMissing, oracle.StateMissing},
+			first:   3,
+			reasons: map[int]string{2: "oracle's copy is current and can prove DS example.com.", 3: "oracle's copy is missing"},
+		},
+		{
+			name: "stale root key behind a current DS",
+			setup: func(t *testing.T, p *planSetup) []proofs.SignedSet {
+				p.b.SetTime(past)
+				p.submit(t, p.txt, 0)
+				p.b.SetTime(now)
+				p.submit(t, p.txt[:5], 1)
+				return p.changed(t)
+			},
+			states:  []oracle.SetState{oracle.StateExpired, oracle.StateCurrent, oracle.StateCurrent, oracle.StateCurrent, oracle.StateCurrent, oracle.StateOutdated},
+			first:   5,
+			reasons: map[int]string{0: "oracle's copy is expired, but not needed", 4: "can prove TXT _ens.example.com.", 5: "oracle's copy is outdated"},
+		},
+		{
+			name: "stale key still proves the next set",
+			setup: func(t *testing.T, p *planSetup) []proofs.SignedSet {
+				p.b.SetTime(past)
+				p.submit(t, p.txt, 0)
+				p.b.SetTime(now)
+				return p.changed(t)
+			},
+			states:  []oracle.SetState{oracle.StateExpired, oracle.StateExpired, oracle.StateExpired, oracle.StateExpired, oracle.StateExpired, oracle.StateOutdated},
+			first:   5,
+			reasons: map[int]string{4: "oracle's copy is expired and can prove TXT _ens.example.com."},
+		},
+		{
+			name: "oracle holds a newer record",
+			setup: func(t *testing.T, p *planSetup) []proofs.SignedSet {
+				old := p.txt
+				for _, zone := range []*dnstest.Zone{p.s.Root(), p.s.Zone("com."), p.s.Zone("example.com.")} {
+					zone.SetValidity(past.Add(time.Hour), now.Add(24*time.Hour))
+				}
+				p.submit(t, chain(t, p.s, []string{"com.", "example.com."}, "_ens.example.com.", dns.TypeTXT), 0)
+				return old
+			},
+			err: "inception after our record's inception",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newPlanSetup(t, past.Add(-time.Hour), now.Add(24*time.Hour))
+			plan, err := p.oracle.PlanProofs(tc.setup(t, p))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, step := range plan.Steps {
+				if step.Status.State != tc.states[i] {
+					t.Errorf("set %d is %s, want %s", i, step.Status.State, tc.states[i])
+				}
+				if step.Submit != (i >= tc.first) {
+					t.Errorf("set %d: submit = %v (%s)", i, step.Submit, step.Reason)
+				}
+			}
+			if plan.First != tc.first || plan.Submissions() != len(plan.Steps)-tc.first {
+				t.Errorf("plan submits from set %d (%d sets), want %d", plan.First, plan.Submissions(), tc.first)
+			}
+			for i, reason := range tc.reasons {
+				if !strings.Contains(plan.Steps[i].Reason, reason) {
+					t.Errorf("set %d skipped or submitted because %q, want %q", i, plan.Steps[i].Reason, reason)
+				}
+			}
+		})
+	}
+}
+
+// planSetup is a legacy oracle and the proof chain for _ens.example.com's TXT
+// record, signed to be valid from inception to expiration.
+type planSetup struct {
+	s      *dnstest.Server
+	b      *chaintest.Backend
+	opts   *bind.TransactOpts
+	oracle *oracle.Oracle
+	txt    []proofs.SignedSet
+}
+
+func newPlanSetup(t *testing.T, inception, expiration time.Time) *planSetup {
+	t.Helper()
+	s, err := dnstest.NewServer(dns.ECDSAP256SHA256, "com.", "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, zone := range []*dnstest.Zone{s.Root(), s.Zone("com."), s.Zone("example.com.")} {
+		zone.SetValidity(inception, expiration)
+	}
+	if err := s.Zone("example.com.").Add(`_ens.example.com. 3600 IN TXT "a=0x0000000000000000000000000000000000000001"`); err != nil {
+		t.Fatal(err)
+	}
+	b := chaintest.NewBackend()
+	addr, err := b.DeployOracle(s.Anchors()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := oracle.New(addr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := chain(t, s, []string{"com.", "example.com."}, "_ens.example.com.", dns.TypeTXT)
+	return &planSetup{s, b, transactor(t), o, txt}
+}
+
+// submit sends sets from known onwards, proven by the set before known.
+func (p *planSetup) submit(t *testing.T, sets []proofs.SignedSet, known int) {
+	t.Helper()
+	tx, err := p.oracle.SendProofs(p.opts, sets, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mined(t, p.b, p.opts.Nonce.Uint64(), tx)
+	p.opts.Nonce.Add(p.opts.Nonce, big.NewInt(1))
+}
+
+// changed adds a second TXT record, and returns the new chain.
+func (p *planSetup) changed(t *testing.T) []proofs.SignedSet {
+	t.Helper()
+	if err := p.s.Zone("example.com.").Add(`_ens.example.com. 3600 IN TXT "changed"`); err != nil {
+		t.Fatal(err)
+	}
+	return chain(t, p.s, []string{"com.", "example.com."}, "_ens.example.com.", dns.TypeTXT)
+}
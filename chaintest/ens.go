@@ -0,0 +1,197 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package chaintest
+
+import (
+	"fmt"
+
+	"github.com/arachnid/dnsprove/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ensABI      = parseABI(contracts.ENSABI)
+	resolverABI = parseABI(contracts.ResolverABI)
+)
+
+type ensRecord struct {
+	owner    common.Address
+	resolver common.Address
+	ttl      uint64
+}
+
+// registry is the ENS registry.
+type registry struct {
+	records map[[32]byte]ensRecord
+}
+
+// DeployENS adds an ENS registry whose root node is owned by owner.
+func (b *Backend) DeployENS(owner common.Address) common.Address {
+	r := &registry{make(map[[32]byte]ensRecord)}
+	r.records[[32]byte{}] = ensRecord{owner: owner}
+	return b.deploy(r)
+}
+
+// SetENSOwner sets the owner of name in the registry at addr directly, as
+// test setup.
+func (b *Backend) SetENSOwner(addr common.Address, name string, owner common.Address) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.contracts[addr].(*registry)
+	if !ok {
+		return fmt.Errorf("no ENS registry at %s", addr.String())
+	}
+	node := Namehash(name)
+	record := r.records[node]
+	record.owner = owner
+	r.records[node] = record
+	return nil
+}
+
+func (r *registry) definition() *abi.ABI {
+	return ensABI
+}
+
+func (r *registry) clone() contract {
+	records := make(map[[32]byte]ensRecord, len(r.records))
+	for k, v := range r.records {
+		records[k] = v
+	}
+	return &registry{records}
+}
+
+func (r *registry) call(ctx *callContext, method string, args []interface{}) ([]interface{}, error) {
+	switch method {
+	case "owner":
+		return []interface{}{r.records[args[0].([32]byte)].owner}, nil
+	case "resolver":
+		return []interface{}{r.records[args[0].([32]byte)].resolver}, nil
+	case "ttl":
+		return []interface{}{r.records[args[0].([32]byte)].ttl}, nil
+	case "setOwner":
+		return nil, r.setOwner(ctx, args[0].([32]byte), args[1].(common.Address))
+	case "setSubnodeOwner":
+		_, err := r.setSubnodeOwner(ctx, args[0].([32]byte), args[1].([32]byte), args[2].(common.Address))
+		return nil, err
+	case "setResolver":
+		return nil, r.setResolver(ctx, args[0].([32]byte), args[1].(common.Address))
+	case "setTTL":
+		node := args[0].([32]byte)
+		if err := r.authorise(ctx, node); err != nil {
+			return nil, err
+		}
+		record := r.records[node]
+		record.ttl = args[1].(uint64)
+		r.records[node] = record
+		return nil, ctx.emit("NewTTL", node, record.ttl)
+	}
+	return nil, revert("%s is not implemented", method)
+}
+
+// authorise requires the caller to own node.
+func (r *registry) authorise(ctx *callContext, node [32]byte) error {
+	if owner := r.records[node].owner; owner != ctx.sender {
+		return revert("%s does not own node %x; %s does", ctx.sender.String(), node, owner.String())
+	}
+	return nil
+}
+
+// as returns a context for a call from the contract running in ctx to the
+// one at addr.
+func (ctx *callContext) as(addr common.Address) *callContext {
+	return &callContext{world: ctx.world, self: addr, sender: ctx.self, time: ctx.time}
+}
+
+// finish adds the logs of a nested call to those of its caller.
+func (ctx *callContext) finish(inner *callContext) {
+	ctx.logs = append(ctx.logs, inner.logs...)
+}
+
+func (r *registry) setOwner(ctx *callContext, node [32]byte, owner common.Address) error {
+	if err := r.authorise(ctx, node); err != nil {
+		return err
+	}
+	record := r.records[node]
+	record.owner = owner
+	r.records[node] = record
+	return ctx.emit("Transfer", node, owner)
+}
+
+func (r *registry) setSubnodeOwner(ctx *callContext, node, label [32]byte, owner common.Address) ([32]byte, error) {
+	if err := r.authorise(ctx, node); err != nil {
+		return [32]byte{}, err
+	}
+	subnode := keccak(node[:], label[:])
+	record := r.records[subnode]
+	record.owner = owner
+	r.records[subnode] = record
+	return subnode, ctx.emit("NewOwner", node, label, owner)
+}
+
+func (r *registry) setResolver(ctx *callContext, node [32]byte, resolver common.Address) error {
+	if err := r.authorise(ctx, node); err != nil {
+		return err
+	}
+	record := r.records[node]
+	record.resolver = resolver
+	r.records[node] = record
+	return ctx.emit("NewResolver", node, resolver)
+}
+
+// resolver is a public resolver supporting only the addr profile.
+type resolver struct {
+	ens   common.Address
+	addrs map[[32]byte]common.Address
+}
+
+// addrInterfaceID is the ERC-165 interface ID of addr(bytes32).
+var addrInterfaceID = [4]byte{0x3b, 0x3b, 0x57, 0xde}
+
+// DeployResolver adds a resolver, supporting addr records, that lets the
+// owners of names in the registry at ens set their records.
+func (b *Backend) DeployResolver(ens common.Address) common.Address {
+	return b.deploy(&resolver{ens, make(map[[32]byte]common.Address)})
+}
+
+func (r *resolver) definition() *abi.ABI {
+	return resolverABI
+}
+
+func (r *resolver) clone() contract {
+	addrs := make(map[[32]byte]common.Address, len(r.addrs))
+	for k, v := range r.addrs {
+		addrs[k] = v
+	}
+	return &resolver{r.ens, addrs}
+}
+
+func (r *resolver) call(ctx *callContext, method string, args []interface{}) ([]interface{}, error) {
+	switch method {
+	case "supportsInterface":
+		id := args[0].([4]byte)
+		return []interface{}{id == addrInterfaceID || id == ERC165_INTERFACE_ID}, nil
+	case "addr", "addr0":
+		if len(args) == 1 {
+			return []interface{}{r.addrs[args[0].([32]byte)]}, nil
+		}
+	case "setAddr", "setAddr0":
+		if len(args) == 2 {
+			return nil, r.setAddr(ctx, args[0].([32]byte), args[1].(common.Address))
+		}
+	}
+	return nil, revert("%s is not implemented", method)
+}
+
+func (r *resolver) setAddr(ctx *callContext, node [32]byte, addr common.Address) error {
+	ens, ok := ctx.at(r.ens).(*registry)
+	if !ok {
+		return revert("no ENS registry")
+	}
+	if err := ens.authorise(ctx, node); err != nil {
+		return err
+	}
+	r.addrs[node] = addr
+	return nil
+}
@@ -0,0 +1,322 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package chaintest
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/arachnid/dnsprove/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/miekg/dns"
+)
+
+var (
+	dnssecABI     = parseABI(contracts.DNSSECABI)
+	dnssecImplABI = parseABI(contracts.DNSSECImplABI)
+)
+
+// VERIFY_RRSET_INTERFACE_ID is the ERC-165 interface ID of stateless oracles.
+var VERIFY_RRSET_INTERFACE_ID = [4]byte{0xbd, 0xf9, 0x5f, 0xef}
+
+// ERC165_INTERFACE_ID is the ERC-165 interface ID of supportsInterface.
+var ERC165_INTERFACE_ID = [4]byte{0x01, 0xff, 0xc9, 0xa7}
+
+type rrsetKey struct {
+	rrtype uint16
+	name   string
+}
+
+type rrset struct {
+	inception uint32
+	inserted  uint64
+	hash      [20]byte
+}
+
+// legacyOracle stores proven RRSets. Every submission must be proven by the
+// trust anchors or by a set it already holds.
+type legacyOracle struct {
+	verifier
+	anchors []byte
+	rrsets  map[rrsetKey]rrset
+}
+
+// statelessOracle stores nothing, and verifies a whole chain on every call.
+type statelessOracle struct {
+	verifier
+	anchors []byte
+}
+
+// packAnchors encodes trust anchors as the oracles store them.
+func packAnchors(anchors []dns.RR) ([]byte, error) {
+	var ret []byte
+	for _, rr := range anchors {
+		buf := make([]byte, dns.Len(rr)+1)
+		off, err := dns.PackRR(rr, buf, 0, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, buf[:off]...)
+	}
+	return ret, nil
+}
+
+// DeployOracle adds a legacy oracle, which stores proven RRSets, trusting
+// anchors. They are usually the root zone's DS records.
+func (b *Backend) DeployOracle(anchors ...dns.RR) (common.Address, error) {
+	packed, err := packAnchors(anchors)
+	if err != nil {
+		return common.Address{}, err
+	}
+	o := &legacyOracle{anchors: packed, rrsets: make(map[rrsetKey]rrset)}
+	if len(anchors) > 0 {
+		// As in the contract, the anchors are stored with no inception so any
+		// real record replaces them.
+		key := rrsetKey{anchors[0].Header().Rrtype, strings.ToLower(anchors[0].Header().Name)}
+		o.rrsets[key] = rrset{0, b.Time(), hashRRSet(packed)}
+	}
+	return b.deploy(o), nil
+}
+
+// DeployStatelessOracle adds a stateless oracle trusting anchors.
+func (b *Backend) DeployStatelessOracle(anchors ...dns.RR) (common.Address, error) {
+	packed, err := packAnchors(anchors)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return b.deploy(&statelessOracle{anchors: packed}), nil
+}
+
+// SetAlgorithms limits the signature algorithms the oracle at addr supports.
+// With none, it supports every algorithm.
+func (b *Backend) SetAlgorithms(addr common.Address, algorithms ...uint8) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	v := verifier{make(map[uint8]bool)}
+	for _, alg := range algorithms {
+		v.algorithms[alg] = true
+	}
+	switch o := b.contracts[addr].(type) {
+	case *legacyOracle:
+		o.verifier = v
+	case *statelessOracle:
+		o.verifier = v
+	default:
+		return fmt.Errorf("no oracle at %s", addr.String())
+	}
+	return nil
+}
+
+func (o *legacyOracle) definition() *abi.ABI {
+	return dnssecABI
+}
+
+func (o *legacyOracle) clone() contract {
+	rrsets := make(map[rrsetKey]rrset, len(o.rrsets))
+	for k, v := range o.rrsets {
+		rrsets[k] = v
+	}
+	return &legacyOracle{o.verifier.clone(), o.anchors, rrsets}
+}
+
+func (o *legacyOracle) call(ctx *callContext, method string, args []interface{}) ([]interface{}, error) {
+	switch method {
+	case "anchors":
+		return []interface{}{o.anchors}, nil
+	case "rrdata":
+		name, err := unpackName(args[1].([]byte))
+		if err != nil {
+			return nil, revert("bad name: %v", err)
+		}
+		set := o.rrsets[rrsetKey{args[0].(uint16), name}]
+		return []interface{}{set.inception, set.inserted, set.hash}, nil
+	case "submitRRSet":
+		set, err := decodeSet(args[0].([]byte), args[1].([]byte))
+		if err != nil {
+			return nil, revert("bad RRSet: %v", err)
+		}
+		if err := o.submit(ctx, set, args[2].([]byte)); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	case "submitRRSets":
+		rrs, err := o.submitRRSets(ctx, args[0].([]byte), args[1].([]byte))
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{rrs}, nil
+	case "deleteRRSet":
+		return nil, o.deleteRRSet(ctx, args[0].(uint16), args[1].([]byte), args[2].([]byte), args[3].([]byte), args[4].([]byte))
+	}
+	return nil, revert("%s is not implemented", method)
+}
+
+// checkProof requires proof to be a set the oracle holds.
+func (o *legacyOracle) checkProof(proof []byte) error {
+	rrs, err := unpackRRSet(proof)
+	if err != nil || len(rrs) == 0 {
+		return revert("bad proof")
+	}
+	key := rrsetKey{rrs[0].Header().Rrtype, strings.ToLower(rrs[0].Header().Name)}
+	if o.rrsets[key].hash != hashRRSet(proof) {
+		return revert("proof %s %s does not match the oracle's copy", dns.TypeToString[key.rrtype], key.name)
+	}
+	return nil
+}
+
+func (o *legacyOracle) submitRRSets(ctx *callContext, data, proof []byte) ([]byte, error) {
+	sets, err := decodeSets(data)
+	if err != nil {
+		return nil, revert("bad RRSets: %v", err)
+	}
+	for _, set := range sets {
+		if err := o.submit(ctx, set, proof); err != nil {
+			return nil, err
+		}
+		proof = set.data
+	}
+	return proof, nil
+}
+
+func (o *legacyOracle) submit(ctx *callContext, set *signedSet, proof []byte) error {
+	if err := o.checkProof(proof); err != nil {
+		return err
+	}
+	if err := o.verify(set, proof, ctx.time); err != nil {
+		return err
+	}
+
+	key := rrsetKey{set.rrtype(), strings.ToLower(set.name())}
+	if existing, ok := o.rrsets[key]; ok && existing.inception > set.sig.Inception {
+		return revert("oracle's copy of %s %s is newer", dns.TypeToString[key.rrtype], key.name)
+	}
+	o.rrsets[key] = rrset{set.sig.Inception, ctx.time, hashRRSet(set.data)}
+	return ctx.emit("RRSetUpdated", packName(key.name), set.data)
+}
+
+func (o *legacyOracle) deleteRRSet(ctx *callContext, rrtype uint16, packedName, data, sig, proof []byte) error {
+	set, err := decodeSet(data, sig)
+	if err != nil {
+		return revert("bad NSEC: %v", err)
+	}
+	if err := o.checkProof(proof); err != nil {
+		return err
+	}
+	if err := o.verify(set, proof, ctx.time); err != nil {
+		return err
+	}
+	nsec, ok := set.rrs[0].(*dns.NSEC)
+	if !ok {
+		return revert("%s is not an NSEC record", dns.TypeToString[set.rrtype()])
+	}
+
+	name, err := unpackName(packedName)
+	if err != nil {
+		return revert("bad name: %v", err)
+	}
+	if !dns.IsSubDomain(set.sig.SignerName, name) {
+		return revert("NSEC from %s cannot prove anything about %s", set.sig.SignerName, name)
+	}
+	if !nsecProves(nsec, name, rrtype) {
+		return revert("NSEC %s does not prove %s %s is absent", nsec.Hdr.Name, dns.TypeToString[rrtype], name)
+	}
+
+	key := rrsetKey{rrtype, name}
+	if existing, ok := o.rrsets[key]; ok && existing.inception > set.sig.Inception {
+		return revert("oracle's copy of %s %s is newer than the NSEC", dns.TypeToString[rrtype], name)
+	}
+	delete(o.rrsets, key)
+	return ctx.emit("RRSetUpdated", packedName, []byte{})
+}
+
+// nsecProves reports whether nsec shows there is no rrtype record at name.
+func nsecProves(nsec *dns.NSEC, name string, rrtype uint16) bool {
+	owner := strings.ToLower(nsec.Hdr.Name)
+	next := strings.ToLower(nsec.NextDomain)
+	if owner == name {
+		for _, t := range nsec.TypeBitMap {
+			if t == rrtype {
+				return false
+			}
+		}
+		return true
+	}
+	if !canonicalLess(owner, name) {
+		return false
+	}
+	// The last NSEC in a zone points back to its apex.
+	return canonicalLess(name, next) || !canonicalLess(owner, next)
+}
+
+// verifyChain checks a chain of sets starting from anchors, returning the
+// last set's records and inception.
+func (v verifier) verifyChain(anchors []byte, sets []*signedSet, now uint64) ([]byte, uint32, error) {
+	proof, inception := anchors, uint32(0)
+	for _, set := range sets {
+		if err := v.verify(set, proof, now); err != nil {
+			return nil, 0, err
+		}
+		proof, inception = set.data, set.sig.Inception
+	}
+	return proof, inception, nil
+}
+
+func (o *statelessOracle) definition() *abi.ABI {
+	return dnssecImplABI
+}
+
+func (o *statelessOracle) clone() contract {
+	return &statelessOracle{o.verifier.clone(), o.anchors}
+}
+
+func (o *statelessOracle) call(ctx *callContext, method string, args []interface{}) ([]interface{}, error) {
+	switch method {
+	case "anchors":
+		return []interface{}{o.anchors}, nil
+	case "supportsInterface":
+		id := args[0].([4]byte)
+		return []interface{}{id == VERIFY_RRSET_INTERFACE_ID || id == ERC165_INTERFACE_ID}, nil
+	case "verifyRRSet", "verifyRRSet0":
+		sets, err := decodeInput(args[0])
+		if err != nil {
+			return nil, revert("bad input: %v", err)
+		}
+		now := ctx.time
+		if len(args) > 1 {
+			now = args[1].(*big.Int).Uint64()
+		}
+		rrs, inception, err := o.verifyChain(o.anchors, sets, now)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{rrs, inception}, nil
+	}
+	return nil, revert("%s is not implemented", method)
+}
+
+// txtOwner returns the owner named by the TXT record at name, as proven by
+// proof, or false if the oracle holds no such record and proof is empty.
+func (o *legacyOracle) txtOwner(name string, proof []byte) (common.Address, bool, error) {
+	held := o.rrsets[rrsetKey{dns.TypeTXT, strings.ToLower(name)}]
+	if held.hash == ([20]byte{}) && len(proof) == 0 {
+		return common.Address{}, false, nil
+	}
+	if held.hash != hashRRSet(proof) {
+		return common.Address{}, false, revert("proof does not match the oracle's TXT record for %s", name)
+	}
+	rrs, err := unpackRRSet(proof)
+	if err != nil {
+		return common.Address{}, false, revert("bad proof: %v", err)
+	}
+	owner, _ := parseOwner(rrs)
+	return owner, true, nil
+}
+
+// holds reports whether the oracle holds a record of type rrtype at name.
+func (o *legacyOracle) holds(rrtype uint16, name string) bool {
+	return o.rrsets[rrsetKey{rrtype, strings.ToLower(name)}].hash != [20]byte{}
+}
@@ -0,0 +1,251 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package chaintest
+
+import (
+	"strings"
+
+	"github.com/arachnid/dnsprove/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/miekg/dns"
+)
+
+var (
+	dnsRegistrarABI  = parseABI(contracts.DNSRegistrarABI)
+	dnsRegistrar2ABI = parseABI(contracts.DNSRegistrar2ABI)
+)
+
+var (
+	// DNSSEC_CLAIM_INTERFACE_ID is the ERC-165 interface ID of legacy registrars.
+	DNSSEC_CLAIM_INTERFACE_ID = [4]byte{0x1a, 0xa2, 0xe6, 0x41}
+	// DNS_REGISTRAR_INTERFACE_ID is the ERC-165 interface ID of current registrars.
+	DNS_REGISTRAR_INTERFACE_ID = [4]byte{0x2f, 0x43, 0x54, 0x28}
+)
+
+// legacyRegistrar assigns names to the owners named in TXT records held by a
+// legacy oracle.
+type legacyRegistrar struct {
+	oracle common.Address
+	ens    common.Address
+}
+
+// registrar assigns names to the owners named in TXT records proven to a
+// stateless oracle with each claim.
+type registrar struct {
+	oracle     common.Address
+	ens        common.Address
+	inceptions map[[32]byte]uint32
+}
+
+// DeployRegistrar adds a legacy registrar using the legacy oracle at oracle,
+// and makes it the owner of suffix in the ENS registry at ens.
+func (b *Backend) DeployRegistrar(oracle, ens common.Address, suffix string) (common.Address, error) {
+	addr := b.deploy(&legacyRegistrar{oracle, ens})
+	return addr, b.SetENSOwner(ens, suffix, addr)
+}
+
+// DeployRegistrar2 adds a current registrar using the stateless oracle at
+// oracle, and makes it the owner of suffix in the ENS registry at ens.
+func (b *Backend) DeployRegistrar2(oracle, ens common.Address, suffix string) (common.Address, error) {
+	addr := b.deploy(&registrar{oracle, ens, make(map[[32]byte]uint32)})
+	return addr, b.SetENSOwner(ens, suffix, addr)
+}
+
+// splitName returns the first label of a packed name and the rest of it.
+func splitName(packed []byte) (string, string, error) {
+	name, err := unpackName(packed)
+	if err != nil {
+		return "", "", revert("bad name: %v", err)
+	}
+	labels := dns.SplitDomainName(name)
+	if len(labels) == 0 {
+		return "", "", revert("cannot claim the root")
+	}
+	return labels[0], dns.Fqdn(strings.Join(labels[1:], ".")), nil
+}
+
+// setSubnodeOwner calls the registry at ens from the contract running in ctx.
+func setSubnodeOwner(ctx *callContext, ens common.Address, parent, label string, owner common.Address) ([32]byte, error) {
+	r, ok := ctx.at(ens).(*registry)
+	if !ok {
+		return [32]byte{}, revert("no ENS registry")
+	}
+	var l [32]byte
+	copy(l[:], labelhash(label))
+	inner := ctx.as(ens)
+	node, err := r.setSubnodeOwner(inner, Namehash(parent), l, owner)
+	ctx.finish(inner)
+	return node, err
+}
+
+func (r *legacyRegistrar) definition() *abi.ABI {
+	return dnsRegistrarABI
+}
+
+func (r *legacyRegistrar) clone() contract {
+	return &legacyRegistrar{r.oracle, r.ens}
+}
+
+func (r *legacyRegistrar) call(ctx *callContext, method string, args []interface{}) ([]interface{}, error) {
+	switch method {
+	case "oracle":
+		return []interface{}{r.oracle}, nil
+	case "supportsInterface":
+		id := args[0].([4]byte)
+		return []interface{}{id == DNSSEC_CLAIM_INTERFACE_ID || id == ERC165_INTERFACE_ID}, nil
+	case "claim":
+		return nil, r.claim(ctx, args[0].([]byte), args[1].([]byte))
+	case "proveAndClaim":
+		o, err := legacyOracleAt(ctx, r.oracle)
+		if err != nil {
+			return nil, err
+		}
+		inner := ctx.as(r.oracle)
+		proof, err := o.submitRRSets(inner, args[1].([]byte), args[2].([]byte))
+		ctx.finish(inner)
+		if err != nil {
+			return nil, err
+		}
+		return nil, r.claim(ctx, args[0].([]byte), proof)
+	}
+	return nil, revert("%s is not implemented", method)
+}
+
+// claim assigns name to the owner in its _ens TXT record, which proof must
+// match. With no record and an empty proof, the name is given to no one.
+func (r *legacyRegistrar) claim(ctx *callContext, name, proof []byte) error {
+	label, parent, err := splitName(name)
+	if err != nil {
+		return err
+	}
+	o, err := legacyOracleAt(ctx, r.oracle)
+	if err != nil {
+		return err
+	}
+	owner, _, err := o.txtOwner("_ens."+label+"."+parent, proof)
+	if err != nil {
+		return err
+	}
+	_, err = setSubnodeOwner(ctx, r.ens, parent, label, owner)
+	return err
+}
+
+func legacyOracleAt(ctx *callContext, addr common.Address) (*legacyOracle, error) {
+	o, ok := ctx.at(addr).(*legacyOracle)
+	if !ok {
+		return nil, revert("no legacy oracle at %s", addr.String())
+	}
+	return o, nil
+}
+
+func (r *registrar) definition() *abi.ABI {
+	return dnsRegistrar2ABI
+}
+
+func (r *registrar) clone() contract {
+	inceptions := make(map[[32]byte]uint32, len(r.inceptions))
+	for k, v := range r.inceptions {
+		inceptions[k] = v
+	}
+	return &registrar{r.oracle, r.ens, inceptions}
+}
+
+func (r *registrar) call(ctx *callContext, method string, args []interface{}) ([]interface{}, error) {
+	switch method {
+	case "oracle":
+		return []interface{}{r.oracle}, nil
+	case "supportsInterface":
+		id := args[0].([4]byte)
+		return []interface{}{id == DNS_REGISTRAR_INTERFACE_ID || id == ERC165_INTERFACE_ID}, nil
+	case "proveAndClaim":
+		c, err := r.verify(ctx, args[0].([]byte), args[1])
+		if err != nil {
+			return nil, err
+		}
+		_, err = setSubnodeOwner(ctx, r.ens, c.parent, c.label, c.owner)
+		return nil, err
+	case "proveAndClaimWithResolver":
+		return nil, r.claimWithResolver(ctx, args[0].([]byte), args[1], args[2].(common.Address), args[3].(common.Address))
+	}
+	return nil, revert("%s is not implemented", method)
+}
+
+// claim is a name whose _ens TXT record has been proven.
+type claim struct {
+	label  string
+	parent string
+	node   [32]byte
+	owner  common.Address
+}
+
+// verify checks the chain in input, which must end with the _ens TXT record
+// for name, and returns the owner it names.
+func (r *registrar) verify(ctx *callContext, name []byte, input interface{}) (claim, error) {
+	label, parent, err := splitName(name)
+	if err != nil {
+		return claim{}, err
+	}
+	sets, err := decodeInput(input)
+	if err != nil {
+		return claim{}, revert("bad input: %v", err)
+	}
+	if len(sets) == 0 {
+		return claim{}, revert("no proof")
+	}
+	last := sets[len(sets)-1]
+	if txt := "_ens." + label + "." + parent; last.rrtype() != dns.TypeTXT || !strings.EqualFold(last.name(), txt) {
+		return claim{}, revert("proof is for %s %s, not TXT %s", dns.TypeToString[last.rrtype()], last.name(), txt)
+	}
+
+	o, ok := ctx.at(r.oracle).(*statelessOracle)
+	if !ok {
+		return claim{}, revert("no stateless oracle at %s", r.oracle.String())
+	}
+	_, inception, err := o.verifyChain(o.anchors, sets, ctx.time)
+	if err != nil {
+		return claim{}, err
+	}
+
+	c := claim{label: label, parent: parent, node: Namehash(label + "." + parent)}
+	if inception < r.inceptions[c.node] {
+		return claim{}, revert("stale proof: inception %d is before %d", inception, r.inceptions[c.node])
+	}
+	r.inceptions[c.node] = inception
+	c.owner, _ = parseOwner(last.rrs)
+	return c, ctx.emit("Claim", c.node, c.owner, name, inception)
+}
+
+// claimWithResolver claims name for the sender, who must be its owner, and
+// sets its resolver and address. The registrar holds the name while it does
+// so.
+func (r *registrar) claimWithResolver(ctx *callContext, name []byte, input interface{}, resolverAddr, addr common.Address) error {
+	c, err := r.verify(ctx, name, input)
+	if err != nil {
+		return err
+	}
+	if c.owner != ctx.sender {
+		return revert("only the owner %s may set a resolver", c.owner.String())
+	}
+	if _, err := setSubnodeOwner(ctx, r.ens, c.parent, c.label, ctx.self); err != nil {
+		return err
+	}
+
+	ens := ctx.at(r.ens).(*registry)
+	inner := ctx.as(r.ens)
+	defer ctx.finish(inner)
+	if err := ens.setResolver(inner, c.node, resolverAddr); err != nil {
+		return err
+	}
+	if addr != (common.Address{}) {
+		res, ok := ctx.at(resolverAddr).(*resolver)
+		if !ok {
+			return revert("no resolver at %s", resolverAddr.String())
+		}
+		if err := res.setAddr(ctx.as(resolverAddr), c.node, addr); err != nil {
+			return err
+		}
+	}
+	return ens.setOwner(inner, c.node, c.owner)
+}
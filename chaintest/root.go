@@ -0,0 +1,98 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package chaintest
+
+import (
+	"github.com/arachnid/dnsprove/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/miekg/dns"
+)
+
+var rootABI = parseABI(contracts.RootABI)
+
+// DNSSEC_ROOT_CLAIM_INTERFACE_ID is the ERC-165 interface ID of the root.
+var DNSSEC_ROOT_CLAIM_INTERFACE_ID = [4]byte{0xc7, 0xfe, 0x16, 0xbf}
+
+// root assigns TLDs to the owners named in _ens.nic TXT records held by a
+// legacy oracle, or to the default registrar if a TLD is signed but has no
+// such record.
+type root struct {
+	oracle           common.Address
+	ens              common.Address
+	defaultRegistrar common.Address
+}
+
+// DeployRoot adds a root using the legacy oracle at oracle, and makes it the
+// owner of the root node in the ENS registry at ens.
+func (b *Backend) DeployRoot(oracle, ens, defaultRegistrar common.Address) (common.Address, error) {
+	addr := b.deploy(&root{oracle, ens, defaultRegistrar})
+	return addr, b.SetENSOwner(ens, ".", addr)
+}
+
+func (r *root) definition() *abi.ABI {
+	return rootABI
+}
+
+func (r *root) clone() contract {
+	return &root{r.oracle, r.ens, r.defaultRegistrar}
+}
+
+func (r *root) call(ctx *callContext, method string, args []interface{}) ([]interface{}, error) {
+	switch method {
+	case "oracle":
+		return []interface{}{r.oracle}, nil
+	case "supportsInterface":
+		id := args[0].([4]byte)
+		return []interface{}{id == DNSSEC_ROOT_CLAIM_INTERFACE_ID || id == ERC165_INTERFACE_ID}, nil
+	case "registerTLD":
+		return nil, r.registerTLD(ctx, args[0].([]byte), args[1].([]byte))
+	case "proveAndRegisterTLD", "proveAndRegisterDefaultTLD":
+		o, err := legacyOracleAt(ctx, r.oracle)
+		if err != nil {
+			return nil, err
+		}
+		inner := ctx.as(r.oracle)
+		proof, err := o.submitRRSets(inner, args[1].([]byte), args[2].([]byte))
+		ctx.finish(inner)
+		if err != nil {
+			return nil, err
+		}
+		if method == "proveAndRegisterDefaultTLD" {
+			proof = nil
+		}
+		return nil, r.registerTLD(ctx, args[0].([]byte), proof)
+	}
+	return nil, revert("%s is not implemented", method)
+}
+
+// registerTLD assigns a TLD to the owner named in its _ens.nic TXT record,
+// which proof must match. With no record and an empty proof, the TLD must
+// have a DS record, and goes to the default registrar.
+func (r *root) registerTLD(ctx *callContext, name, proof []byte) error {
+	label, parent, err := splitName(name)
+	if err != nil {
+		return err
+	}
+	if parent != "." {
+		return revert("%s.%s is not a TLD", label, parent)
+	}
+	o, err := legacyOracleAt(ctx, r.oracle)
+	if err != nil {
+		return err
+	}
+
+	owner, found, err := o.txtOwner("_ens.nic."+label+".", proof)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if !o.holds(dns.TypeDS, label+".") {
+			return revert("oracle holds no DS record for %s", label)
+		}
+		owner = r.defaultRegistrar
+	}
+	_, err = setSubnodeOwner(ctx, r.ens, parent, label, owner)
+	return err
+}
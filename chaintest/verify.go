@@ -0,0 +1,93 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package chaintest
+
+import (
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// verifier checks signatures as the oracle contracts do. If algorithms is
+// non-empty, only the algorithms it lists are supported, as if the others
+// had never been registered with the oracle.
+type verifier struct {
+	algorithms map[uint8]bool
+}
+
+func (v verifier) clone() verifier {
+	algorithms := make(map[uint8]bool, len(v.algorithms))
+	for alg, ok := range v.algorithms {
+		algorithms[alg] = ok
+	}
+	return verifier{algorithms}
+}
+
+// verify checks that set is validly signed, at time now, by a key in proof.
+// A proof is either the DNSKEY set of the signer, or a DS set for a DNSKEY
+// set that signs itself.
+func (v verifier) verify(set *signedSet, proof []byte, now uint64) error {
+	sig := set.sig
+	name := set.name()
+
+	if !sig.ValidityPeriod(time.Unix(int64(now), 0)) {
+		return revert("signature on %s %s is not valid at %d", dns.TypeToString[set.rrtype()], name, now)
+	}
+	if !dns.IsSubDomain(sig.SignerName, name) {
+		return revert("signer %s is not an ancestor of %s", sig.SignerName, name)
+	}
+	if int(sig.Labels) > dns.CountLabel(name) {
+		return revert("signature on %s has too many labels", name)
+	}
+	if len(v.algorithms) > 0 && !v.algorithms[sig.Algorithm] {
+		return revert("unsupported algorithm %d", sig.Algorithm)
+	}
+
+	keys, err := unpackRRSet(proof)
+	if err != nil {
+		return revert("bad proof: %v", err)
+	}
+	if len(keys) == 0 {
+		return revert("empty proof")
+	}
+	if !strings.EqualFold(keys[0].Header().Name, sig.SignerName) {
+		return revert("proof is for %s, not signer %s", keys[0].Header().Name, sig.SignerName)
+	}
+
+	switch keys[0].Header().Rrtype {
+	case dns.TypeDNSKEY:
+		for _, rr := range keys {
+			if key, ok := rr.(*dns.DNSKEY); ok && sig.Verify(key, set.rrs) == nil {
+				return nil
+			}
+		}
+	case dns.TypeDS:
+		if set.rrtype() != dns.TypeDNSKEY || !strings.EqualFold(name, sig.SignerName) {
+			return revert("a DS proof can only prove a self-signed DNSKEY set")
+		}
+		for _, rr := range set.rrs {
+			key, ok := rr.(*dns.DNSKEY)
+			if !ok || sig.Verify(key, set.rrs) != nil {
+				continue
+			}
+			for _, rr := range keys {
+				if ds, ok := rr.(*dns.DS); ok && matchesDS(key, ds) {
+					return nil
+				}
+			}
+		}
+	default:
+		return revert("proof is a %s set, not DNSKEY or DS", dns.TypeToString[keys[0].Header().Rrtype])
+	}
+	return revert("no key in proof verifies the signature on %s %s", dns.TypeToString[set.rrtype()], name)
+}
+
+func matchesDS(key *dns.DNSKEY, ds *dns.DS) bool {
+	if key.Algorithm != ds.Algorithm || key.KeyTag() != ds.KeyTag {
+		return false
+	}
+	computed := key.ToDS(ds.DigestType)
+	return computed != nil && strings.EqualFold(computed.Digest, ds.Digest)
+}
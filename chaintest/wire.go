@@ -0,0 +1,230 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package chaintest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/miekg/dns"
+)
+
+// signedSet is an RRSet as submitted to the oracle: the RRSIG's fields, less
+// its signature, followed by the records in canonical form.
+type signedSet struct {
+	sig  *dns.RRSIG
+	rrs  []dns.RR
+	data []byte
+}
+
+// name returns the owner name of the set's records.
+func (s *signedSet) name() string {
+	return s.rrs[0].Header().Name
+}
+
+// rrtype returns the type of the set's records.
+func (s *signedSet) rrtype() uint16 {
+	return s.rrs[0].Header().Rrtype
+}
+
+// decodeSet decodes a set in the format produced by proofs.SignedSet.Pack,
+// with its signature.
+func decodeSet(data, sig []byte) (*signedSet, error) {
+	if len(data) < 18 {
+		return nil, errors.New("short RRSIG data")
+	}
+	rrsig := &dns.RRSIG{
+		Hdr:         dns.RR_Header{Rrtype: dns.TypeRRSIG, Class: dns.ClassINET},
+		TypeCovered: binary.BigEndian.Uint16(data[0:]),
+		Algorithm:   data[2],
+		Labels:      data[3],
+		OrigTtl:     binary.BigEndian.Uint32(data[4:]),
+		Expiration:  binary.BigEndian.Uint32(data[8:]),
+		Inception:   binary.BigEndian.Uint32(data[12:]),
+		KeyTag:      binary.BigEndian.Uint16(data[16:]),
+		Signature:   base64.StdEncoding.EncodeToString(sig),
+	}
+	signer, off, err := dns.UnpackDomainName(data, 18)
+	if err != nil {
+		return nil, err
+	}
+	rrsig.SignerName = signer
+
+	rrs, err := unpackRRSet(data[off:])
+	if err != nil {
+		return nil, err
+	}
+	if len(rrs) == 0 {
+		return nil, errors.New("empty RRSet")
+	}
+	if !dns.IsRRset(rrs) {
+		return nil, errors.New("records do not form an RRSet")
+	}
+	if rrs[0].Header().Rrtype != rrsig.TypeCovered {
+		return nil, fmt.Errorf("signature covers %s, not %s", dns.TypeToString[rrsig.TypeCovered], dns.TypeToString[rrs[0].Header().Rrtype])
+	}
+	rrsig.Hdr.Name = rrs[0].Header().Name
+	return &signedSet{rrsig, rrs, data[off:]}, nil
+}
+
+// unpackRRSet decodes a packed RRSet, as used for proofs.
+func unpackRRSet(data []byte) ([]dns.RR, error) {
+	var rrs []dns.RR
+	for off := 0; off < len(data); {
+		var rr dns.RR
+		var err error
+		rr, off, err = dns.UnpackRR(data, off)
+		if err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// decodeSets decodes the length-prefixed sets passed to submitRRSets.
+func decodeSets(data []byte) ([]*signedSet, error) {
+	var sets []*signedSet
+	for off := 0; off < len(data); {
+		var set, sig []byte
+		var err error
+		if set, off, err = readPrefixed(data, off); err != nil {
+			return nil, err
+		}
+		if sig, off, err = readPrefixed(data, off); err != nil {
+			return nil, err
+		}
+		decoded, err := decodeSet(set, sig)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, decoded)
+	}
+	return sets, nil
+}
+
+func readPrefixed(data []byte, off int) ([]byte, int, error) {
+	if off+2 > len(data) {
+		return nil, 0, errors.New("truncated length prefix")
+	}
+	n := int(binary.BigEndian.Uint16(data[off:]))
+	off += 2
+	if off+n > len(data) {
+		return nil, 0, errors.New("truncated data")
+	}
+	return data[off : off+n], off + n, nil
+}
+
+// decodeInput decodes the RRSetWithSignature structs passed to stateless
+// oracles. The ABI decoder returns them as anonymous structs.
+func decodeInput(input interface{}) ([]*signedSet, error) {
+	v := reflect.ValueOf(input)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("unexpected input %T", input)
+	}
+	sets := make([]*signedSet, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		set, err := decodeSet(item.FieldByName("Rrset").Bytes(), item.FieldByName("Sig").Bytes())
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// unpackName decodes a name in DNS wire format, lower-casing it.
+func unpackName(data []byte) (string, error) {
+	name, off, err := dns.UnpackDomainName(data, 0)
+	if err != nil {
+		return "", err
+	}
+	if off != len(data) {
+		return "", errors.New("trailing data after name")
+	}
+	return strings.ToLower(name), nil
+}
+
+// packName encodes a name in DNS wire format.
+func packName(name string) []byte {
+	buf := make([]byte, len(name)+2)
+	off, err := dns.PackDomainName(dns.Fqdn(name), buf, 0, nil, false)
+	if err != nil {
+		panic(err)
+	}
+	return buf[:off]
+}
+
+// Namehash returns the ENS namehash of a DNS name.
+func Namehash(name string) [32]byte {
+	var node [32]byte
+	labels := dns.SplitDomainName(strings.ToLower(name))
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = keccak(node[:], labelhash(labels[i]))
+	}
+	return node
+}
+
+func labelhash(label string) []byte {
+	h := keccak([]byte(label))
+	return h[:]
+}
+
+// hashRRSet returns the truncated hash the oracle stores for a packed RRSet.
+func hashRRSet(data []byte) [20]byte {
+	var ret [20]byte
+	h := keccak(data)
+	copy(ret[:], h[:])
+	return ret
+}
+
+// ownerPrefix is the prefix of a TXT string naming an ENS owner.
+const ownerPrefix = "a=0x"
+
+// parseOwner returns the first address named by a TXT RRSet.
+func parseOwner(rrs []dns.RR) (common.Address, bool) {
+	for _, rr := range rrs {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		for _, s := range txt.Txt {
+			if !strings.HasPrefix(s, ownerPrefix) {
+				continue
+			}
+			hex := s[len(ownerPrefix):]
+			if len(hex) == 2*common.AddressLength && isHex(hex) {
+				return common.HexToAddress(hex), true
+			}
+		}
+	}
+	return common.Address{}, false
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalLess orders names as in RFC 4034 section 6.1.
+func canonicalLess(a, b string) bool {
+	alabels := dns.SplitDomainName(strings.ToLower(a))
+	blabels := dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(alabels) && i <= len(blabels); i++ {
+		if c := strings.Compare(alabels[len(alabels)-i], blabels[len(blabels)-i]); c != 0 {
+			return c < 0
+		}
+	}
+	return len(alabels) < len(blabels)
+}
@@ -0,0 +1,251 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/ethclient"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// Profile holds the settings for one network. Empty fields leave the
+// corresponding flag at its default.
+type Profile struct {
+	ChainID  uint64   `toml:"chain_id"`
+	RPC      string   `toml:"rpc"`
+	Registry string   `toml:"registry"`
+	Oracle   string   `toml:"oracle"`
+	Root     string   `toml:"root"`
+	Server   string   `toml:"server"`
+	Anchors  []string `toml:"anchors"`
+	Keyfile  string   `toml:"keyfile"`
+	GasPrice float64  `toml:"gasprice"`
+}
+
+// Config is the contents of a config file. Profile names the profile used when
+// -profile isn't given.
+type Config struct {
+	Profile  string              `toml:"profile"`
+	Profiles map[string]*Profile `toml:"profiles"`
+}
+
+// defaultProfiles are available without a config file, and are extended by
+// profiles of the same name in one.
+var defaultProfiles = map[string]Profile{
+	"mainnet": {ChainID: 1, Registry: "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"},
+	"goerli":  {ChainID: 5, Registry: "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"},
+	"sepolia": {ChainID: 11155111, Registry: "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"},
+	"local":   {ChainID: 1337, RPC: "http://localhost:8545"},
+}
+
+var (
+	// activeProfile is the profile in use, if any.
+	activeProfile *Profile
+	// rootAddress is the root contract from the active profile, used to find
+	// an oracle when none is given.
+	rootAddress string
+)
+
+// merge overrides fields of p with those set in o.
+func (p Profile) merge(o *Profile) Profile {
+	if o.ChainID != 0 {
+		p.ChainID = o.ChainID
+	}
+	for _, pair := range []struct{ dst, src *string }{
+		{&p.RPC, &o.RPC}, {&p.Registry, &o.Registry}, {&p.Oracle, &o.Oracle}, {&p.Root, &o.Root}, {&p.Server, &o.Server}, {&p.Keyfile, &o.Keyfile},
+	} {
+		if *pair.src != "" {
+			*pair.dst = *pair.src
+		}
+	}
+	if len(o.Anchors) > 0 {
+		p.Anchors = o.Anchors
+	}
+	if o.GasPrice != 0 {
+		p.GasPrice = o.GasPrice
+	}
+	return p
+}
+
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".dnsprove.toml")
+}
+
+// loadConfig reads the config file at path. A missing file at the default path
+// is not an error.
+func loadConfig(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+
+	config := &Config{}
+	if path != "" {
+		if _, err := toml.DecodeFile(path, config); err != nil {
+			if !explicit && os.IsNotExist(err) {
+				return config, nil
+			}
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// selectProfile returns the named profile, from the config file or the
+// defaults. An empty name selects the config file's default, if any.
+func (c *Config) selectProfile(name string) (*Profile, string, error) {
+	if name == "" {
+		name = c.Profile
+	}
+	if name == "" {
+		return nil, "", nil
+	}
+
+	base, isDefault := defaultProfiles[name]
+	override, inFile := c.Profiles[name]
+	if !isDefault && !inFile {
+		var names []string
+		for n := range defaultProfiles {
+			names = append(names, n)
+		}
+		for n := range c.Profiles {
+			if _, ok := defaultProfiles[n]; !ok {
+				names = append(names, n)
+			}
+		}
+		sort.Strings(names)
+		return nil, "", fmt.Errorf("Unknown profile %q; known profiles are %v", name, names)
+	}
+	if inFile {
+		base = base.merge(override)
+	}
+	return &base, name, nil
+}
+
+// parseAnchors parses DS records in presentation format.
+func parseAnchors(anchors []string) ([]*dns.DS, error) {
+	var ret []*dns.DS
+	for _, anchor := range anchors {
+		rr, err := dns.NewRR(anchor)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trust anchor %q: %v", anchor, err)
+		}
+		ds, ok := rr.(*dns.DS)
+		if !ok {
+			return nil, fmt.Errorf("Trust anchor %q is not a DS record", anchor)
+		}
+		ret = append(ret, ds)
+	}
+	return ret, nil
+}
+
+// apply makes the profile's settings the defaults for every flag that wasn't
+// given on the command line.
+func (p *Profile) apply() error {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	globals := []struct {
+		name  string
+		value string
+	}{
+		{"rpc", p.RPC},
+		{"server", p.Server},
+		{"keyfile", p.Keyfile},
+		{"ens", p.Registry},
+	}
+	if p.GasPrice != 0 {
+		globals = append(globals, struct {
+			name  string
+			value string
+		}{"gasprice", strconv.FormatFloat(p.GasPrice, 'f', -1, 64)})
+	}
+	for _, g := range globals {
+		if g.value == "" || set[g.name] {
+			continue
+		}
+		if err := flag.Set(g.name, g.value); err != nil {
+			return err
+		}
+	}
+
+	// Subcommand flags haven't been parsed yet, so anything given on the
+	// command line will still override these.
+	if p.Oracle != "" {
+		for _, fs := range []*flag.FlagSet{proveFlags, watchFlags, indexFlags, inspectFlags, verifyFlags, serveFlags} {
+			if err := fs.Set("address", p.Oracle); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(p.Anchors) > 0 {
+		anchors, err := parseAnchors(p.Anchors)
+		if err != nil {
+			return err
+		}
+		trustAnchors = anchors
+	}
+	rootAddress = p.Root
+	return nil
+}
+
+// loadProfile reads the config file and applies the selected profile, if any.
+func loadProfile() {
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		log.Crit("Could not load config file", "err", err)
+		exit(1)
+	}
+	profile, name, err := config.selectProfile(*profileName)
+	if err != nil {
+		log.Crit("Could not select profile", "err", err)
+		exit(1)
+	}
+	if profile == nil {
+		return
+	}
+	if err := profile.apply(); err != nil {
+		log.Crit("Could not apply profile", "profile", name, "err", err)
+		exit(1)
+	}
+	activeProfile = profile
+	log.Debug("Using profile", "profile", name, "chainid", profile.ChainID)
+}
+
+// dial connects to the Ethereum node given by -rpc, and checks it is on the
+// active profile's chain.
+func dial() (*chainClient, error) {
+	conn, err := ethclient.Dial(*rpc)
+	if err != nil {
+		return nil, err
+	}
+	if activeProfile == nil || activeProfile.ChainID == 0 {
+		return &chainClient{conn}, nil
+	}
+
+	id, err := conn.ChainID(context.TODO())
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Could not check chain ID: %v", err)
+	}
+	if !id.IsUint64() || id.Uint64() != activeProfile.ChainID {
+		conn.Close()
+		return nil, fmt.Errorf("Node at %s is on chain %s, but the profile is for chain %d", *rpc, id, activeProfile.ChainID)
+	}
+	return &chainClient{conn}, nil
+}
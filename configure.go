@@ -0,0 +1,207 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arachnid/dnsprove/ens"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/inconshreveable/log15"
+)
+
+// ensCommand holds what the ENS configuration subcommands have in common.
+type ensCommand struct {
+	flags    *flag.FlagSet
+	registry *string
+	yes      *bool
+	usage    string
+	nargs    int
+}
+
+func newENSCommand(name, usage string, nargs int) *ensCommand {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	return &ensCommand{
+		flags:    flags,
+		registry: flags.String("address", "", "Address or ENS name of the ENS registry; -ens if not given"),
+		yes:      flags.Bool("yes", false, "Do not prompt before sending transactions"),
+		usage:    usage,
+		nargs:    nargs,
+	}
+}
+
+// parse parses args, and returns the normalized name and remaining arguments,
+// or false if usage was shown.
+func (c *ensCommand) parse(args []string) (string, []string, bool) {
+	c.flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] %s [%s options] %s\n", os.Args[0], c.flags.Name(), c.flags.Name(), c.usage)
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n%s command options:\n", strings.ToUpper(c.flags.Name()[:1])+c.flags.Name()[1:])
+		c.flags.PrintDefaults()
+	}
+	c.flags.Parse(args)
+
+	if c.flags.NArg() != c.nargs {
+		c.flags.Usage()
+		return "", nil, false
+	}
+
+	name, err := ens.Normalize(c.flags.Arg(0))
+	if err != nil {
+		log.Crit("Invalid name", "err", err)
+		exit(1)
+	}
+	return name, c.flags.Args()[1:], true
+}
+
+func (c *ensCommand) connect() (*chainClient, *ens.ENS) {
+	conn, err := dial()
+	if err != nil {
+		log.Crit("Error connecting to Ethereum node", "err", err)
+		exit(1)
+	}
+
+	registry, err := openRegistry(conn, *c.registry)
+	if err != nil {
+		log.Crit("Error instantiating registry", "err", err)
+		exit(1)
+	}
+	return conn, registry
+}
+
+// transactor confirms with the user and returns a transactor for owner, which
+// must be the account in the keyfile. It returns nil if the user declines.
+func (c *ensCommand) transactor(conn *chainClient, owner common.Address, format string, args ...interface{}) *bind.TransactOpts {
+	return ownerTransactor(conn, owner, *c.yes, format, args...)
+}
+
+// ownerTransactor is ensCommand.transactor for commands with their own -yes
+// flag.
+func ownerTransactor(conn *chainClient, owner common.Address, yes bool, format string, args ...interface{}) *bind.TransactOpts {
+	if !yes {
+		if !confirm(format, args...) {
+			fmt.Printf("Exiting at user request.\n")
+			return nil
+		}
+	}
+
+	auth, err := makeTransactor(conn)
+	if err != nil {
+		log.Crit("Could not create transactor", "err", err)
+		exit(1)
+	}
+	if auth.From != owner {
+		log.Crit("Account is not the owner", "account", auth.From.String(), "owner", owner.String())
+		exit(1)
+	}
+	return auth
+}
+
+func logSent(tx *types.Transaction, err error) {
+	if err != nil {
+		log.Crit("Error sending transaction", "err", err)
+		exit(1)
+	}
+	log.Info("Sent transaction", "tx", tx.Hash().String())
+}
+
+func parseAddressArg(arg, what string) common.Address {
+	if !common.IsHexAddress(arg) {
+		log.Crit("Invalid address", what, arg)
+		exit(1)
+	}
+	return common.HexToAddress(arg)
+}
+
+func getOwner(registry *ens.ENS, name string) common.Address {
+	owner, err := registry.Owner(name)
+	if err != nil {
+		log.Crit("Could not get ENS owner", "name", name, "err", err)
+		exit(1)
+	}
+	if owner == (common.Address{}) {
+		log.Crit("Name does not exist in ENS", "name", name)
+		exit(1)
+	}
+	return owner
+}
+
+func setResolverCommand(args []string) {
+	name, rest, ok := setResolverCmd.parse(args)
+	if !ok {
+		return
+	}
+	resolver := parseAddressArg(rest[0], "resolver")
+
+	conn, registry := setResolverCmd.connect()
+	owner := getOwner(registry, name)
+	current, err := registry.ResolverAddress(name)
+	if err != nil {
+		log.Crit("Could not get resolver", "name", name, "err", err)
+		exit(1)
+	}
+
+	auth := setResolverCmd.transactor(conn, owner, "Send a transaction to change the resolver for %s from %s to %s?", name, current.String(), resolver.String())
+	if auth == nil {
+		return
+	}
+	logSent(registry.SetResolver(auth, name, resolver))
+}
+
+func transferCommand(args []string) {
+	name, rest, ok := transferCmd.parse(args)
+	if !ok {
+		return
+	}
+	newOwner := parseAddressArg(rest[0], "owner")
+
+	conn, registry := transferCmd.connect()
+	owner := getOwner(registry, name)
+
+	auth := transferCmd.transactor(conn, owner, "Send a transaction to transfer %s from %s to %s?", name, owner.String(), newOwner.String())
+	if auth == nil {
+		return
+	}
+	logSent(registry.SetOwner(auth, name, newOwner))
+}
+
+func subdomainCommand(args []string) {
+	name, _, ok := subdomainCmd.parse(args)
+	if !ok {
+		return
+	}
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) < 2 {
+		log.Crit("Name has no parent to create it under", "name", name)
+		exit(1)
+	}
+
+	conn, registry := subdomainCmd.connect()
+	parentOwner := getOwner(registry, parts[1])
+	newOwner := parentOwner
+	if *subdomainOwner != "" {
+		newOwner = parseAddressArg(*subdomainOwner, "owner")
+	}
+	current, err := registry.Owner(name)
+	if err != nil {
+		log.Crit("Could not get ENS owner", "name", name, "err", err)
+		exit(1)
+	}
+
+	msg := "Send a transaction to create %s owned by %s?"
+	if current != (common.Address{}) {
+		msg = "Send a transaction to reassign %s to %s (currently owned by " + current.String() + ")?"
+	}
+	auth := subdomainCmd.transactor(conn, parentOwner, msg, name, newOwner.String())
+	if auth == nil {
+		return
+	}
+	logSent(registry.SetSubnodeOwner(auth, name, newOwner))
+}
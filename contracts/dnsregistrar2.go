@@ -0,0 +1,410 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package contracts
+
+import (
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = abi.U256
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// DNSRegistrar2ABI is the input ABI used to generate the binding from.
+const DNSRegistrar2ABI = "[{\"type\":\"function\",\"name\":\"oracle\",\"stateMutability\":\"view\",\"constant\":true,\"payable\":false,\"inputs\":[],\"outputs\":[{\"name\":\"\",\"type\":\"address\"}]},{\"type\":\"function\",\"name\":\"proveAndClaim\",\"stateMutability\":\"nonpayable\",\"constant\":false,\"payable\":false,\"inputs\":[{\"name\":\"name\",\"type\":\"bytes\"},{\"name\":\"input\",\"type\":\"tuple[]\",\"internalType\":\"structDNSSECImpl.RRSetWithSignature[]\",\"components\":[{\"name\":\"rrset\",\"type\":\"bytes\"},{\"name\":\"sig\",\"type\":\"bytes\"}]}],\"outputs\":[]},{\"type\":\"function\",\"name\":\"proveAndClaimWithResolver\",\"stateMutability\":\"nonpayable\",\"constant\":false,\"payable\":false,\"inputs\":[{\"name\":\"name\",\"type\":\"bytes\"},{\"name\":\"input\",\"type\":\"tuple[]\",\"internalType\":\"structDNSSECImpl.RRSetWithSignature[]\",\"components\":[{\"name\":\"rrset\",\"type\":\"bytes\"},{\"name\":\"sig\",\"type\":\"bytes\"}]},{\"name\":\"resolver\",\"type\":\"address\"},{\"name\":\"addr\",\"type\":\"address\"}],\"outputs\":[]},{\"type\":\"function\",\"name\":\"supportsInterface\",\"stateMutability\":\"pure\",\"constant\":true,\"payable\":false,\"inputs\":[{\"name\":\"interfaceID\",\"type\":\"bytes4\"}],\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}]},{\"type\":\"event\",\"name\":\"Claim\",\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"node\",\"type\":\"bytes32\"},{\"indexed\":true,\"name\":\"owner\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"dnsname\",\"type\":\"bytes\"},{\"indexed\":false,\"name\":\"inception\",\"type\":\"uint32\"}]}]"
+
+// DNSRegistrar2 is an auto generated Go binding around an Ethereum contract.
+type DNSRegistrar2 struct {
+	DNSRegistrar2Caller     // Read-only binding to the contract
+	DNSRegistrar2Transactor // Write-only binding to the contract
+	DNSRegistrar2Filterer   // Log filterer for contract events
+}
+
+// DNSRegistrar2Caller is an auto generated read-only Go binding around an Ethereum contract.
+type DNSRegistrar2Caller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DNSRegistrar2Transactor is an auto generated write-only Go binding around an Ethereum contract.
+type DNSRegistrar2Transactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DNSRegistrar2Filterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type DNSRegistrar2Filterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DNSRegistrar2Session is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type DNSRegistrar2Session struct {
+	Contract     *DNSRegistrar2    // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// DNSRegistrar2CallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type DNSRegistrar2CallerSession struct {
+	Contract *DNSRegistrar2Caller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts        // Call options to use throughout this session
+}
+
+// DNSRegistrar2TransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type DNSRegistrar2TransactorSession struct {
+	Contract     *DNSRegistrar2Transactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts        // Transaction auth options to use throughout this session
+}
+
+// DNSRegistrar2Raw is an auto generated low-level Go binding around an Ethereum contract.
+type DNSRegistrar2Raw struct {
+	Contract *DNSRegistrar2 // Generic contract binding to access the raw methods on
+}
+
+// DNSRegistrar2CallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type DNSRegistrar2CallerRaw struct {
+	Contract *DNSRegistrar2Caller // Generic read-only contract binding to access the raw methods on
+}
+
+// DNSRegistrar2TransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type DNSRegistrar2TransactorRaw struct {
+	Contract *DNSRegistrar2Transactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewDNSRegistrar2 creates a new instance of DNSRegistrar2, bound to a specific deployed contract.
+func NewDNSRegistrar2(address common.Address, backend bind.ContractBackend) (*DNSRegistrar2, error) {
+	contract, err := bindDNSRegistrar2(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSRegistrar2{DNSRegistrar2Caller: DNSRegistrar2Caller{contract: contract}, DNSRegistrar2Transactor: DNSRegistrar2Transactor{contract: contract}, DNSRegistrar2Filterer: DNSRegistrar2Filterer{contract: contract}}, nil
+}
+
+// NewDNSRegistrar2Caller creates a new read-only instance of DNSRegistrar2, bound to a specific deployed contract.
+func NewDNSRegistrar2Caller(address common.Address, caller bind.ContractCaller) (*DNSRegistrar2Caller, error) {
+	contract, err := bindDNSRegistrar2(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSRegistrar2Caller{contract: contract}, nil
+}
+
+// NewDNSRegistrar2Transactor creates a new write-only instance of DNSRegistrar2, bound to a specific deployed contract.
+func NewDNSRegistrar2Transactor(address common.Address, transactor bind.ContractTransactor) (*DNSRegistrar2Transactor, error) {
+	contract, err := bindDNSRegistrar2(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSRegistrar2Transactor{contract: contract}, nil
+}
+
+// NewDNSRegistrar2Filterer creates a new log filterer instance of DNSRegistrar2, bound to a specific deployed contract.
+func NewDNSRegistrar2Filterer(address common.Address, filterer bind.ContractFilterer) (*DNSRegistrar2Filterer, error) {
+	contract, err := bindDNSRegistrar2(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSRegistrar2Filterer{contract: contract}, nil
+}
+
+// bindDNSRegistrar2 binds a generic wrapper to an already deployed contract.
+func bindDNSRegistrar2(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(DNSRegistrar2ABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_DNSRegistrar2 *DNSRegistrar2Raw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _DNSRegistrar2.Contract.DNSRegistrar2Caller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_DNSRegistrar2 *DNSRegistrar2Raw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _DNSRegistrar2.Contract.DNSRegistrar2Transactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_DNSRegistrar2 *DNSRegistrar2Raw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _DNSRegistrar2.Contract.DNSRegistrar2Transactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_DNSRegistrar2 *DNSRegistrar2CallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _DNSRegistrar2.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_DNSRegistrar2 *DNSRegistrar2TransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _DNSRegistrar2.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_DNSRegistrar2 *DNSRegistrar2TransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _DNSRegistrar2.Contract.contract.Transact(opts, method, params...)
+}
+
+// Oracle is a free data retrieval call binding the contract method 0x7dc0d1d0.
+//
+// Solidity: function oracle() constant returns(address)
+func (_DNSRegistrar2 *DNSRegistrar2Caller) Oracle(opts *bind.CallOpts) (common.Address, error) {
+	var (
+		ret0 = new(common.Address)
+	)
+	out := ret0
+	err := _DNSRegistrar2.contract.Call(opts, out, "oracle")
+	return *ret0, err
+}
+
+// Oracle is a free data retrieval call binding the contract method 0x7dc0d1d0.
+//
+// Solidity: function oracle() constant returns(address)
+func (_DNSRegistrar2 *DNSRegistrar2Session) Oracle() (common.Address, error) {
+	return _DNSRegistrar2.Contract.Oracle(&_DNSRegistrar2.CallOpts)
+}
+
+// Oracle is a free data retrieval call binding the contract method 0x7dc0d1d0.
+//
+// Solidity: function oracle() constant returns(address)
+func (_DNSRegistrar2 *DNSRegistrar2CallerSession) Oracle() (common.Address, error) {
+	return _DNSRegistrar2.Contract.Oracle(&_DNSRegistrar2.CallOpts)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_DNSRegistrar2 *DNSRegistrar2Caller) SupportsInterface(opts *bind.CallOpts, interfaceID [4]byte) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _DNSRegistrar2.contract.Call(opts, out, "supportsInterface", interfaceID)
+	return *ret0, err
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_DNSRegistrar2 *DNSRegistrar2Session) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _DNSRegistrar2.Contract.SupportsInterface(&_DNSRegistrar2.CallOpts, interfaceID)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_DNSRegistrar2 *DNSRegistrar2CallerSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _DNSRegistrar2.Contract.SupportsInterface(&_DNSRegistrar2.CallOpts, interfaceID)
+}
+
+// ProveAndClaim is a paid mutator transaction binding the contract method 0x29d56630.
+//
+// Solidity: function proveAndClaim(bytes name, []DNSSECImplRRSetWithSignature input) returns()
+func (_DNSRegistrar2 *DNSRegistrar2Transactor) ProveAndClaim(opts *bind.TransactOpts, name []byte, input []DNSSECImplRRSetWithSignature) (*types.Transaction, error) {
+	return _DNSRegistrar2.contract.Transact(opts, "proveAndClaim", name, input)
+}
+
+// ProveAndClaim is a paid mutator transaction binding the contract method 0x29d56630.
+//
+// Solidity: function proveAndClaim(bytes name, []DNSSECImplRRSetWithSignature input) returns()
+func (_DNSRegistrar2 *DNSRegistrar2Session) ProveAndClaim(name []byte, input []DNSSECImplRRSetWithSignature) (*types.Transaction, error) {
+	return _DNSRegistrar2.Contract.ProveAndClaim(&_DNSRegistrar2.TransactOpts, name, input)
+}
+
+// ProveAndClaim is a paid mutator transaction binding the contract method 0x29d56630.
+//
+// Solidity: function proveAndClaim(bytes name, []DNSSECImplRRSetWithSignature input) returns()
+func (_DNSRegistrar2 *DNSRegistrar2TransactorSession) ProveAndClaim(name []byte, input []DNSSECImplRRSetWithSignature) (*types.Transaction, error) {
+	return _DNSRegistrar2.Contract.ProveAndClaim(&_DNSRegistrar2.TransactOpts, name, input)
+}
+
+// ProveAndClaimWithResolver is a paid mutator transaction binding the contract method 0x06963218.
+//
+// Solidity: function proveAndClaimWithResolver(bytes name, []DNSSECImplRRSetWithSignature input, address resolver, address addr) returns()
+func (_DNSRegistrar2 *DNSRegistrar2Transactor) ProveAndClaimWithResolver(opts *bind.TransactOpts, name []byte, input []DNSSECImplRRSetWithSignature, resolver common.Address, addr common.Address) (*types.Transaction, error) {
+	return _DNSRegistrar2.contract.Transact(opts, "proveAndClaimWithResolver", name, input, resolver, addr)
+}
+
+// ProveAndClaimWithResolver is a paid mutator transaction binding the contract method 0x06963218.
+//
+// Solidity: function proveAndClaimWithResolver(bytes name, []DNSSECImplRRSetWithSignature input, address resolver, address addr) returns()
+func (_DNSRegistrar2 *DNSRegistrar2Session) ProveAndClaimWithResolver(name []byte, input []DNSSECImplRRSetWithSignature, resolver common.Address, addr common.Address) (*types.Transaction, error) {
+	return _DNSRegistrar2.Contract.ProveAndClaimWithResolver(&_DNSRegistrar2.TransactOpts, name, input, resolver, addr)
+}
+
+// ProveAndClaimWithResolver is a paid mutator transaction binding the contract method 0x06963218.
+//
+// Solidity: function proveAndClaimWithResolver(bytes name, []DNSSECImplRRSetWithSignature input, address resolver, address addr) returns()
+func (_DNSRegistrar2 *DNSRegistrar2TransactorSession) ProveAndClaimWithResolver(name []byte, input []DNSSECImplRRSetWithSignature, resolver common.Address, addr common.Address) (*types.Transaction, error) {
+	return _DNSRegistrar2.Contract.ProveAndClaimWithResolver(&_DNSRegistrar2.TransactOpts, name, input, resolver, addr)
+}
+
+// DNSRegistrar2ClaimIterator is returned from FilterClaim and is used to iterate over the raw logs and unpacked data for Claim events raised by the DNSRegistrar2 contract.
+type DNSRegistrar2ClaimIterator struct {
+	Event *DNSRegistrar2Claim // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *DNSRegistrar2ClaimIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(DNSRegistrar2Claim)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(DNSRegistrar2Claim)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *DNSRegistrar2ClaimIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *DNSRegistrar2ClaimIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// DNSRegistrar2Claim represents a Claim event raised by the DNSRegistrar2 contract.
+type DNSRegistrar2Claim struct {
+	Node      [32]byte
+	Owner     common.Address
+	Dnsname   []byte
+	Inception uint32
+	Raw       types.Log // Blockchain specific contextual infos
+}
+
+// FilterClaim is a free log retrieval operation binding the contract event 0x87db02a0e483e2818060eddcbb3488ce44e35aff49a70d92c2aa6c8046cf01e2.
+//
+// Solidity: event Claim(bytes32 indexed node, address indexed owner, bytes dnsname, uint32 inception)
+func (_DNSRegistrar2 *DNSRegistrar2Filterer) FilterClaim(opts *bind.FilterOpts, node [][32]byte, owner []common.Address) (*DNSRegistrar2ClaimIterator, error) {
+
+	var nodeRule []interface{}
+	for _, nodeItem := range node {
+		nodeRule = append(nodeRule, nodeItem)
+	}
+	var ownerRule []interface{}
+	for _, ownerItem := range owner {
+		ownerRule = append(ownerRule, ownerItem)
+	}
+
+	logs, sub, err := _DNSRegistrar2.contract.FilterLogs(opts, "Claim", nodeRule, ownerRule)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSRegistrar2ClaimIterator{contract: _DNSRegistrar2.contract, event: "Claim", logs: logs, sub: sub}, nil
+}
+
+// WatchClaim is a free log subscription operation binding the contract event 0x87db02a0e483e2818060eddcbb3488ce44e35aff49a70d92c2aa6c8046cf01e2.
+//
+// Solidity: event Claim(bytes32 indexed node, address indexed owner, bytes dnsname, uint32 inception)
+func (_DNSRegistrar2 *DNSRegistrar2Filterer) WatchClaim(opts *bind.WatchOpts, sink chan<- *DNSRegistrar2Claim, node [][32]byte, owner []common.Address) (event.Subscription, error) {
+
+	var nodeRule []interface{}
+	for _, nodeItem := range node {
+		nodeRule = append(nodeRule, nodeItem)
+	}
+	var ownerRule []interface{}
+	for _, ownerItem := range owner {
+		ownerRule = append(ownerRule, ownerItem)
+	}
+
+	logs, sub, err := _DNSRegistrar2.contract.WatchLogs(opts, "Claim", nodeRule, ownerRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(DNSRegistrar2Claim)
+				if err := _DNSRegistrar2.contract.UnpackLog(event, "Claim", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
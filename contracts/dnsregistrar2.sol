@@ -0,0 +1,12 @@
+pragma experimental ABIEncoderV2;
+
+import "./dnssecimpl.sol";
+
+contract DNSRegistrar2 {
+    event Claim(bytes32 indexed node, address indexed owner, bytes dnsname, uint32 inception);
+
+    function oracle() external view returns(address);
+    function proveAndClaim(bytes calldata name, DNSSECImpl.RRSetWithSignature[] calldata input) external;
+    function proveAndClaimWithResolver(bytes calldata name, DNSSECImpl.RRSetWithSignature[] calldata input, address resolver, address addr) external;
+    function supportsInterface(bytes4 interfaceID) external pure returns (bool);
+}
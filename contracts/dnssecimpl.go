@@ -0,0 +1,549 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package contracts
+
+import (
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = abi.U256
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// DNSSECImplRRSetWithSignature is an auto generated low-level Go binding around an user-defined struct.
+type DNSSECImplRRSetWithSignature struct {
+	Rrset []byte
+	Sig   []byte
+}
+
+// DNSSECImplABI is the input ABI used to generate the binding from.
+const DNSSECImplABI = "[{\"type\":\"function\",\"name\":\"anchors\",\"stateMutability\":\"view\",\"constant\":true,\"payable\":false,\"inputs\":[],\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}]},{\"type\":\"function\",\"name\":\"verifyRRSet\",\"stateMutability\":\"view\",\"constant\":true,\"payable\":false,\"inputs\":[{\"name\":\"input\",\"type\":\"tuple[]\",\"internalType\":\"structDNSSECImpl.RRSetWithSignature[]\",\"components\":[{\"name\":\"rrset\",\"type\":\"bytes\"},{\"name\":\"sig\",\"type\":\"bytes\"}]}],\"outputs\":[{\"name\":\"rrs\",\"type\":\"bytes\"},{\"name\":\"inception\",\"type\":\"uint32\"}]},{\"type\":\"function\",\"name\":\"verifyRRSet\",\"stateMutability\":\"view\",\"constant\":true,\"payable\":false,\"inputs\":[{\"name\":\"input\",\"type\":\"tuple[]\",\"internalType\":\"structDNSSECImpl.RRSetWithSignature[]\",\"components\":[{\"name\":\"rrset\",\"type\":\"bytes\"},{\"name\":\"sig\",\"type\":\"bytes\"}]},{\"name\":\"now\",\"type\":\"uint256\"}],\"outputs\":[{\"name\":\"rrs\",\"type\":\"bytes\"},{\"name\":\"inception\",\"type\":\"uint32\"}]},{\"type\":\"function\",\"name\":\"supportsInterface\",\"stateMutability\":\"pure\",\"constant\":true,\"payable\":false,\"inputs\":[{\"name\":\"interfaceID\",\"type\":\"bytes4\"}],\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}]},{\"type\":\"event\",\"name\":\"AlgorithmUpdated\",\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"id\",\"type\":\"uint8\"},{\"indexed\":false,\"name\":\"addr\",\"type\":\"address\"}]},{\"type\":\"event\",\"name\":\"DigestUpdated\",\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"name\":\"id\",\"type\":\"uint8\"},{\"indexed\":false,\"name\":\"addr\",\"type\":\"address\"}]}]"
+
+// DNSSECImpl is an auto generated Go binding around an Ethereum contract.
+type DNSSECImpl struct {
+	DNSSECImplCaller     // Read-only binding to the contract
+	DNSSECImplTransactor // Write-only binding to the contract
+	DNSSECImplFilterer   // Log filterer for contract events
+}
+
+// DNSSECImplCaller is an auto generated read-only Go binding around an Ethereum contract.
+type DNSSECImplCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DNSSECImplTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type DNSSECImplTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DNSSECImplFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type DNSSECImplFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DNSSECImplSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type DNSSECImplSession struct {
+	Contract     *DNSSECImpl       // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// DNSSECImplCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type DNSSECImplCallerSession struct {
+	Contract *DNSSECImplCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts     // Call options to use throughout this session
+}
+
+// DNSSECImplTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type DNSSECImplTransactorSession struct {
+	Contract     *DNSSECImplTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts     // Transaction auth options to use throughout this session
+}
+
+// DNSSECImplRaw is an auto generated low-level Go binding around an Ethereum contract.
+type DNSSECImplRaw struct {
+	Contract *DNSSECImpl // Generic contract binding to access the raw methods on
+}
+
+// DNSSECImplCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type DNSSECImplCallerRaw struct {
+	Contract *DNSSECImplCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// DNSSECImplTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type DNSSECImplTransactorRaw struct {
+	Contract *DNSSECImplTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewDNSSECImpl creates a new instance of DNSSECImpl, bound to a specific deployed contract.
+func NewDNSSECImpl(address common.Address, backend bind.ContractBackend) (*DNSSECImpl, error) {
+	contract, err := bindDNSSECImpl(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSSECImpl{DNSSECImplCaller: DNSSECImplCaller{contract: contract}, DNSSECImplTransactor: DNSSECImplTransactor{contract: contract}, DNSSECImplFilterer: DNSSECImplFilterer{contract: contract}}, nil
+}
+
+// NewDNSSECImplCaller creates a new read-only instance of DNSSECImpl, bound to a specific deployed contract.
+func NewDNSSECImplCaller(address common.Address, caller bind.ContractCaller) (*DNSSECImplCaller, error) {
+	contract, err := bindDNSSECImpl(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSSECImplCaller{contract: contract}, nil
+}
+
+// NewDNSSECImplTransactor creates a new write-only instance of DNSSECImpl, bound to a specific deployed contract.
+func NewDNSSECImplTransactor(address common.Address, transactor bind.ContractTransactor) (*DNSSECImplTransactor, error) {
+	contract, err := bindDNSSECImpl(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSSECImplTransactor{contract: contract}, nil
+}
+
+// NewDNSSECImplFilterer creates a new log filterer instance of DNSSECImpl, bound to a specific deployed contract.
+func NewDNSSECImplFilterer(address common.Address, filterer bind.ContractFilterer) (*DNSSECImplFilterer, error) {
+	contract, err := bindDNSSECImpl(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSSECImplFilterer{contract: contract}, nil
+}
+
+// bindDNSSECImpl binds a generic wrapper to an already deployed contract.
+func bindDNSSECImpl(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(DNSSECImplABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_DNSSECImpl *DNSSECImplRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _DNSSECImpl.Contract.DNSSECImplCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_DNSSECImpl *DNSSECImplRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _DNSSECImpl.Contract.DNSSECImplTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_DNSSECImpl *DNSSECImplRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _DNSSECImpl.Contract.DNSSECImplTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_DNSSECImpl *DNSSECImplCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _DNSSECImpl.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_DNSSECImpl *DNSSECImplTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _DNSSECImpl.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_DNSSECImpl *DNSSECImplTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _DNSSECImpl.Contract.contract.Transact(opts, method, params...)
+}
+
+// Anchors is a free data retrieval call binding the contract method 0x98d35f20.
+//
+// Solidity: function anchors() constant returns(bytes)
+func (_DNSSECImpl *DNSSECImplCaller) Anchors(opts *bind.CallOpts) ([]byte, error) {
+	var (
+		ret0 = new([]byte)
+	)
+	out := ret0
+	err := _DNSSECImpl.contract.Call(opts, out, "anchors")
+	return *ret0, err
+}
+
+// Anchors is a free data retrieval call binding the contract method 0x98d35f20.
+//
+// Solidity: function anchors() constant returns(bytes)
+func (_DNSSECImpl *DNSSECImplSession) Anchors() ([]byte, error) {
+	return _DNSSECImpl.Contract.Anchors(&_DNSSECImpl.CallOpts)
+}
+
+// Anchors is a free data retrieval call binding the contract method 0x98d35f20.
+//
+// Solidity: function anchors() constant returns(bytes)
+func (_DNSSECImpl *DNSSECImplCallerSession) Anchors() ([]byte, error) {
+	return _DNSSECImpl.Contract.Anchors(&_DNSSECImpl.CallOpts)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_DNSSECImpl *DNSSECImplCaller) SupportsInterface(opts *bind.CallOpts, interfaceID [4]byte) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _DNSSECImpl.contract.Call(opts, out, "supportsInterface", interfaceID)
+	return *ret0, err
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_DNSSECImpl *DNSSECImplSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _DNSSECImpl.Contract.SupportsInterface(&_DNSSECImpl.CallOpts, interfaceID)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_DNSSECImpl *DNSSECImplCallerSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _DNSSECImpl.Contract.SupportsInterface(&_DNSSECImpl.CallOpts, interfaceID)
+}
+
+// VerifyRRSet is a free data retrieval call binding the contract method 0xbdf95fef.
+//
+// Solidity: function verifyRRSet([]DNSSECImplRRSetWithSignature input) constant returns(bytes rrs, uint32 inception)
+func (_DNSSECImpl *DNSSECImplCaller) VerifyRRSet(opts *bind.CallOpts, input []DNSSECImplRRSetWithSignature) (struct {
+	Rrs       []byte
+	Inception uint32
+}, error) {
+	ret := new(struct {
+		Rrs       []byte
+		Inception uint32
+	})
+	out := ret
+	err := _DNSSECImpl.contract.Call(opts, out, "verifyRRSet", input)
+	return *ret, err
+}
+
+// VerifyRRSet is a free data retrieval call binding the contract method 0xbdf95fef.
+//
+// Solidity: function verifyRRSet([]DNSSECImplRRSetWithSignature input) constant returns(bytes rrs, uint32 inception)
+func (_DNSSECImpl *DNSSECImplSession) VerifyRRSet(input []DNSSECImplRRSetWithSignature) (struct {
+	Rrs       []byte
+	Inception uint32
+}, error) {
+	return _DNSSECImpl.Contract.VerifyRRSet(&_DNSSECImpl.CallOpts, input)
+}
+
+// VerifyRRSet is a free data retrieval call binding the contract method 0xbdf95fef.
+//
+// Solidity: function verifyRRSet([]DNSSECImplRRSetWithSignature input) constant returns(bytes rrs, uint32 inception)
+func (_DNSSECImpl *DNSSECImplCallerSession) VerifyRRSet(input []DNSSECImplRRSetWithSignature) (struct {
+	Rrs       []byte
+	Inception uint32
+}, error) {
+	return _DNSSECImpl.Contract.VerifyRRSet(&_DNSSECImpl.CallOpts, input)
+}
+
+// VerifyRRSet0 is a free data retrieval call binding the contract method 0x440f3d42.
+//
+// Solidity: function verifyRRSet([]DNSSECImplRRSetWithSignature input, uint256 now) constant returns(bytes rrs, uint32 inception)
+func (_DNSSECImpl *DNSSECImplCaller) VerifyRRSet0(opts *bind.CallOpts, input []DNSSECImplRRSetWithSignature, now *big.Int) (struct {
+	Rrs       []byte
+	Inception uint32
+}, error) {
+	ret := new(struct {
+		Rrs       []byte
+		Inception uint32
+	})
+	out := ret
+	err := _DNSSECImpl.contract.Call(opts, out, "verifyRRSet0", input, now)
+	return *ret, err
+}
+
+// VerifyRRSet0 is a free data retrieval call binding the contract method 0x440f3d42.
+//
+// Solidity: function verifyRRSet([]DNSSECImplRRSetWithSignature input, uint256 now) constant returns(bytes rrs, uint32 inception)
+func (_DNSSECImpl *DNSSECImplSession) VerifyRRSet0(input []DNSSECImplRRSetWithSignature, now *big.Int) (struct {
+	Rrs       []byte
+	Inception uint32
+}, error) {
+	return _DNSSECImpl.Contract.VerifyRRSet0(&_DNSSECImpl.CallOpts, input, now)
+}
+
+// VerifyRRSet0 is a free data retrieval call binding the contract method 0x440f3d42.
+//
+// Solidity: function verifyRRSet([]DNSSECImplRRSetWithSignature input, uint256 now) constant returns(bytes rrs, uint32 inception)
+func (_DNSSECImpl *DNSSECImplCallerSession) VerifyRRSet0(input []DNSSECImplRRSetWithSignature, now *big.Int) (struct {
+	Rrs       []byte
+	Inception uint32
+}, error) {
+	return _DNSSECImpl.Contract.VerifyRRSet0(&_DNSSECImpl.CallOpts, input, now)
+}
+
+// DNSSECImplAlgorithmUpdatedIterator is returned from FilterAlgorithmUpdated and is used to iterate over the raw logs and unpacked data for AlgorithmUpdated events raised by the DNSSECImpl contract.
+type DNSSECImplAlgorithmUpdatedIterator struct {
+	Event *DNSSECImplAlgorithmUpdated // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *DNSSECImplAlgorithmUpdatedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(DNSSECImplAlgorithmUpdated)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(DNSSECImplAlgorithmUpdated)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *DNSSECImplAlgorithmUpdatedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *DNSSECImplAlgorithmUpdatedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// DNSSECImplAlgorithmUpdated represents a AlgorithmUpdated event raised by the DNSSECImpl contract.
+type DNSSECImplAlgorithmUpdated struct {
+	Id   uint8
+	Addr common.Address
+	Raw  types.Log // Blockchain specific contextual infos
+}
+
+// FilterAlgorithmUpdated is a free log retrieval operation binding the contract event 0xf73c3c226af96b7f1ba666a21b3ceaf2be3ee6a365e3178fd9cd1eaae0075aa8.
+//
+// Solidity: event AlgorithmUpdated(uint8 id, address addr)
+func (_DNSSECImpl *DNSSECImplFilterer) FilterAlgorithmUpdated(opts *bind.FilterOpts) (*DNSSECImplAlgorithmUpdatedIterator, error) {
+
+	logs, sub, err := _DNSSECImpl.contract.FilterLogs(opts, "AlgorithmUpdated")
+	if err != nil {
+		return nil, err
+	}
+	return &DNSSECImplAlgorithmUpdatedIterator{contract: _DNSSECImpl.contract, event: "AlgorithmUpdated", logs: logs, sub: sub}, nil
+}
+
+// WatchAlgorithmUpdated is a free log subscription operation binding the contract event 0xf73c3c226af96b7f1ba666a21b3ceaf2be3ee6a365e3178fd9cd1eaae0075aa8.
+//
+// Solidity: event AlgorithmUpdated(uint8 id, address addr)
+func (_DNSSECImpl *DNSSECImplFilterer) WatchAlgorithmUpdated(opts *bind.WatchOpts, sink chan<- *DNSSECImplAlgorithmUpdated) (event.Subscription, error) {
+
+	logs, sub, err := _DNSSECImpl.contract.WatchLogs(opts, "AlgorithmUpdated")
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(DNSSECImplAlgorithmUpdated)
+				if err := _DNSSECImpl.contract.UnpackLog(event, "AlgorithmUpdated", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// DNSSECImplDigestUpdatedIterator is returned from FilterDigestUpdated and is used to iterate over the raw logs and unpacked data for DigestUpdated events raised by the DNSSECImpl contract.
+type DNSSECImplDigestUpdatedIterator struct {
+	Event *DNSSECImplDigestUpdated // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *DNSSECImplDigestUpdatedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(DNSSECImplDigestUpdated)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(DNSSECImplDigestUpdated)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *DNSSECImplDigestUpdatedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *DNSSECImplDigestUpdatedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// DNSSECImplDigestUpdated represents a DigestUpdated event raised by the DNSSECImpl contract.
+type DNSSECImplDigestUpdated struct {
+	Id   uint8
+	Addr common.Address
+	Raw  types.Log // Blockchain specific contextual infos
+}
+
+// FilterDigestUpdated is a free log retrieval operation binding the contract event 0x2fcc274c3b72dd483ab201bfa87295e3817e8b9b10693219873b722ca1af00c7.
+//
+// Solidity: event DigestUpdated(uint8 id, address addr)
+func (_DNSSECImpl *DNSSECImplFilterer) FilterDigestUpdated(opts *bind.FilterOpts) (*DNSSECImplDigestUpdatedIterator, error) {
+
+	logs, sub, err := _DNSSECImpl.contract.FilterLogs(opts, "DigestUpdated")
+	if err != nil {
+		return nil, err
+	}
+	return &DNSSECImplDigestUpdatedIterator{contract: _DNSSECImpl.contract, event: "DigestUpdated", logs: logs, sub: sub}, nil
+}
+
+// WatchDigestUpdated is a free log subscription operation binding the contract event 0x2fcc274c3b72dd483ab201bfa87295e3817e8b9b10693219873b722ca1af00c7.
+//
+// Solidity: event DigestUpdated(uint8 id, address addr)
+func (_DNSSECImpl *DNSSECImplFilterer) WatchDigestUpdated(opts *bind.WatchOpts, sink chan<- *DNSSECImplDigestUpdated) (event.Subscription, error) {
+
+	logs, sub, err := _DNSSECImpl.contract.WatchLogs(opts, "DigestUpdated")
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(DNSSECImplDigestUpdated)
+				if err := _DNSSECImpl.contract.UnpackLog(event, "DigestUpdated", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
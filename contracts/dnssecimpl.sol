@@ -0,0 +1,16 @@
+pragma experimental ABIEncoderV2;
+
+contract DNSSECImpl {
+    struct RRSetWithSignature {
+        bytes rrset;
+        bytes sig;
+    }
+
+    event AlgorithmUpdated(uint8 id, address addr);
+    event DigestUpdated(uint8 id, address addr);
+
+    function anchors() external view returns (bytes memory);
+    function verifyRRSet(RRSetWithSignature[] calldata input) external view returns (bytes memory rrs, uint32 inception);
+    function verifyRRSet(RRSetWithSignature[] calldata input, uint256 now) external view returns (bytes memory rrs, uint32 inception);
+    function supportsInterface(bytes4 interfaceID) external pure returns (bool);
+}
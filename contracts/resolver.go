@@ -28,23 +28,7 @@ var (
 )
 
 // ResolverABI is the input ABI used to generate the binding from.
-const ResolverABI = "[{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"}],\"name\":\"addr\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"}]"
-
-// ResolverBin is the compiled bytecode used for deploying new contracts.
-const ResolverBin = `0x`
-
-// DeployResolver deploys a new Ethereum contract, binding an instance of Resolver to it.
-func DeployResolver(auth *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, *Resolver, error) {
-	parsed, err := abi.JSON(strings.NewReader(ResolverABI))
-	if err != nil {
-		return common.Address{}, nil, nil, err
-	}
-	address, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex(ResolverBin), backend)
-	if err != nil {
-		return common.Address{}, nil, nil, err
-	}
-	return address, tx, &Resolver{ResolverCaller: ResolverCaller{contract: contract}, ResolverTransactor: ResolverTransactor{contract: contract}, ResolverFilterer: ResolverFilterer{contract: contract}}, nil
-}
+const ResolverABI = "[{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"}],\"name\":\"addr\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"coinType\",\"type\":\"uint256\"}],\"name\":\"addr\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"key\",\"type\":\"string\"}],\"name\":\"text\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"}],\"name\":\"contenthash\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"}],\"name\":\"pubkey\",\"outputs\":[{\"name\":\"x\",\"type\":\"bytes32\"},{\"name\":\"y\",\"type\":\"bytes32\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"contentTypes\",\"type\":\"uint256\"}],\"name\":\"ABI\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"},{\"name\":\"\",\"type\":\"bytes\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"}],\"name\":\"name\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"addr\",\"type\":\"address\"}],\"name\":\"setAddr\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"coinType\",\"type\":\"uint256\"},{\"name\":\"a\",\"type\":\"bytes\"}],\"name\":\"setAddr\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"key\",\"type\":\"string\"},{\"name\":\"value\",\"type\":\"string\"}],\"name\":\"setText\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"hash\",\"type\":\"bytes\"}],\"name\":\"setContenthash\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"x\",\"type\":\"bytes32\"},{\"name\":\"y\",\"type\":\"bytes32\"}],\"name\":\"setPubkey\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"contentType\",\"type\":\"uint256\"},{\"name\":\"data\",\"type\":\"bytes\"}],\"name\":\"setABI\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"name\",\"type\":\"string\"}],\"name\":\"setName\",\"outputs\":[],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"interfaceID\",\"type\":\"bytes4\"}],\"name\":\"supportsInterface\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"pure\",\"type\":\"function\"}]"
 
 // Resolver is an auto generated Go binding around an Ethereum contract.
 type Resolver struct {
@@ -188,6 +172,36 @@ func (_Resolver *ResolverTransactorRaw) Transact(opts *bind.TransactOpts, method
 	return _Resolver.Contract.contract.Transact(opts, method, params...)
 }
 
+// ABI is a free data retrieval call binding the contract method 0x2203ab56.
+//
+// Solidity: function ABI(bytes32 node, uint256 contentTypes) constant returns(uint256, bytes)
+func (_Resolver *ResolverCaller) ABI(opts *bind.CallOpts, node [32]byte, contentTypes *big.Int) (*big.Int, []byte, error) {
+	var (
+		ret0 = new(*big.Int)
+		ret1 = new([]byte)
+	)
+	out := &[]interface{}{
+		ret0,
+		ret1,
+	}
+	err := _Resolver.contract.Call(opts, out, "ABI", node, contentTypes)
+	return *ret0, *ret1, err
+}
+
+// ABI is a free data retrieval call binding the contract method 0x2203ab56.
+//
+// Solidity: function ABI(bytes32 node, uint256 contentTypes) constant returns(uint256, bytes)
+func (_Resolver *ResolverSession) ABI(node [32]byte, contentTypes *big.Int) (*big.Int, []byte, error) {
+	return _Resolver.Contract.ABI(&_Resolver.CallOpts, node, contentTypes)
+}
+
+// ABI is a free data retrieval call binding the contract method 0x2203ab56.
+//
+// Solidity: function ABI(bytes32 node, uint256 contentTypes) constant returns(uint256, bytes)
+func (_Resolver *ResolverCallerSession) ABI(node [32]byte, contentTypes *big.Int) (*big.Int, []byte, error) {
+	return _Resolver.Contract.ABI(&_Resolver.CallOpts, node, contentTypes)
+}
+
 // Addr is a free data retrieval call binding the contract method 0x3b3b57de.
 //
 // Solidity: function addr(bytes32 node) constant returns(address)
@@ -213,3 +227,316 @@ func (_Resolver *ResolverSession) Addr(node [32]byte) (common.Address, error) {
 func (_Resolver *ResolverCallerSession) Addr(node [32]byte) (common.Address, error) {
 	return _Resolver.Contract.Addr(&_Resolver.CallOpts, node)
 }
+
+// Addr0 is a free data retrieval call binding the contract method 0xf1cb7e06.
+//
+// Solidity: function addr(bytes32 node, uint256 coinType) constant returns(bytes)
+func (_Resolver *ResolverCaller) Addr0(opts *bind.CallOpts, node [32]byte, coinType *big.Int) ([]byte, error) {
+	var (
+		ret0 = new([]byte)
+	)
+	out := ret0
+	err := _Resolver.contract.Call(opts, out, "addr0", node, coinType)
+	return *ret0, err
+}
+
+// Addr0 is a free data retrieval call binding the contract method 0xf1cb7e06.
+//
+// Solidity: function addr(bytes32 node, uint256 coinType) constant returns(bytes)
+func (_Resolver *ResolverSession) Addr0(node [32]byte, coinType *big.Int) ([]byte, error) {
+	return _Resolver.Contract.Addr0(&_Resolver.CallOpts, node, coinType)
+}
+
+// Addr0 is a free data retrieval call binding the contract method 0xf1cb7e06.
+//
+// Solidity: function addr(bytes32 node, uint256 coinType) constant returns(bytes)
+func (_Resolver *ResolverCallerSession) Addr0(node [32]byte, coinType *big.Int) ([]byte, error) {
+	return _Resolver.Contract.Addr0(&_Resolver.CallOpts, node, coinType)
+}
+
+// Contenthash is a free data retrieval call binding the contract method 0xbc1c58d1.
+//
+// Solidity: function contenthash(bytes32 node) constant returns(bytes)
+func (_Resolver *ResolverCaller) Contenthash(opts *bind.CallOpts, node [32]byte) ([]byte, error) {
+	var (
+		ret0 = new([]byte)
+	)
+	out := ret0
+	err := _Resolver.contract.Call(opts, out, "contenthash", node)
+	return *ret0, err
+}
+
+// Contenthash is a free data retrieval call binding the contract method 0xbc1c58d1.
+//
+// Solidity: function contenthash(bytes32 node) constant returns(bytes)
+func (_Resolver *ResolverSession) Contenthash(node [32]byte) ([]byte, error) {
+	return _Resolver.Contract.Contenthash(&_Resolver.CallOpts, node)
+}
+
+// Contenthash is a free data retrieval call binding the contract method 0xbc1c58d1.
+//
+// Solidity: function contenthash(bytes32 node) constant returns(bytes)
+func (_Resolver *ResolverCallerSession) Contenthash(node [32]byte) ([]byte, error) {
+	return _Resolver.Contract.Contenthash(&_Resolver.CallOpts, node)
+}
+
+// Name is a free data retrieval call binding the contract method 0x691f3431.
+//
+// Solidity: function name(bytes32 node) constant returns(string)
+func (_Resolver *ResolverCaller) Name(opts *bind.CallOpts, node [32]byte) (string, error) {
+	var (
+		ret0 = new(string)
+	)
+	out := ret0
+	err := _Resolver.contract.Call(opts, out, "name", node)
+	return *ret0, err
+}
+
+// Name is a free data retrieval call binding the contract method 0x691f3431.
+//
+// Solidity: function name(bytes32 node) constant returns(string)
+func (_Resolver *ResolverSession) Name(node [32]byte) (string, error) {
+	return _Resolver.Contract.Name(&_Resolver.CallOpts, node)
+}
+
+// Name is a free data retrieval call binding the contract method 0x691f3431.
+//
+// Solidity: function name(bytes32 node) constant returns(string)
+func (_Resolver *ResolverCallerSession) Name(node [32]byte) (string, error) {
+	return _Resolver.Contract.Name(&_Resolver.CallOpts, node)
+}
+
+// Pubkey is a free data retrieval call binding the contract method 0xc8690233.
+//
+// Solidity: function pubkey(bytes32 node) constant returns(bytes32 x, bytes32 y)
+func (_Resolver *ResolverCaller) Pubkey(opts *bind.CallOpts, node [32]byte) (struct {
+	X [32]byte
+	Y [32]byte
+}, error) {
+	ret := new(struct {
+		X [32]byte
+		Y [32]byte
+	})
+	out := ret
+	err := _Resolver.contract.Call(opts, out, "pubkey", node)
+	return *ret, err
+}
+
+// Pubkey is a free data retrieval call binding the contract method 0xc8690233.
+//
+// Solidity: function pubkey(bytes32 node) constant returns(bytes32 x, bytes32 y)
+func (_Resolver *ResolverSession) Pubkey(node [32]byte) (struct {
+	X [32]byte
+	Y [32]byte
+}, error) {
+	return _Resolver.Contract.Pubkey(&_Resolver.CallOpts, node)
+}
+
+// Pubkey is a free data retrieval call binding the contract method 0xc8690233.
+//
+// Solidity: function pubkey(bytes32 node) constant returns(bytes32 x, bytes32 y)
+func (_Resolver *ResolverCallerSession) Pubkey(node [32]byte) (struct {
+	X [32]byte
+	Y [32]byte
+}, error) {
+	return _Resolver.Contract.Pubkey(&_Resolver.CallOpts, node)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_Resolver *ResolverCaller) SupportsInterface(opts *bind.CallOpts, interfaceID [4]byte) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _Resolver.contract.Call(opts, out, "supportsInterface", interfaceID)
+	return *ret0, err
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_Resolver *ResolverSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _Resolver.Contract.SupportsInterface(&_Resolver.CallOpts, interfaceID)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(bytes4 interfaceID) constant returns(bool)
+func (_Resolver *ResolverCallerSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _Resolver.Contract.SupportsInterface(&_Resolver.CallOpts, interfaceID)
+}
+
+// Text is a free data retrieval call binding the contract method 0x59d1d43c.
+//
+// Solidity: function text(bytes32 node, string key) constant returns(string)
+func (_Resolver *ResolverCaller) Text(opts *bind.CallOpts, node [32]byte, key string) (string, error) {
+	var (
+		ret0 = new(string)
+	)
+	out := ret0
+	err := _Resolver.contract.Call(opts, out, "text", node, key)
+	return *ret0, err
+}
+
+// Text is a free data retrieval call binding the contract method 0x59d1d43c.
+//
+// Solidity: function text(bytes32 node, string key) constant returns(string)
+func (_Resolver *ResolverSession) Text(node [32]byte, key string) (string, error) {
+	return _Resolver.Contract.Text(&_Resolver.CallOpts, node, key)
+}
+
+// Text is a free data retrieval call binding the contract method 0x59d1d43c.
+//
+// Solidity: function text(bytes32 node, string key) constant returns(string)
+func (_Resolver *ResolverCallerSession) Text(node [32]byte, key string) (string, error) {
+	return _Resolver.Contract.Text(&_Resolver.CallOpts, node, key)
+}
+
+// SetABI is a paid mutator transaction binding the contract method 0x623195b0.
+//
+// Solidity: function setABI(bytes32 node, uint256 contentType, bytes data) returns()
+func (_Resolver *ResolverTransactor) SetABI(opts *bind.TransactOpts, node [32]byte, contentType *big.Int, data []byte) (*types.Transaction, error) {
+	return _Resolver.contract.Transact(opts, "setABI", node, contentType, data)
+}
+
+// SetABI is a paid mutator transaction binding the contract method 0x623195b0.
+//
+// Solidity: function setABI(bytes32 node, uint256 contentType, bytes data) returns()
+func (_Resolver *ResolverSession) SetABI(node [32]byte, contentType *big.Int, data []byte) (*types.Transaction, error) {
+	return _Resolver.Contract.SetABI(&_Resolver.TransactOpts, node, contentType, data)
+}
+
+// SetABI is a paid mutator transaction binding the contract method 0x623195b0.
+//
+// Solidity: function setABI(bytes32 node, uint256 contentType, bytes data) returns()
+func (_Resolver *ResolverTransactorSession) SetABI(node [32]byte, contentType *big.Int, data []byte) (*types.Transaction, error) {
+	return _Resolver.Contract.SetABI(&_Resolver.TransactOpts, node, contentType, data)
+}
+
+// SetAddr is a paid mutator transaction binding the contract method 0xd5fa2b00.
+//
+// Solidity: function setAddr(bytes32 node, address addr) returns()
+func (_Resolver *ResolverTransactor) SetAddr(opts *bind.TransactOpts, node [32]byte, addr common.Address) (*types.Transaction, error) {
+	return _Resolver.contract.Transact(opts, "setAddr", node, addr)
+}
+
+// SetAddr is a paid mutator transaction binding the contract method 0xd5fa2b00.
+//
+// Solidity: function setAddr(bytes32 node, address addr) returns()
+func (_Resolver *ResolverSession) SetAddr(node [32]byte, addr common.Address) (*types.Transaction, error) {
+	return _Resolver.Contract.SetAddr(&_Resolver.TransactOpts, node, addr)
+}
+
+// SetAddr is a paid mutator transaction binding the contract method 0xd5fa2b00.
+//
+// Solidity: function setAddr(bytes32 node, address addr) returns()
+func (_Resolver *ResolverTransactorSession) SetAddr(node [32]byte, addr common.Address) (*types.Transaction, error) {
+	return _Resolver.Contract.SetAddr(&_Resolver.TransactOpts, node, addr)
+}
+
+// SetAddr0 is a paid mutator transaction binding the contract method 0x8b95dd71.
+//
+// Solidity: function setAddr(bytes32 node, uint256 coinType, bytes a) returns()
+func (_Resolver *ResolverTransactor) SetAddr0(opts *bind.TransactOpts, node [32]byte, coinType *big.Int, a []byte) (*types.Transaction, error) {
+	return _Resolver.contract.Transact(opts, "setAddr0", node, coinType, a)
+}
+
+// SetAddr0 is a paid mutator transaction binding the contract method 0x8b95dd71.
+//
+// Solidity: function setAddr(bytes32 node, uint256 coinType, bytes a) returns()
+func (_Resolver *ResolverSession) SetAddr0(node [32]byte, coinType *big.Int, a []byte) (*types.Transaction, error) {
+	return _Resolver.Contract.SetAddr0(&_Resolver.TransactOpts, node, coinType, a)
+}
+
+// SetAddr0 is a paid mutator transaction binding the contract method 0x8b95dd71.
+//
+// Solidity: function setAddr(bytes32 node, uint256 coinType, bytes a) returns()
+func (_Resolver *ResolverTransactorSession) SetAddr0(node [32]byte, coinType *big.Int, a []byte) (*types.Transaction, error) {
+	return _Resolver.Contract.SetAddr0(&_Resolver.TransactOpts, node, coinType, a)
+}
+
+// SetContenthash is a paid mutator transaction binding the contract method 0x304e6ade.
+//
+// Solidity: function setContenthash(bytes32 node, bytes hash) returns()
+func (_Resolver *ResolverTransactor) SetContenthash(opts *bind.TransactOpts, node [32]byte, hash []byte) (*types.Transaction, error) {
+	return _Resolver.contract.Transact(opts, "setContenthash", node, hash)
+}
+
+// SetContenthash is a paid mutator transaction binding the contract method 0x304e6ade.
+//
+// Solidity: function setContenthash(bytes32 node, bytes hash) returns()
+func (_Resolver *ResolverSession) SetContenthash(node [32]byte, hash []byte) (*types.Transaction, error) {
+	return _Resolver.Contract.SetContenthash(&_Resolver.TransactOpts, node, hash)
+}
+
+// SetContenthash is a paid mutator transaction binding the contract method 0x304e6ade.
+//
+// Solidity: function setContenthash(bytes32 node, bytes hash) returns()
+func (_Resolver *ResolverTransactorSession) SetContenthash(node [32]byte, hash []byte) (*types.Transaction, error) {
+	return _Resolver.Contract.SetContenthash(&_Resolver.TransactOpts, node, hash)
+}
+
+// SetName is a paid mutator transaction binding the contract method 0x77372213.
+//
+// Solidity: function setName(bytes32 node, string name) returns()
+func (_Resolver *ResolverTransactor) SetName(opts *bind.TransactOpts, node [32]byte, name string) (*types.Transaction, error) {
+	return _Resolver.contract.Transact(opts, "setName", node, name)
+}
+
+// SetName is a paid mutator transaction binding the contract method 0x77372213.
+//
+// Solidity: function setName(bytes32 node, string name) returns()
+func (_Resolver *ResolverSession) SetName(node [32]byte, name string) (*types.Transaction, error) {
+	return _Resolver.Contract.SetName(&_Resolver.TransactOpts, node, name)
+}
+
+// SetName is a paid mutator transaction binding the contract method 0x77372213.
+//
+// Solidity: function setName(bytes32 node, string name) returns()
+func (_Resolver *ResolverTransactorSession) SetName(node [32]byte, name string) (*types.Transaction, error) {
+	return _Resolver.Contract.SetName(&_Resolver.TransactOpts, node, name)
+}
+
+// SetPubkey is a paid mutator transaction binding the contract method 0x29cd62ea.
+//
+// Solidity: function setPubkey(bytes32 node, bytes32 x, bytes32 y) returns()
+func (_Resolver *ResolverTransactor) SetPubkey(opts *bind.TransactOpts, node [32]byte, x [32]byte, y [32]byte) (*types.Transaction, error) {
+	return _Resolver.contract.Transact(opts, "setPubkey", node, x, y)
+}
+
+// SetPubkey is a paid mutator transaction binding the contract method 0x29cd62ea.
+//
+// Solidity: function setPubkey(bytes32 node, bytes32 x, bytes32 y) returns()
+func (_Resolver *ResolverSession) SetPubkey(node [32]byte, x [32]byte, y [32]byte) (*types.Transaction, error) {
+	return _Resolver.Contract.SetPubkey(&_Resolver.TransactOpts, node, x, y)
+}
+
+// SetPubkey is a paid mutator transaction binding the contract method 0x29cd62ea.
+//
+// Solidity: function setPubkey(bytes32 node, bytes32 x, bytes32 y) returns()
+func (_Resolver *ResolverTransactorSession) SetPubkey(node [32]byte, x [32]byte, y [32]byte) (*types.Transaction, error) {
+	return _Resolver.Contract.SetPubkey(&_Resolver.TransactOpts, node, x, y)
+}
+
+// SetText is a paid mutator transaction binding the contract method 0x10f13a8c.
+//
+// Solidity: function setText(bytes32 node, string key, string value) returns()
+func (_Resolver *ResolverTransactor) SetText(opts *bind.TransactOpts, node [32]byte, key string, value string) (*types.Transaction, error) {
+	return _Resolver.contract.Transact(opts, "setText", node, key, value)
+}
+
+// SetText is a paid mutator transaction binding the contract method 0x10f13a8c.
+//
+// Solidity: function setText(bytes32 node, string key, string value) returns()
+func (_Resolver *ResolverSession) SetText(node [32]byte, key string, value string) (*types.Transaction, error) {
+	return _Resolver.Contract.SetText(&_Resolver.TransactOpts, node, key, value)
+}
+
+// SetText is a paid mutator transaction binding the contract method 0x10f13a8c.
+//
+// Solidity: function setText(bytes32 node, string key, string value) returns()
+func (_Resolver *ResolverTransactorSession) SetText(node [32]byte, key string, value string) (*types.Transaction, error) {
+	return _Resolver.Contract.SetText(&_Resolver.TransactOpts, node, key, value)
+}
@@ -1,4 +1,19 @@
 contract Resolver {
     function addr(bytes32 node) external view returns(address);
+    function addr(bytes32 node, uint256 coinType) external view returns(bytes memory);
+    function text(bytes32 node, string calldata key) external view returns(string memory);
+    function contenthash(bytes32 node) external view returns(bytes memory);
+    function pubkey(bytes32 node) external view returns(bytes32 x, bytes32 y);
+    function ABI(bytes32 node, uint256 contentTypes) external view returns(uint256, bytes memory);
+    function name(bytes32 node) external view returns(string memory);
+
+    function setAddr(bytes32 node, address addr) external;
+    function setAddr(bytes32 node, uint256 coinType, bytes calldata a) external;
+    function setText(bytes32 node, string calldata key, string calldata value) external;
+    function setContenthash(bytes32 node, bytes calldata hash) external;
+    function setPubkey(bytes32 node, bytes32 x, bytes32 y) external;
+    function setABI(bytes32 node, uint256 contentType, bytes calldata data) external;
+    function setName(bytes32 node, string calldata name) external;
+
     function supportsInterface(bytes4 interfaceID) external pure returns (bool);
 }
@@ -17,6 +17,8 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/arachnid/dnsprove/ens"
 	"github.com/arachnid/dnsprove/oracle"
@@ -26,33 +28,114 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	log "github.com/inconshreveable/log15"
 	"github.com/miekg/dns"
-	prompt "github.com/segmentio/go-prompt"
 )
 
 var (
-	server     = flag.String("server", "https://dns.google.com/experimental", "The URL of the dns-over-https server to use")
-	hashes     = flag.String("hashes", "SHA1,SHA256", "a comma-separated list of supported hash algorithms")
-	algorithms = flag.String("algorithms", "RSASHA1,RSASHA1-NSEC3-SHA1,RSASHA256", "a comma-separated list of supported digest algorithms")
-	verbosity  = flag.Int("verbosity", 3, "logging level verbosity (0-4)")
-	rpc        = flag.String("rpc", "http://localhost:8545", "RPC path to Ethereum node")
-	keyfile    = flag.String("keyfile", "", "Path to JSON keyfile")
-	insecure   = flag.Bool("insecure", false, "Do not prompt for a password, assume the empty string")
-	gasprice   = flag.Float64("gasprice", 5.0, "Gas price, in gwei")
+	server        = flag.String("server", "https://dns.google.com/experimental", "The URL of the dns-over-https server to use, or tls://host:853 for DNS-over-TLS; give several, comma-separated, to use them as -resolver-strategy says")
+	strategy      = flag.String("resolver-strategy", strategyFailover, "How to use several servers: failover (use the first that answers), first-valid (use the first whose answer validates) or quorum (ask them all, and require -quorum of them to agree)")
+	quorum        = flag.Int("quorum", 0, "How many servers' validated answers must agree with -resolver-strategy quorum (default a majority)")
+	chainQueries  = flag.Bool("chain", true, "Ask the server to include each answer's chain of DNSKEY and DS records (RFC 7901 CHAIN), where it supports it")
+	prefetchChain = flag.Bool("prefetch", true, "Fetch the DNSKEY and DS records a proof may need in parallel before validating it")
+	hashes        = flag.String("hashes", "SHA1,SHA256", "a comma-separated list of supported hash algorithms")
+	algorithms    = flag.String("algorithms", "RSASHA1,RSASHA1-NSEC3-SHA1,RSASHA256", "a comma-separated list of supported digest algorithms")
+	verbosity     = flag.Int("verbosity", 3, "logging level verbosity (0-4)")
+	rpc           = flag.String("rpc", "http://localhost:8545", "RPC path to Ethereum node")
+	keyfile       = flag.String("keyfile", "", "Path to JSON keyfile")
+	insecure      = flag.Bool("insecure", false, "Do not prompt for a password, assume the empty string")
+	gasprice      = flag.Float64("gasprice", 5.0, "Gas price, in gwei")
+	configFile    = flag.String("config", "", "Path to config file (default ~/.dnsprove.toml)")
+	profileName   = flag.String("profile", "", "Network profile to use from the config file: mainnet, goerli, sepolia, local or one defined there")
+	metricsAddr   = flag.String("metrics", "", "Address to serve Prometheus metrics on at /metrics, eg :9100")
+	pushgateway   = flag.String("pushgateway", "", "URL of a Prometheus Pushgateway to push metrics to on exit")
+	metricsJob    = flag.String("metrics-job", "dnsprove", "Job name to push metrics under")
+	zonesFlag     = flag.String("zones", "", "Comma-separated list of signed zone files to answer DNS queries from, instead of -server")
+	anchorsFile   = flag.String("anchors", "", "File of DS records, in zone file format, to use as trust anchors instead of the root KSK")
+	ensAddress    = flag.String("ens", "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e", "Contract address for the ENS registry used to resolve names given as addresses, and to find oracles")
 
 	proveFlags    = flag.NewFlagSet("prove", flag.ExitOnError)
-	oracleAddress = proveFlags.String("address", "", "Contract address for DNSSEC oracle")
+	oracleAddress = proveFlags.String("address", "", "Address or ENS name of the DNSSEC oracle; found through ENS if not given")
 	print         = proveFlags.Bool("print", false, "don't upload to the contract, just print proof data")
 	yes           = proveFlags.Bool("yes", false, "Do not prompt before sending transactions")
+	batchFile     = proveFlags.String("batch", "", "File listing \"qtype qname\" pairs to prove, one per line, or - for stdin")
+	gasLimit      = proveFlags.Uint64("gaslimit", 6000000, "Maximum gas to use per transaction when proving a batch")
+	explain       = proveFlags.Bool("explain", false, "don't upload to the contract, just show each step of validating the proof")
+	proveOutput   = proveFlags.String("output", "text", "Output format: text or json")
+	proveInsecure = proveFlags.Bool("insecure-delegation", false, "If the name is in an unsigned zone, prove to the oracle that its signed parent has no DS record for it instead")
 
 	claimFlags      = flag.NewFlagSet("claim", flag.ExitOnError)
-	registryAddress = claimFlags.String("address", "0x314159265dd8dbb310642f98f50c066173c1259b", "Contract address for ENS registry")
+	registryAddress = claimFlags.String("address", "", "Address or ENS name of the ENS registry; -ens if not given")
+	claimResolver   = claimFlags.String("resolver", "", "Address or ENS name of a resolver to set when claiming, if the registrar supports it")
+	claimAddr       = claimFlags.String("addr", "", "Address or ENS name to set as the address record when claiming; requires -resolver")
+	claimForce      = claimFlags.Bool("force", false, "Claim even if the _ens TXT record names a different owner")
+	claimOutput     = claimFlags.String("output", "text", "Output format: text or json")
+
+	watchFlags         = flag.NewFlagSet("watch", flag.ExitOnError)
+	watchOracleAddress = watchFlags.String("address", "", "Address or ENS name of the DNSSEC oracle; found through ENS if not given")
+	watchStateFile     = watchFlags.String("state", "dnsprove-watch.json", "Path to file used to persist state across restarts")
+	watchInterval      = watchFlags.Duration("interval", time.Hour, "How often to check each record")
+	watchMargin        = watchFlags.Duration("margin", 6*time.Hour, "Resubmit records that will expire within this long after the next check")
+
+	indexFlags         = flag.NewFlagSet("index", flag.ExitOnError)
+	indexOracleAddress = indexFlags.String("address", "", "Address or ENS name of the DNSSEC oracle; found through ENS if not given")
+	indexDB            = indexFlags.String("db", "dnsprove.db", "Path to the index database")
+	indexStart         = indexFlags.Uint64("from", 0, "Block to start indexing from, if the database is empty")
+	indexConfirmations = indexFlags.Uint64("confirmations", 3, "Number of blocks to stay behind the chain head")
+	indexInterval      = indexFlags.Duration("interval", 15*time.Second, "How often to poll for new events")
+	indexOnce          = indexFlags.Bool("once", false, "Sync to the current block and exit")
+
+	listFlags = flag.NewFlagSet("list", flag.ExitOnError)
+	listDB    = listFlags.String("db", "dnsprove.db", "Path to the index database")
+
+	showFlags = flag.NewFlagSet("show", flag.ExitOnError)
+	showDB    = showFlags.String("db", "dnsprove.db", "Path to the index database")
+
+	inspectFlags         = flag.NewFlagSet("inspect", flag.ExitOnError)
+	inspectOracleAddress = inspectFlags.String("address", "", "Address or ENS name of the DNSSEC oracle; found through ENS if not given")
+	inspectOutput        = inspectFlags.String("output", "text", "Output format: text or json")
+
+	statusFlags           = flag.NewFlagSet("status", flag.ExitOnError)
+	statusRegistryAddress = statusFlags.String("address", "", "Address or ENS name of the ENS registry; -ens if not given")
+	statusOutput          = statusFlags.String("output", "text", "Output format: text or json")
+
+	verifyFlags         = flag.NewFlagSet("verify", flag.ExitOnError)
+	verifyOracleAddress = verifyFlags.String("address", "", "Address or ENS name of the DNSSEC oracle; found through ENS if not given")
+	verifyOutput        = verifyFlags.String("output", "text", "Output format: text or json")
+
+	recordsFlags           = flag.NewFlagSet("records", flag.ExitOnError)
+	recordsRegistryAddress = recordsFlags.String("address", "", "Address or ENS name of the ENS registry; -ens if not given")
+	recordsYes             = recordsFlags.Bool("yes", false, "Do not prompt before sending transactions")
+
+	serveFlags           = flag.NewFlagSet("serve", flag.ExitOnError)
+	serveListen          = serveFlags.String("listen", ":8080", "Address to listen for HTTP requests on")
+	serveOracleAddress   = serveFlags.String("address", "", "Address or ENS name of the DNSSEC oracle used for /calldata; found through ENS if not given")
+	serveRegistryAddress = serveFlags.String("registry", "", "Address or ENS name of the ENS registry used for /status; -ens if not given")
+	serveTimeout         = serveFlags.Duration("timeout", 30*time.Second, "Maximum time to spend on a request")
+	serveRate            = serveFlags.Float64("rate", 1, "Requests per second allowed from each client; 0 for no limit")
+	serveBurst           = serveFlags.Int("burst", 10, "Requests a client may make at once before being rate limited")
+
+	setResolverCmd = newENSCommand("set-resolver", "name resolver", 2)
+	transferCmd    = newENSCommand("transfer", "name owner", 2)
+	subdomainCmd   = newENSCommand("subdomain", "name", 1)
+	subdomainOwner = subdomainCmd.flags.String("owner", "", "Owner of the new subdomain; defaults to the parent's owner")
 
 	subcommands = map[string]func([]string){
-		"prove": proveCommand,
-		"claim": claimCommand,
+		"prove":           proveCommand,
+		"claim":           claimCommand,
+		"watch":           watchCommand,
+		"index":           indexCommand,
+		"list":            listCommand,
+		"show":            showCommand,
+		"inspect":         inspectCommand,
+		"status":          statusCommand,
+		"verify":          verifyCommand,
+		"records":         recordsCommand,
+		"serve":           serveCommand,
+		"export-fixtures": exportFixturesCommand,
+		"set-resolver":    setResolverCommand,
+		"transfer":        transferCommand,
+		"subdomain":       subdomainCommand,
 	}
 
 	trustAnchors = []*dns.DS{
@@ -68,6 +151,29 @@ var (
 	NotDNSSECEnabledError = errors.New("RR does not exist and either no NSEC records returned or NSEC records are unsigned")
 )
 
+// DNSQueryError is returned when a DNS query could not be completed, as
+// opposed to when its answer fails to validate.
+type DNSQueryError struct {
+	Name string
+	Err  error
+}
+
+func (e *DNSQueryError) Error() string {
+	return fmt.Sprintf("DNS query for %s failed: %v", e.Name, e.Err)
+}
+
+// UnsupportedDenialError is returned when NSEC3 records deny a record in a
+// form that can't be validated, such as a closest encloser proof for any type
+// but DS.
+type UnsupportedDenialError struct {
+	Name  string
+	Qtype uint16
+}
+
+func (e *UnsupportedDenialError) Error() string {
+	return fmt.Sprintf("The NSEC3 proof that %s has no %s records is not supported", e.Name, dns.TypeToString[e.Qtype])
+}
+
 type dnskeyEntry struct {
 	name      string
 	algorithm uint8
@@ -76,10 +182,30 @@ type dnskeyEntry struct {
 
 type Client struct {
 	c                   *dns.Client
-	nameserver          string
+	resolvers           []*resolver
 	knownHashes         map[dnskeyEntry][]*dns.DS
 	supportedAlgorithms map[uint8]struct{}
 	supportedDigests    map[uint8]struct{}
+	trace               *traceNode
+	zones               *zoneFiles
+	results             map[queryKey]*queryResult
+
+	// mu guards responses, which prefetch fills concurrently, and chain.
+	mu        sync.Mutex
+	responses map[queryKey]response
+	chain     map[queryKey]*dns.Msg
+}
+
+// queryKey identifies a query whose validated result a client has kept.
+type queryKey struct {
+	name  string
+	qtype uint16
+}
+
+type queryResult struct {
+	paths [][]proofs.SignedSet
+	found bool
+	err   error
 }
 
 func (client *Client) addDS(ds *dns.DS) {
@@ -97,13 +223,16 @@ func (client *Client) supportsDigest(digest uint8) bool {
 	return ok
 }
 
-func NewClient(nameserver string, roots []*dns.DS, algorithms, digests map[uint8]struct{}) *Client {
+func NewClient(resolvers []*resolver, roots []*dns.DS, algorithms, digests map[uint8]struct{}) *Client {
 	client := &Client{
 		c:                   new(dns.Client),
-		nameserver:          nameserver,
+		resolvers:           resolvers,
 		knownHashes:         make(map[dnskeyEntry][]*dns.DS),
 		supportedAlgorithms: algorithms,
 		supportedDigests:    digests,
+		results:             make(map[queryKey]*queryResult),
+		responses:           make(map[queryKey]response),
+		chain:               make(map[queryKey]*dns.Msg),
 	}
 	for _, root := range roots {
 		client.addDS(root)
@@ -111,7 +240,28 @@ func NewClient(nameserver string, roots []*dns.DS, algorithms, digests map[uint8
 	return client
 }
 
-func (client *Client) Query(qtype uint16, qclass uint16, name string) (*dns.Msg, error) {
+// Query returns the response to a query, which may have been fetched already
+// by prefetch, and the resolver that sent it, if it didn't come from a zone
+// file.
+func (client *Client) Query(qtype uint16, qclass uint16, name string) (*dns.Msg, *resolver, error) {
+	if client.zones != nil {
+		r, err := client.zones.Answer(qtype, name)
+		return r, nil, err
+	}
+	if r := client.prefetched(qtype, name); r.msg != nil {
+		dnsQueriesSaved.WithLabelValues(savedPrefetch).Inc()
+		log.Debug("DNS query answered by prefetch", "type", dns.TypeToString[qtype], "name", name)
+		return r.msg, r.from, nil
+	}
+	return client.exchange(qtype, qclass, name)
+}
+
+// exchangeWith sends a query to res, over TLS for tls:// servers and
+// DNS-over-HTTPS otherwise, marking it down if it doesn't answer.
+func (client *Client) exchangeWith(res *resolver, qtype uint16, qclass uint16, name string) (msg *dns.Msg, err error) {
+	start := time.Now()
+	defer func() { observeQuery(qtype, start, err) }()
+
 	m := &dns.Msg{
 		MsgHdr: dns.MsgHdr{
 			Authoritative:     false,
@@ -137,15 +287,39 @@ func (client *Client) Query(qtype uint16, qclass uint16, name string) (*dns.Msg,
 	}
 	o.SetDo()
 	o.SetUDPSize(dns.DefaultMsgSize)
+	if *chainQueries {
+		// Both transports are connection-oriented, as CHAIN requires.
+		o.Option = append(o.Option, chainRequest())
+	}
 	m.Extra = append(m.Extra, o)
 	m.Id = dns.Id()
 
+	var r *dns.Msg
+	if strings.HasPrefix(res.addr, "tls://") {
+		c := &dns.Client{Net: "tcp-tls"}
+		r, _, err = c.Exchange(m, strings.TrimPrefix(res.addr, "tls://"))
+	} else {
+		r, err = postQuery(res.addr, m)
+	}
+	if err != nil {
+		res.failed(err)
+		return nil, err
+	}
+	res.succeeded()
+	log.Debug("DNS response:\n" + r.String())
+	log.Info("DNS query", "class", dns.ClassToString[qclass], "type", dns.TypeToString[qtype], "name", name, "answer", len(r.Answer), "extra", len(r.Extra), "ns", len(r.Ns), "resolver", res.addr)
+	client.harvest(r)
+	return r, nil
+}
+
+// postQuery sends a DNS-over-HTTPS query.
+func postQuery(url string, m *dns.Msg) (*dns.Msg, error) {
 	req, err := m.Pack()
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := http.Post(client.nameserver, "application/dns-udpwireformat", bytes.NewReader(req))
+	response, err := http.Post(url, "application/dns-udpwireformat", bytes.NewReader(req))
 	if err != nil {
 		return nil, err
 	}
@@ -161,143 +335,353 @@ func (client *Client) Query(qtype uint16, qclass uint16, name string) (*dns.Msg,
 	}
 
 	var r dns.Msg
-	err = r.Unpack(data)
-	if err == nil {
-		log.Debug("DNS response:\n" + r.String())
-		log.Info("DNS query", "class", dns.ClassToString[qclass], "type", dns.TypeToString[qtype], "name", name, "answer", len(r.Answer), "extra", len(r.Extra), "ns", len(r.Ns))
+	if err := r.Unpack(data); err != nil {
+		return nil, err
 	}
-	return &r, err
+	return &r, nil
 }
 
+// QueryWithProof returns the best proof chain for an RRSet, or for the NSEC
+// record proving it doesn't exist, as ranked by rankPaths.
 func (client *Client) QueryWithProof(qtype, qclass uint16, name string) ([]proofs.SignedSet, bool, error) {
-	found := false
+	paths, found, err := client.QueryPaths(qtype, qclass, name)
+	if err != nil {
+		return nil, found, err
+	}
+	return paths[0], found, nil
+}
 
+// QueryPaths returns every valid proof chain for an RRSet, or for the NSEC
+// record proving it doesn't exist, best first. Chains differ where a set has
+// more than one valid signature, as during key and algorithm rollovers.
+func (client *Client) QueryPaths(qtype, qclass uint16, name string) ([][]proofs.SignedSet, bool, error) {
 	if name[len(name)-1] != '.' {
 		name = name + "."
 	}
 
-	r, err := client.Query(qtype, qclass, name)
+	key := queryKey{strings.ToLower(name), qtype}
+	if result, ok := client.results[key]; ok {
+		node := &traceNode{Kind: "query", Name: name, Type: dns.TypeToString[qtype], Detail: "already validated"}
+		if result.err != nil {
+			node.reject(result.err.Error())
+		} else {
+			node.accept(countPaths(len(result.paths)))
+		}
+		client.note(node)
+		return result.paths, result.found, result.err
+	}
+
+	paths, found, err := client.queryPaths(qtype, qclass, name)
+	client.results[key] = &queryResult{paths, found, err}
+	return paths, found, err
+}
+
+func (client *Client) queryPaths(qtype, qclass uint16, name string) ([][]proofs.SignedSet, bool, error) {
+	if client.zones == nil && *strategy == strategyQuorum {
+		return client.queryQuorum(qtype, qclass, name)
+	}
+
+	if r := client.harvested(qtype, name); r != nil {
+		paths, found, err := client.validateResponse(qtype, qclass, name, r, "records included in an earlier response")
+		if err == nil {
+			dnsQueriesSaved.WithLabelValues(savedHarvest).Inc()
+			return paths, found, nil
+		}
+		log.Debug("Records included in an earlier response did not validate; querying", "type", dns.TypeToString[qtype], "name", name, "err", err)
+	}
+
+	r, from, err := client.Query(qtype, qclass, name)
 	if err != nil {
-		return nil, false, err
+		return nil, false, client.queryFailed(qtype, name, "", err)
 	}
+	paths, found, err := client.validateResponse(qtype, qclass, name, r, client.source(from))
+	if err != nil && from != nil && *strategy == strategyFirstValid && len(client.resolvers) > 1 {
+		return client.tryOtherResolvers(qtype, qclass, name, from, found, err)
+	}
+	return paths, found, err
+}
+
+// queryFailed records in the trace that a query got no response, and returns
+// the error for it.
+func (client *Client) queryFailed(qtype uint16, name, source string, err error) error {
+	node := &traceNode{Kind: "query", Name: name, Type: dns.TypeToString[qtype], Detail: source}
+	node.reject(err.Error())
+	client.note(node)
+	return &DNSQueryError{name, err}
+}
+
+// validateResponse returns every valid proof chain for the answer in r, or
+// for the NSEC record in it proving there is none. source, if set, describes
+// where r came from.
+func (client *Client) validateResponse(qtype, qclass uint16, name string, r *dns.Msg, source string) ([][]proofs.SignedSet, bool, error) {
+	found := false
+
+	node := &traceNode{Kind: "query", Name: name, Type: dns.TypeToString[qtype]}
+	defer client.enter(node)()
 
 	rrs := getRRset(r.Answer, name, qtype)
 	var sigs []dns.RR
 	if len(rrs) > 0 {
 		found = true
 		sigs = findSignatures(r.Answer, name)
+		node.Detail = fmt.Sprintf("%d records, %d signatures", len(rrs), len(sigs))
 		if len(sigs) == 0 {
+			validationFailures.WithLabelValues(reasonUnsigned).Inc()
+			node.reject("answer is unsigned")
 			return nil, false, fmt.Errorf("No signed RRSETs available for %s %s", dns.TypeToString[qtype], name)
 		}
 	} else {
 		rrs = getNSECRRs(r.Ns, name)
 		if len(rrs) == 0 {
+			rrs = getNSEC3RRs(r.Ns, name)
+		}
+		if len(rrs) == 0 && qtype == dns.TypeDS {
+			if ce, nc := optOutProof(r.Ns, name); ce != nil {
+				return client.validateOptOut(qclass, name, r, ce, nc, node, source)
+			}
+		}
+		if len(rrs) == 0 && hasNSEC3(r.Ns) {
+			node.reject("NSEC3 records deny the name in a form that is not supported")
+			return nil, false, &UnsupportedDenialError{name, qtype}
+		}
+		if len(rrs) == 0 {
+			validationFailures.WithLabelValues(reasonUnsigned).Inc()
+			node.reject("no records, and no NSEC record covers the name")
 			return nil, false, NotDNSSECEnabledError
 		}
-		log.Info("RR does not exist; got NSEC", "qtype", dns.TypeToString[qtype], "name", name)
+		denial := dns.TypeToString[rrs[0].Header().Rrtype]
+		log.Info("RR does not exist; got "+denial, "qtype", dns.TypeToString[qtype], "name", name)
 		sigs = findSignatures(r.Ns, rrs[0].Header().Name)
+		node.Detail = fmt.Sprintf("no records; %s %s covers name, %d signatures", denial, rrs[0].Header().Name, len(sigs))
 		if len(sigs) == 0 {
+			validationFailures.WithLabelValues(reasonUnsigned).Inc()
+			node.reject("NSEC record is unsigned")
 			return nil, false, NotDNSSECEnabledError
 		}
 	}
 
+	paths := client.signedPaths(name, rrs, sigs)
+	if len(paths) == 0 {
+		node.reject("no valid signatures found")
+		return nil, found, fmt.Errorf("Could not validate %s %s %s: no valid signatures found", dns.ClassToString[qclass], dns.TypeToString[qtype], name)
+	}
+	paths = rankPaths(paths)
+	if source != "" {
+		node.Detail += "; " + source
+	}
+	node.accept(countPaths(len(paths)))
+	return paths, found, nil
+}
+
+// validateOptOut returns every valid proof chain for ce and nc, the NSEC3
+// records in r proving that name has no DS record because it is an unsigned
+// delegation in an opt-out span. Each chain ends with ce's set and then nc's,
+// or with just one where a single record is both.
+func (client *Client) validateOptOut(qclass uint16, name string, r *dns.Msg, ce, nc *dns.NSEC3, node *traceNode, source string) ([][]proofs.SignedSet, bool, error) {
+	log.Info("RR does not exist; got NSEC3 opt-out proof", "qtype", "DS", "name", name)
+	node.Detail = fmt.Sprintf("no records; NSEC3 %s matches the closest encloser and opt-out NSEC3 %s covers the next closer name", ce.Hdr.Name, nc.Hdr.Name)
+
+	paths := client.signedPaths(name, []dns.RR{ce}, findSignatures(r.Ns, ce.Hdr.Name))
+	if len(paths) > 0 && nc != ce {
+		// Both records are signed by the same zone, so the covering record's
+		// signature just follows the closest encloser's chain.
+		covering := client.signedPaths(name, []dns.RR{nc}, findSignatures(r.Ns, nc.Hdr.Name))
+		if len(covering) == 0 {
+			paths = nil
+		}
+		for i := range paths {
+			paths[i] = append(paths[i], covering[0][len(covering[0])-1])
+		}
+	}
+	if len(paths) == 0 {
+		node.reject("no valid signatures found")
+		return nil, false, fmt.Errorf("Could not validate %s DS %s: no valid signatures found for its NSEC3 opt-out proof", dns.ClassToString[qclass], name)
+	}
+
+	paths = rankPaths(paths)
+	if source != "" {
+		node.Detail += "; " + source
+	}
+	node.accept(countPaths(len(paths)))
+	return paths, false, nil
+}
+
+// signedPaths returns every valid proof chain for rrs with one of sigs.
+// Every signature is tried rather than stopping at the first that validates,
+// so the best path can be chosen.
+func (client *Client) signedPaths(name string, rrs, sigs []dns.RR) [][]proofs.SignedSet {
+	var paths [][]proofs.SignedSet
 	for _, sig := range sigs {
 		sig := sig.(*dns.RRSIG)
+		sigNode := &traceNode{
+			Kind:   "rrsig",
+			Name:   sig.SignerName,
+			Type:   dns.TypeToString[sig.TypeCovered],
+			Detail: fmt.Sprintf("%s/%d, valid %s to %s", dns.AlgorithmToString[sig.Algorithm], sig.KeyTag, dns.TimeToString(sig.Inception), dns.TimeToString(sig.Expiration)),
+		}
 		if sig.TypeCovered != rrs[0].Header().Rrtype {
+			sigNode.skip("covers a different type")
+			client.note(sigNode)
 			continue
 		}
-		ret, err := client.verifyRRSet(sig, rrs)
-		if err == nil {
-			result := proofs.SignedSet{sig, rrs, name}
-			ret = append(ret, result)
-			return ret, found, nil
+		restore := client.enter(sigNode)
+		prefixes, err := client.verifyRRSet(sig, rrs)
+		restore()
+		if err != nil {
+			sigNode.reject(err.Error())
+			log.Warn("Failed to verify RRSET", "type", dns.TypeToString[rrs[0].Header().Rrtype], "name", name, "signername", sig.SignerName, "algorithm", dns.AlgorithmToString[sig.Algorithm], "keytag", sig.KeyTag, "err", err)
+			continue
+		}
+		sigNode.accept(countPaths(len(prefixes)))
+		result := proofs.SignedSet{sig, rrs, name}
+		for _, prefix := range prefixes {
+			path := make([]proofs.SignedSet, len(prefix), len(prefix)+1)
+			copy(path, prefix)
+			paths = append(paths, append(path, result))
 		}
-		log.Warn("Failed to verify RRSET", "type", dns.TypeToString[rrs[0].Header().Rrtype], "name", name, "signername", sig.SignerName, "algorithm", dns.AlgorithmToString[sig.Algorithm], "keytag", sig.KeyTag, "err", err)
 	}
-
-	return nil, found, fmt.Errorf("Could not validate %s %s %s: no valid signatures found", dns.ClassToString[qclass], dns.TypeToString[qtype], name)
+	return paths
 }
 
-func (client *Client) verifyRRSet(sig *dns.RRSIG, rrs []dns.RR) ([]proofs.SignedSet, error) {
+// verifyRRSet checks sig over rrs, returning every valid chain proving the
+// key that made it.
+func (client *Client) verifyRRSet(sig *dns.RRSIG, rrs []dns.RR) ([][]proofs.SignedSet, error) {
 	if !client.supportsAlgorithm(sig.Algorithm) {
+		validationFailures.WithLabelValues(reasonUnsupportedAlgorithm).Inc()
 		return nil, fmt.Errorf("Unsupported algorithm: %s", dns.AlgorithmToString[sig.Algorithm])
 	}
+	if !sig.ValidityPeriod(time.Time{}) {
+		validationFailures.WithLabelValues(reasonExpired).Inc()
+		return nil, fmt.Errorf("Signature is only valid from %s to %s", dns.TimeToString(sig.Inception), dns.TimeToString(sig.Expiration))
+	}
 
-	var sets []proofs.SignedSet
+	selfSigned := sig.Header().Name == sig.SignerName && rrs[0].Header().Rrtype == dns.TypeDNSKEY
+	var keyPaths [][]proofs.SignedSet
 	var keys []dns.RR
-	var err error
-	if sig.Header().Name == sig.SignerName && rrs[0].Header().Rrtype == dns.TypeDNSKEY {
+	if selfSigned {
 		// RRSet is self-signed; verify against itself
 		keys = rrs
 	} else {
 		// Find the keys that signed this RRSET
 		var found bool
-		sets, found, err = client.QueryWithProof(dns.TypeDNSKEY, sig.Header().Class, sig.SignerName)
+		var err error
+		keyPaths, found, err = client.QueryPaths(dns.TypeDNSKEY, sig.Header().Class, sig.SignerName)
 		if err != nil {
 			return nil, err
 		}
 		if !found {
+			validationFailures.WithLabelValues(reasonMissingDNSKEY).Inc()
 			return nil, fmt.Errorf("DNSKEY %s not found", sig.SignerName)
 		}
-		keys = sets[len(sets)-1].Rrs
+		// Every path proves the same DNSKEY RRSet.
+		keys = keyPaths[0][len(keyPaths[0])-1].Rrs
 	}
 
-	// Iterate over the keys looking for one that validly signs our RRSET
+	// Iterate over the keys looking for those that validly sign our RRSET
+	var paths [][]proofs.SignedSet
+	var lastErr error
 	for _, key := range keys {
 		key := key.(*dns.DNSKEY)
-		if key.Algorithm != sig.Algorithm || key.KeyTag() != sig.KeyTag || key.Header().Name != sig.SignerName {
+		keyNode := &traceNode{Kind: "dnskey", Name: key.Header().Name, Detail: fmt.Sprintf("%s/%d, flags %d", dns.AlgorithmToString[key.Algorithm], key.KeyTag(), key.Flags)}
+		switch {
+		case key.Algorithm != sig.Algorithm:
+			keyNode.skip("algorithm does not match signature")
+		case key.KeyTag() != sig.KeyTag:
+			keyNode.skip("key tag does not match signature")
+		case key.Header().Name != sig.SignerName:
+			keyNode.skip("owner does not match signer name")
+		}
+		if keyNode.Result != "" {
+			client.note(keyNode)
 			continue
 		}
 		if err := sig.Verify(key, rrs); err != nil {
+			keyNode.reject("signature does not verify: " + err.Error())
+			client.note(keyNode)
 			log.Error("Could not verify signature", "type", dns.TypeToString[rrs[0].Header().Rrtype], "signame", sig.Header().Name, "keyname", key.Header().Name, "algorithm", dns.AlgorithmToString[key.Algorithm], "keytag", key.KeyTag(), "key", key, "rrs", rrs, "sig", sig, "err", err)
 			continue
 		}
-		if sig.Header().Name == sig.SignerName && rrs[0].Header().Rrtype == dns.TypeDNSKEY {
-			// RRSet is self-signed; look for DS records in parent zones to verify
-			sets, err = client.verifyWithDS(key)
-			if err != nil {
-				return nil, err
-			}
+		if !selfSigned {
+			client.note(keyNode)
+			keyNode.accept("signature verifies")
+			return keyPaths, nil
+		}
+
+		// RRSet is self-signed; look for DS records in parent zones to verify
+		restore := client.enter(keyNode)
+		dsPaths, err := client.verifyWithDS(key)
+		restore()
+		if err != nil {
+			keyNode.reject(err.Error())
+			lastErr = err
+			continue
 		}
-		return sets, nil
+		keyNode.accept("signature verifies")
+		paths = append(paths, dsPaths...)
+	}
+	if len(paths) > 0 {
+		return paths, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
 	}
+	validationFailures.WithLabelValues(reasonBadSignature).Inc()
 	return nil, fmt.Errorf("Could not validate signature for %s %s %s (%s/%d); no valid keys found", dns.ClassToString[sig.Header().Class], dns.TypeToString[sig.Header().Rrtype], sig.Header().Name, dns.AlgorithmToString[sig.Algorithm], sig.KeyTag)
 }
 
-func (client *Client) verifyWithDS(key *dns.DNSKEY) ([]proofs.SignedSet, error) {
+// checkDS reports whether ds is a digest of key, recording the attempt in the
+// client's trace.
+func (client *Client) checkDS(kind string, ds *dns.DS, key *dns.DNSKEY) bool {
+	node := &traceNode{Kind: kind, Name: ds.Header().Name, Detail: fmt.Sprintf("%s/%d, %s", dns.AlgorithmToString[ds.Algorithm], ds.KeyTag, dns.HashToString[ds.DigestType])}
+	defer client.note(node)
+
+	switch {
+	case !client.supportsDigest(ds.DigestType):
+		node.skip("unsupported digest type")
+	case ds.Algorithm != key.Algorithm || ds.KeyTag != key.KeyTag():
+		node.skip("does not refer to this key")
+	case strings.ToLower(key.ToDS(ds.DigestType).Digest) != strings.ToLower(ds.Digest):
+		node.reject("digest does not match key")
+	default:
+		node.accept("digest matches key")
+		return true
+	}
+	return false
+}
+
+// verifyWithDS returns every valid chain proving key, which is empty if key
+// is a trust anchor.
+func (client *Client) verifyWithDS(key *dns.DNSKEY) ([][]proofs.SignedSet, error) {
 	keytag := key.KeyTag()
 	// Check the roots
 	for _, ds := range client.knownHashes[dnskeyEntry{key.Header().Name, key.Algorithm, keytag}] {
-		if !client.supportsDigest(ds.DigestType) {
-			continue
-		}
-		if strings.ToLower(key.ToDS(ds.DigestType).Digest) == strings.ToLower(ds.Digest) {
-			return []proofs.SignedSet{}, nil
+		if client.checkDS("anchor", ds, key) {
+			return [][]proofs.SignedSet{{}}, nil
 		}
 	}
 
 	// If it's a root DS, and we don't have it in our roots, no point querying for it.
 	if key.Header().Name == "." {
+		validationFailures.WithLabelValues(reasonMissingDS).Inc()
 		return nil, fmt.Errorf("DS . with key tag %d not found", keytag)
 	}
 
 	// Look up the DS record
-	sets, found, err := client.QueryWithProof(dns.TypeDS, key.Header().Class, key.Header().Name)
+	paths, found, err := client.QueryPaths(dns.TypeDS, key.Header().Class, key.Header().Name)
 	if err != nil {
 		return nil, err
 	}
 	if !found {
+		validationFailures.WithLabelValues(reasonMissingDS).Inc()
 		return nil, fmt.Errorf("DS %s not found", key.Header().Name)
 	}
-	for _, ds := range sets[len(sets)-1].Rrs {
-		ds := ds.(*dns.DS)
-		if !client.supportsDigest(ds.DigestType) {
-			continue
-		}
-		if strings.ToLower(key.ToDS(ds.DigestType).Digest) == strings.ToLower(ds.Digest) {
-			return sets, nil
+	// Every path proves the same DS RRSet.
+	for _, ds := range paths[0][len(paths[0])-1].Rrs {
+		if client.checkDS("ds", ds.(*dns.DS), key) {
+			return paths, nil
 		}
 	}
+	validationFailures.WithLabelValues(reasonMissingDS).Inc()
 	return nil, fmt.Errorf("Could not find any DS records that validate %s DNSKEY %s (%s/%d)", dns.ClassToString[key.Header().Class], key.Header().Name, dns.AlgorithmToString[key.Algorithm], keytag)
 }
 
@@ -342,6 +726,19 @@ func getNSECRRs(rrs []dns.RR, name string) []dns.RR {
 	return ret
 }
 
+// getNSEC3RRs returns the NSEC3 record matching name, which proves it has no
+// records of the types missing from its bitmap. Proofs that a name doesn't
+// exist take several NSEC3 records, and of those only optOutProof's are
+// supported.
+func getNSEC3RRs(rrs []dns.RR, name string) []dns.RR {
+	for _, rr := range rrs {
+		if nsec3, ok := rr.(*dns.NSEC3); ok && nsec3.Match(name) {
+			return []dns.RR{rr}
+		}
+	}
+	return nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -383,6 +780,10 @@ func main() {
 	}
 
 	log.Root().SetHandler(log.LvlFilterHandler(log.Lvl(*verbosity), log.StreamHandler(os.Stderr, log.TerminalFormat())))
+	loadProfile()
+	loadOffline()
+	loadResolvers()
+	startMetrics()
 
 	subcommand, ok := subcommands[flag.Arg(0)]
 	if !ok {
@@ -391,17 +792,25 @@ func main() {
 	}
 
 	subcommand(flag.Args()[1:])
+	pushMetrics()
 }
 
 func proveCommand(args []string) {
 	proveFlags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] prove [prove options] qtype qname\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] prove [prove options] -batch file\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nProve command options:\n")
 		proveFlags.PrintDefaults()
 	}
 	proveFlags.Parse(args)
+	startReport("prove", *proveOutput)
+
+	if *batchFile != "" && proveFlags.NArg() == 0 {
+		proveBatch(*batchFile)
+		return
+	}
 
 	if proveFlags.NArg() != 2 {
 		proveFlags.Usage()
@@ -410,146 +819,290 @@ func proveCommand(args []string) {
 
 	qtype, ok := dns.StringToType[proveFlags.Arg(0)]
 	if !ok {
-		log.Crit("Unrecognised query type", "qtype", qtype)
-		os.Exit(1)
+		report.fail(ExitError, "Unrecognised query type", "qtype", proveFlags.Arg(0))
 	}
 	name := proveFlags.Arg(1)
+	report.setTarget(qtype, name)
 
-	sets, found, err := getProofs(qtype, name)
+	if *explain {
+		explainProof(qtype, name)
+	}
+
+	paths, found, err := getProofPaths(qtype, name)
+	delegation, _ := err.(*InsecureDelegationError)
+	if delegation != nil && *proveInsecure {
+		report.setInsecure(delegation)
+		report.decide(fmt.Sprintf("%s; proving that %s has no DS for %s instead", delegation.Error(), displayZone(delegation.Parent), displayName(delegation.Zone)))
+		qtype, name = dns.TypeDS, delegation.Zone
+		paths, found, err = [][]proofs.SignedSet{delegation.Proof}, false, nil
+	}
 	if err != nil {
-		log.Crit("Error resolving", "qtype", qtype, "name", name, "err", err)
-		os.Exit(1)
+		if delegation != nil {
+			report.setInsecure(delegation)
+			report.fail(ExitValidation, delegation.Error(), "zone", delegation.Zone, "parent", delegation.Parent, "hint", "use -insecure-delegation to prove the DS record's absence")
+		}
+		report.fail(proofExitCode(err), "Error resolving", "qtype", proveFlags.Arg(0), "name", name, "err", err)
 	}
+	sets := paths[0]
+	report.setFound(found)
+	report.setChain(sets, 0)
 
 	if *print {
-		for _, proof := range sets {
-			fmt.Printf("\n// %s\n", proof.Sig.String())
-			for _, rr := range proof.Rrs {
-				for _, line := range strings.Split(rr.String(), "\n") {
-					fmt.Printf("// %s\n", line)
-				}
+		if !report.JSON() {
+			for _, proof := range sets {
+				printProof(proof)
 			}
-			data, err := proof.Pack()
-			if err != nil {
-				log.Crit("Error packing RRSet", "err", err)
-				os.Exit(1)
-			}
-			sig, err := proof.PackSignature()
-			if err != nil {
-				log.Crit("Error packing RRSet signature", "err", err)
-				os.Exit(1)
-			}
-			fmt.Printf("[\"%s\", \"%x\", \"%x\"],\n", proof.Name, data, sig)
 		}
-		os.Exit(0)
+		report.finish(ExitOK, "printed", "")
 	}
 
-	conn, err := ethclient.Dial(*rpc)
+	conn, err := dial()
 	if err != nil {
-		log.Crit("Error connecting to Ethereum node", "err", err)
-		os.Exit(1)
+		report.fail(ExitChain, "Error connecting to Ethereum node", "err", err)
 	}
 
-	o, err := oracle.New(common.HexToAddress(*oracleAddress), conn)
+	o, err := openOracle(conn, *oracleAddress, name)
 	if err != nil {
-		log.Crit("Error creating oracle", "err", err)
-		os.Exit(1)
+		report.fail(ExitChain, "Error creating oracle", "err", err)
+	}
+
+	if delegation != nil && delegation.OptOut() && !o.Stateless() {
+		report.fail(ExitValidation, "Legacy oracles cannot be shown an NSEC3 opt-out proof of a DS record's absence", "zone", delegation.Zone, "parent", delegation.Parent)
 	}
 
+	if sets, err = choosePath(o, paths); err != nil {
+		report.fail(ExitValidation, "Error choosing proof path", "err", err)
+	}
+	report.setChain(sets, 0)
+
+	if o.Stateless() {
+		if delegation != nil {
+			verifyInsecure(o, delegation)
+		}
+		verifyStateless(o, qtype, name, sets, found)
+		return
+	}
+
+	decision, err := decideProve(o, qtype, name, sets, found)
+	if err != nil {
+		e := err.(*oracleCheckError)
+		report.fail(ExitChain, e.msg, "qtype", proveFlags.Arg(0), "name", name, "err", e.err)
+	}
+	report.decide(decision.reason)
+	if decision.action == proveNothing {
+		report.finish(ExitNothingToDo, "nothing-to-do", decision.message)
+	}
+	known := decision.known
+	report.setChain(sets, known)
+
+	if !*yes {
+		if !confirm("Send a transaction to prove %s %s (%d proofs) onchain?", dns.TypeToString[sets[len(sets)-1].Rrs[0].Header().Rrtype], name, len(sets)-known) {
+			report.finish(ExitAborted, "aborted", "Exiting at user request.")
+		}
+	}
+
+	auth, err := makeTransactor(conn)
+	if err != nil {
+		report.fail(ExitError, "Could not create transactor", "err", err)
+	}
+
+	txs, err := sendProof(o, auth, qtype, name, sets, found, known)
+	report.addTransactions(txs...)
+	if err != nil {
+		report.fail(ExitChain, "Error sending proofs", "err", err)
+	}
+
+	txids := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		txids = append(txids, tx.Hash().String())
+	}
+	log.Info("Transactions sent", "txids", txids)
+	report.finish(ExitOK, "sent", "")
+}
+
+// Actions prove can take with a legacy oracle.
+const (
+	proveNothing = "nothing-to-do"
+	proveSubmit  = "submit"
+	proveDelete  = "delete"
+)
+
+// proveDecision is what prove will send to a legacy oracle, and why.
+type proveDecision struct {
+	action string
+	reason string
+	// message is what prove finishes with when there is nothing to do.
+	message string
+	// known is how many of the proofs the oracle already has.
+	known int
+}
+
+// oracleCheckError is a failed oracle call made by decideProve, with the
+// message prove reports it with.
+type oracleCheckError struct {
+	msg string
+	err error
+}
+
+func (e *oracleCheckError) Error() string {
+	return fmt.Sprintf("%s: %v", e.msg, e.err)
+}
+
+// decideProve compares the oracle's copy of an RRSet with sets, its proof
+// chain from DNS, which ends with an NSEC record if found is false.
+func decideProve(o *oracle.Oracle, qtype uint16, name string, sets []proofs.SignedSet, found bool) (*proveDecision, error) {
+	action := proveSubmit
 	if !found {
 		// We're deleting a domain. If it's not already there, there's nothing to do.
-		_, _, hash, err := o.Rrdata(qtype, name)
+		_, _, hash, err := o.Rrdata(nil, qtype, name)
 		if err != nil {
-			log.Crit("Error checking RRDATA", "qtype", qtype, "name", name, "err", err)
-			os.Exit(1)
+			return nil, &oracleCheckError{"Error checking RRDATA", err}
 		}
 		if hash == [20]byte{} {
-			fmt.Printf("RRSet not found in oracle. Nothing to do; exiting\n")
-			os.Exit(0)
+			return &proveDecision{action: proveNothing, reason: "RRSet not found in oracle; nothing to delete", message: "RRSet not found in oracle. Nothing to do; exiting"}, nil
 		}
+		action = proveDelete
 	} else {
 		// If the RRset already matches, there's nothing to do
 		matches, err := o.RecordMatches(sets[len(sets)-1])
 		if err != nil {
-			log.Crit("Error checking for record", "err", err)
-			os.Exit(1)
+			return nil, &oracleCheckError{"Error checking for record", err}
 		}
-		if matches && found {
-			fmt.Printf("Nothing to do; exiting.\n")
-			os.Exit(0)
+		if matches {
+			return &proveDecision{action: proveNothing, reason: "RRSet already matches oracle", message: "Nothing to do; exiting."}, nil
 		}
 	}
 
 	known, err := o.FindFirstUnknownProof(sets)
 	if err != nil {
-		log.Crit("Error checking proofs against oracle", "err", err)
-		os.Exit(1)
+		return nil, &oracleCheckError{"Error checking proofs against oracle", err}
 	}
+	return &proveDecision{
+		action: action,
+		reason: fmt.Sprintf("Submitting %d of %d proofs", len(sets)-known, len(sets)),
+		known:  known,
+	}, nil
+}
 
-	if !*yes {
-		if !prompt.Confirm("Send a transaction to prove %s %s (%d proofs) onchain?", dns.TypeToString[sets[len(sets)-1].Rrs[0].Header().Rrtype], name, len(sets)-known) {
-			fmt.Printf("Exiting at user request.\n")
-			return
-		}
+// explainProof validates a record's proof chain and shows every step taken,
+// whether or not it succeeds.
+func explainProof(qtype uint16, name string) {
+	trace := &traceNode{}
+	sets, found, err := traceProofs(qtype, name, trace)
+	if report.JSON() {
+		report.result.Trace = trace.Children
+	} else {
+		renderTrace(os.Stdout, trace)
 	}
-
-	auth, err := makeTransactor(conn)
 	if err != nil {
-		log.Crit("Could not create transactor", "err", err)
-		os.Exit(1)
+		report.fail(proofExitCode(err), "Error resolving", "qtype", dns.TypeToString[qtype], "name", name, "err", err)
+	}
+	report.setFound(found)
+	report.setChain(sets, 0)
+	report.finish(ExitOK, "explained", "")
+}
+
+// verifyStateless checks a proof against a stateless oracle. There is
+// nothing to submit, since such oracles hold no records.
+func verifyStateless(o *oracle.Oracle, qtype uint16, name string, sets []proofs.SignedSet, found bool) {
+	if !found {
+		report.decide("Oracle is stateless and holds no records")
+		report.finish(ExitNothingToDo, "nothing-to-do", "Oracle is stateless and holds no records. Nothing to do; exiting")
+	}
+	if _, _, err := o.Verify(sets); err != nil {
+		report.fail(ExitValidation, "Oracle rejected proofs", "qtype", dns.TypeToString[qtype], "name", name, "err", err)
 	}
+	report.decide("Oracle verified proofs; stateless oracles need no transaction")
+	report.finish(ExitNothingToDo, "verified", fmt.Sprintf("Oracle verified %s %s (%d proofs). It is stateless, so no transaction is needed.", dns.TypeToString[qtype], name, len(sets)))
+}
 
+// verifyInsecure checks that a stateless oracle accepts the proof that a
+// zone has no DS record.
+func verifyInsecure(o *oracle.Oracle, insecure *InsecureDelegationError) {
+	if _, _, err := o.Verify(insecure.Proof); err != nil {
+		report.fail(ExitValidation, "Oracle rejected proofs", "qtype", "DS", "name", insecure.Zone, "err", err)
+	}
+	report.decide("Oracle verified the DS record's absence; stateless oracles need no transaction")
+	report.finish(ExitNothingToDo, "verified", fmt.Sprintf("Oracle verified that %s has no DS for %s (%d proofs). It is stateless, so no transaction is needed.", displayZone(insecure.Parent), displayName(insecure.Zone), len(insecure.Proof)))
+}
+
+// sendProof submits the sets needed to prove a record, starting from known,
+// or deletes it from the oracle if the last set is an NSEC record proving its
+// absence.
+func sendProof(o *oracle.Oracle, auth *bind.TransactOpts, qtype uint16, name string, sets []proofs.SignedSet, found bool, known int) ([]*types.Transaction, error) {
 	var txs []*types.Transaction
 	if found {
 		tx, err := o.SendProofs(auth, sets, known)
 		if err != nil {
-			log.Crit("Error sending proofs", "err", err)
-			os.Exit(1)
-		}
-		txs = append(txs, tx)
-	} else {
-		nsec := sets[len(sets)-1]
-		if known < len(sets)-1 {
-			tx, err := o.SendProofs(auth, sets[:len(sets)-1], known)
-			if err != nil {
-				log.Crit("Error sending proofs", "err", err)
-				os.Exit(1)
-			}
-			txs = append(txs, tx)
-			auth.Nonce = auth.Nonce.Add(auth.Nonce, big.NewInt(1))
+			return nil, err
 		}
+		auth.Nonce = auth.Nonce.Add(auth.Nonce, big.NewInt(1))
+		return append(txs, tx), nil
+	}
 
-		proof, err := sets[len(sets)-2].PackRRSet()
+	nsec := sets[len(sets)-1]
+	if known < len(sets)-1 {
+		tx, err := o.SendProofs(auth, sets[:len(sets)-1], known)
 		if err != nil {
-			log.Crit("Could not pack proof for record", "err", err)
-			os.Exit(1)
+			return nil, err
 		}
+		txs = append(txs, tx)
+		auth.Nonce = auth.Nonce.Add(auth.Nonce, big.NewInt(1))
+	}
 
-		deletetx, err := o.DeleteRRSet(auth, qtype, name, nsec, proof)
-		if err != nil {
-			log.Crit("Error deleting RRSet", "err", err)
-			os.Exit(1)
-		}
-		txs = append(txs, deletetx)
+	proof, err := sets[len(sets)-2].PackRRSet()
+	if err != nil {
+		return txs, err
 	}
 
-	txids := make([]string, 0, len(txs))
-	for _, tx := range txs {
-		txids = append(txids, tx.Hash().String())
+	deletetx, err := o.DeleteRRSet(auth, qtype, name, nsec, proof)
+	if err != nil {
+		return txs, err
 	}
-	log.Info("Transactions sent", "txids", txids)
+	return append(txs, deletetx), nil
 }
 
-func makeTransactor(conn *ethclient.Client) (*bind.TransactOpts, error) {
+func printProof(proof proofs.SignedSet) {
+	text, err := formatProof(proof)
+	if err != nil {
+		log.Crit("Error packing RRSet", "err", err)
+		exit(1)
+	}
+	fmt.Print(text)
+}
+
+// formatProof renders a proof as printed by prove -print: the signature and
+// records as comments, followed by the name, packed RRSet and signature.
+func formatProof(proof proofs.SignedSet) (string, error) {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "\n// %s\n", proof.Sig.String())
+	for _, rr := range proof.Rrs {
+		for _, line := range strings.Split(rr.String(), "\n") {
+			fmt.Fprintf(buf, "// %s\n", line)
+		}
+	}
+	data, err := proof.Pack()
+	if err != nil {
+		return "", err
+	}
+	sig, err := proof.PackSignature()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(buf, "[\"%s\", \"%x\", \"%x\"],\n", proof.Name, data, sig)
+	return buf.String(), nil
+}
+
+func makeTransactor(conn *chainClient) (*bind.TransactOpts, error) {
 	key, err := os.Open(*keyfile)
 	if err != nil {
-		log.Crit("Could not open keyfile", "err", err)
-		os.Exit(1)
+		return nil, err
 	}
+	defer key.Close()
 
 	pass := ""
 	if !*insecure {
-		pass = prompt.Password("Password")
+		pass = password("Password")
 	}
 	auth, err := bind.NewTransactor(key, pass)
 	if err != nil {
@@ -562,7 +1115,7 @@ func makeTransactor(conn *ethclient.Client) (*bind.TransactOpts, error) {
 	return auth, nil
 }
 
-func updateNonce(conn *ethclient.Client, auth *bind.TransactOpts) error {
+func updateNonce(conn *chainClient, auth *bind.TransactOpts) error {
 	nonce, err := conn.PendingNonceAt(context.TODO(), auth.From)
 	if err != nil {
 		return err
@@ -572,10 +1125,32 @@ func updateNonce(conn *ethclient.Client, auth *bind.TransactOpts) error {
 }
 
 func getProofs(qtype uint16, name string) ([]proofs.SignedSet, bool, error) {
+	return traceProofs(qtype, name, nil)
+}
+
+// traceProofs is like getProofs, but records each step of validation under
+// trace, if it is not nil.
+func traceProofs(qtype uint16, name string, trace *traceNode) ([]proofs.SignedSet, bool, error) {
+	paths, found, err := tracePaths(qtype, name, trace)
+	if err != nil {
+		return nil, false, err
+	}
+	return paths[0], found, nil
+}
+
+// getProofPaths is like getProofs, but returns every valid proof chain, best
+// first.
+func getProofPaths(qtype uint16, name string) ([][]proofs.SignedSet, bool, error) {
+	return tracePaths(qtype, name, nil)
+}
+
+func tracePaths(qtype uint16, name string, trace *traceNode) ([][]proofs.SignedSet, bool, error) {
 	qclass := uint16(dns.ClassINET)
-	if !strings.HasSuffix(name, ".") {
-		name = name + "."
+	name, err := ens.DNSName(name)
+	if err != nil {
+		return nil, false, err
 	}
+	name = dns.Fqdn(name)
 
 	hashmap := make(map[uint8]struct{})
 	for _, hashname := range strings.Split(*hashes, ",") {
@@ -587,8 +1162,80 @@ func getProofs(qtype uint16, name string) ([]proofs.SignedSet, bool, error) {
 		algmap[dns.StringToAlgorithm[algname]] = struct{}{}
 	}
 
-	client := NewClient(*server, trustAnchors, algmap, hashmap)
-	return client.QueryWithProof(qtype, qclass, name)
+	client := NewClient(resolvers, trustAnchors, algmap, hashmap)
+	client.trace = trace
+	client.zones = offlineZones
+	if *prefetchChain && client.zones == nil && *strategy != strategyQuorum {
+		client.prefetch(qtype, name)
+	}
+	paths, found, err := client.QueryPaths(qtype, qclass, name)
+	if err == nil {
+		return paths, found, nil
+	}
+	if _, ok := err.(*DNSQueryError); !ok {
+		insecure, ierr := client.findInsecureDelegation(name, err)
+		if insecure != nil {
+			return nil, false, insecure
+		}
+		if ierr != nil {
+			return nil, false, ierr
+		}
+	}
+	return nil, false, err
+}
+
+// verifyCommand checks that the oracle accepts a record's proof chain, without
+// sending anything.
+func verifyCommand(args []string) {
+	verifyFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] verify [verify options] qtype qname\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nVerify command options:\n")
+		verifyFlags.PrintDefaults()
+	}
+	verifyFlags.Parse(args)
+	startReport("verify", *verifyOutput)
+
+	if verifyFlags.NArg() != 2 {
+		verifyFlags.Usage()
+		return
+	}
+
+	qtype, ok := dns.StringToType[strings.ToUpper(verifyFlags.Arg(0))]
+	if !ok {
+		report.fail(ExitError, "Unrecognised query type", "qtype", verifyFlags.Arg(0))
+	}
+	name := verifyFlags.Arg(1)
+	report.setTarget(qtype, name)
+
+	paths, found, err := getProofPaths(qtype, name)
+	if err != nil {
+		report.fail(proofExitCode(err), "Error resolving", "qtype", verifyFlags.Arg(0), "name", name, "err", err)
+	}
+	report.setFound(found)
+
+	conn, err := dial()
+	if err != nil {
+		report.fail(ExitChain, "Error connecting to Ethereum node", "err", err)
+	}
+
+	o, err := openOracle(conn, *verifyOracleAddress, name)
+	if err != nil {
+		report.fail(ExitChain, "Error creating oracle", "err", err)
+	}
+
+	sets, err := choosePath(o, paths)
+	if err != nil {
+		report.fail(ExitValidation, "Oracle rejected proofs", "qtype", verifyFlags.Arg(0), "name", name, "err", err)
+	}
+	report.setChain(sets, len(sets))
+
+	if _, _, err := o.Verify(sets); err != nil {
+		report.fail(ExitValidation, "Oracle rejected proofs", "qtype", verifyFlags.Arg(0), "name", name, "err", err)
+	}
+	report.decide("Oracle accepted proofs", "version", o.Version(), "count", len(sets))
+	report.finish(ExitOK, "verified", fmt.Sprintf("Oracle accepts the proof of %s %s (%d proofs).", dns.TypeToString[qtype], name, len(sets)))
 }
 
 func claimCommand(args []string) {
@@ -600,24 +1247,31 @@ func claimCommand(args []string) {
 		claimFlags.PrintDefaults()
 	}
 	claimFlags.Parse(args)
+	startReport("claim", *claimOutput)
 
 	if claimFlags.NArg() != 1 {
 		claimFlags.Usage()
 		return
 	}
 
-	conn, err := ethclient.Dial(*rpc)
+	if *claimAddr != "" && *claimResolver == "" {
+		report.fail(ExitError, "-addr requires -resolver")
+	}
+
+	conn, err := dial()
 	if err != nil {
-		log.Crit("Error connecting to Ethereum node", "err", err)
-		os.Exit(1)
+		report.fail(ExitChain, "Error connecting to Ethereum node", "err", err)
 	}
 
-	name := claimFlags.Arg(0)
+	name, err := ens.ToASCII(claimFlags.Arg(0))
+	if err != nil {
+		report.fail(ExitError, "Invalid name", "err", err)
+	}
+	report.setTarget(0, name)
 
-	registry, err := ens.New(common.HexToAddress(*registryAddress), conn)
+	registry, err := openRegistry(conn, *registryAddress)
 	if err != nil {
-		log.Crit("Error instantiating registry", "err", err)
-		os.Exit(1)
+		report.fail(ExitChain, "Error instantiating registry", "err", err)
 	}
 
 	parentName := ""
@@ -627,61 +1281,127 @@ func claimCommand(args []string) {
 
 	addr, err := registry.Owner(parentName)
 	if err != nil {
-		log.Crit("Could not get ENS owner", "name", parentName, "err", err)
-		os.Exit(1)
+		report.fail(ExitChain, "Could not get ENS owner", "name", parentName, "err", err)
 	}
 
 	if addr == (common.Address{}) {
-		log.Crit("Name does not exist in ENS", "name", parentName)
-		os.Exit(1)
+		report.fail(ExitValidation, "Name does not exist in ENS", "name", parentName)
+	}
+
+	current, err := registry.Owner(name)
+	if err != nil {
+		report.fail(ExitChain, "Could not get ENS owner", "name", name, "err", err)
 	}
 
 	reg, err := registrar.New(addr, conn)
 	if err == nil {
-		if err := claimWithRegistrar(conn, name, reg); err != nil {
-			log.Crit("Error claiming name with registrar", "name", name, "error", err)
-			os.Exit(1)
+		report.decide("Claiming through DNS registrar", "registrar", addr.String(), "legacy", reg.Legacy())
+		if err := claimWithRegistrar(conn, name, current, reg); err != nil {
+			report.fail(exitCode(err), "Error claiming name with registrar", "name", name, "error", err)
 		}
-		return
+		report.finish(ExitOK, "sent", "")
 	} else if err != registrar.InterfaceNotSupportedError {
-		log.Crit("Could not instantiate DNSSEC registrar", "name", parentName, "err", err)
-		os.Exit(1)
+		report.fail(ExitChain, "Could not instantiate DNSSEC registrar", "name", parentName, "err", err)
 	}
 
 	root, err := root.New(addr, conn)
 	if err != nil {
-		log.Crit("Could not instantiate root contract", "name", parentName, "err", err)
-		os.Exit(1)
+		report.fail(ExitChain, "Could not instantiate root contract", "name", parentName, "err", err)
 	}
 
-	if err := claimWithRoot(conn, name, root); err != nil {
-		log.Crit("Error claiming name with root contract", "name", name, "error", err)
-		os.Exit(1)
+	report.decide("Claiming through root contract", "root", addr.String())
+	if err := claimWithRoot(conn, name, current, root); err != nil {
+		report.fail(exitCode(err), "Error claiming name with root contract", "name", name, "error", err)
+	}
+	report.finish(ExitOK, "sent", "")
+}
+
+// ownerInSync reports whether ENS already reflects DNS: set, the TXT record,
+// names current as the owner, or there is no record and no owner.
+func ownerInSync(current common.Address, set *proofs.SignedSet) bool {
+	if set == nil {
+		return current == (common.Address{})
+	}
+	owner, ok := registrar.ParseOwner(set.Rrs)
+	return ok && owner == current
+}
+
+// checkInSync ends the command if ENS already reflects the DNS record, unless
+// the claim would also set a resolver or -force is given.
+func checkInSync(current common.Address, set *proofs.SignedSet) {
+	if *claimForce || *claimResolver != "" || !ownerInSync(current, set) {
+		return
+	}
+	if set == nil {
+		report.decide("Name is not claimed in ENS and has no TXT record")
+		report.finish(ExitNothingToDo, "nothing-to-do", "Name is not claimed and has no TXT record. Nothing to do; exiting.")
+	} else {
+		report.decide("ENS owner already matches TXT record", "owner", current.String())
+		report.finish(ExitNothingToDo, "nothing-to-do", "ENS owner already matches DNS. Nothing to do; exiting.")
 	}
 }
 
-func claimWithRegistrar(conn *ethclient.Client, name string, registrar *registrar.DNSRegistrar) error {
-	sets, found, err := getProofs(dns.TypeTXT, "_ens."+name)
+func claimWithRegistrar(conn *chainClient, name string, current common.Address, registrar *registrar.DNSRegistrar) error {
+	paths, found, err := getProofPaths(dns.TypeTXT, "_ens."+name)
 	if err != nil {
-		return err
+		return withCode(proofExitCode(err), err)
+	}
+	o, err := registrar.GetOracle()
+	if err != nil {
+		return withCode(ExitChain, err)
+	}
+	sets, err := choosePath(o, paths)
+	if err != nil {
+		return withCode(ExitValidation, err)
+	}
+	report.setFound(found)
+	report.setChain(sets, 0)
+	if found {
+		checkInSync(current, &sets[len(sets)-1])
+	} else {
+		checkInSync(current, nil)
 	}
 
 	auth, err := makeTransactor(conn)
 	if err != nil {
-		log.Crit("Could not create transactor", "err", err)
-		os.Exit(1)
+		report.fail(ExitError, "Could not create transactor", "err", err)
 	}
 
 	if found {
-		tx, err := registrar.Claim(auth, name, sets)
+		if err := checkClaimOwner(name, sets[len(sets)-1], auth.From); err != nil {
+			return withCode(ExitValidation, err)
+		}
+
+		var tx *types.Transaction
+		if *claimResolver != "" && registrar.SupportsResolver() {
+			var resolver, addr common.Address
+			if resolver, err = resolveAddress(conn, *claimResolver); err != nil {
+				return withCode(ExitChain, err)
+			}
+			if *claimAddr != "" {
+				if addr, err = resolveAddress(conn, *claimAddr); err != nil {
+					return withCode(ExitChain, err)
+				}
+			}
+			report.decide("Claiming with resolver", "resolver", resolver.String(), "addr", addr.String())
+			tx, err = registrar.ClaimWithResolver(auth, name, sets, resolver, addr)
+		} else {
+			if *claimResolver != "" {
+				report.decide("Registrar cannot set a resolver when claiming; claiming without one", "resolver", *claimResolver)
+			}
+			tx, err = registrar.Claim(auth, name, sets)
+		}
 		if err != nil {
-			return err
+			return withCode(ExitChain, err)
 		}
+		report.addTransactions(tx)
 		log.Info("Sent transaction", "tx", tx.Hash().String())
 	} else {
+		report.decide("TXT record does not exist; unclaiming name")
 		txs, err := registrar.Unclaim(auth, name, sets)
+		report.addTransactions(txs...)
 		if err != nil {
-			return err
+			return withCode(ExitChain, err)
 		}
 		txids := make([]string, 0, len(txs))
 		for _, tx := range txs {
@@ -693,42 +1413,88 @@ func claimWithRegistrar(conn *ethclient.Client, name string, registrar *registra
 	return nil
 }
 
-func claimWithRoot(conn *ethclient.Client, name string, root *root.Root) error {
-	sets, found, err := getProofs(dns.TypeTXT, "_ens.nic."+name)
-	if err != nil && err != NotDNSSECEnabledError {
-		return err
+// checkClaimOwner reports who a claim will assign name to, and refuses to
+// proceed if that isn't the account paying for it, unless -force is set.
+func checkClaimOwner(name string, set proofs.SignedSet, from common.Address) error {
+	owner, ok := registrar.ParseOwner(set.Rrs)
+	if !ok {
+		if *claimForce {
+			log.Warn("TXT record names no owner; claiming anyway", "name", name, "record", set.Rrs[0].Header().Name)
+			return nil
+		}
+		return fmt.Errorf("TXT record %s does not contain an owner address of the form \"%s...\"; use -force to claim anyway", set.Rrs[0].Header().Name, registrar.OwnerPrefix)
 	}
 
+	log.Info("Name will be owned by", "name", name, "owner", owner.String())
+	if owner != from {
+		if *claimForce {
+			log.Warn("Claiming for a different account", "name", name, "owner", owner.String(), "from", from.String())
+			return nil
+		}
+		return fmt.Errorf("TXT record %s names owner %s, not the claiming account %s; use -force to claim anyway", set.Rrs[0].Header().Name, owner.String(), from.String())
+	}
+	return nil
+}
+
+func claimWithRoot(conn *chainClient, name string, current common.Address, root *root.Root) error {
+	paths, found, err := getProofPaths(dns.TypeTXT, "_ens.nic."+name)
+	if err != nil && !notDNSSECEnabled(err) {
+		return withCode(proofExitCode(err), err)
+	}
+	o, err := root.GetOracle()
+	if err != nil {
+		return withCode(ExitChain, err)
+	}
+	var sets []proofs.SignedSet
+	if paths != nil {
+		if sets, err = choosePath(o, paths); err != nil {
+			return withCode(ExitValidation, err)
+		}
+	}
+	report.setFound(found)
+	report.setChain(sets, 0)
+
 	if found {
+		checkInSync(current, &sets[len(sets)-1])
+
 		auth, err := makeTransactor(conn)
 		if err != nil {
-			log.Crit("Could not create transactor", "err", err)
-			os.Exit(1)
+			report.fail(ExitError, "Could not create transactor", "err", err)
+		}
+
+		if err := checkClaimOwner(name, sets[len(sets)-1], auth.From); err != nil {
+			return withCode(ExitValidation, err)
 		}
 
 		tx, err := root.Claim(auth, name, sets)
 		if err != nil {
-			return err
+			return withCode(ExitChain, err)
 		}
+		report.addTransactions(tx)
 		log.Info("Sent transaction", "tx", tx.Hash().String())
 	} else {
-		dssets, found, err := getProofs(dns.TypeDS, name)
+		dspaths, found, err := getProofPaths(dns.TypeDS, name)
 		if err != nil {
-			return err
+			return withCode(proofExitCode(err), err)
 		}
 		if !found {
-			return fmt.Errorf("Cannot claim name %s: Not found in DNS", name)
+			return withCode(ExitValidation, fmt.Errorf("Cannot claim name %s: Not found in DNS", name))
+		}
+		dssets, err := bestPath(o, dspaths)
+		if err != nil {
+			return withCode(ExitValidation, err)
 		}
+		report.decide("TXT record does not exist; assigning name to the default registrar")
 
 		auth, err := makeTransactor(conn)
 		if err != nil {
-			log.Crit("Could not create transactor", "err", err)
-			os.Exit(1)
+			report.fail(ExitError, "Could not create transactor", "err", err)
 		}
 
 		txs, err := root.ClaimDefault(auth, name, sets, dssets)
+		report.addTransactions(txs...)
 		if err != nil {
-			return err
+			return withCode(ExitChain, err)
 		}
 
 		txids := make([]string, 0, len(txs))
@@ -0,0 +1,376 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/arachnid/dnsprove/chaintest"
+	"github.com/arachnid/dnsprove/dnstest"
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/crypto"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	log.Root().SetHandler(log.DiscardHandler())
+}
+
+const testTXT = `_ens.example.com. 3600 IN TXT "a=0x0000000000000000000000000000000000000001"`
+
+// serve starts s and makes it the only resolver, with its root as the trust
+// anchor, until the test ends.
+func serve(t *testing.T, s *dnstest.Server) {
+	t.Helper()
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	savedResolvers, savedAnchors, savedZones := resolvers, trustAnchors, offlineZones
+	resolvers = []*resolver{{addr: s.URL}}
+	trustAnchors = s.Anchors()
+	offlineZones = nil
+	t.Cleanup(func() {
+		s.Close()
+		resolvers, trustAnchors, offlineZones = savedResolvers, savedAnchors, savedZones
+	})
+}
+
+// newExampleServer returns a server for com. and example.com., signed with
+// RSASHA256 so the default -algorithms validate it.
+func newExampleServer(t *testing.T) *dnstest.Server {
+	t.Helper()
+	s, err := dnstest.NewServer(dns.RSASHA256, "com.", "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestQueryWithProof(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(t *testing.T, s *dnstest.Server)
+		found bool
+		// sets is the length of the chain expected, or 0 for an error, in
+		// which case reason is part of why validation failed.
+		sets     int
+		reason   string
+		insecure string
+		// optOut is whether the insecure delegation is proven by NSEC3 opt-out.
+		optOut bool
+	}{
+		{
+			name: "valid chain",
+			setup: func(t *testing.T, s *dnstest.Server) {
+				if err := s.Zone("example.com.").Add(testTXT); err != nil {
+					t.Fatal(err)
+				}
+			},
+			found: true,
+			sets:  6,
+		},
+		{
+			name:  "NSEC denial",
+			setup: func(t *testing.T, s *dnstest.Server) {},
+			sets:  6,
+		},
+		{
+			name: "unsigned zone",
+			setup: func(t *testing.T, s *dnstest.Server) {
+				s.Zone("example.com.").Add(testTXT)
+				s.Zone("example.com.").SetUnsigned(true)
+			},
+			reason: "answer is unsigned",
+		},
+		{
+			name: "expired signatures",
+			setup: func(t *testing.T, s *dnstest.Server) {
+				s.Zone("example.com.").Add(testTXT)
+				s.Zone("example.com.").Expire()
+			},
+			reason: "Signature is only valid from",
+		},
+		{
+			name: "signed with the wrong key",
+			setup: func(t *testing.T, s *dnstest.Server) {
+				s.Zone("example.com.").Add(testTXT)
+				if err := s.Zone("example.com.").SignWithWrongKey(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason: "no valid keys found",
+		},
+		{
+			name: "missing DS",
+			setup: func(t *testing.T, s *dnstest.Server) {
+				zone, err := dnstest.NewZone("example.org.", dns.RSASHA256)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := zone.Add(`_ens.example.org. 3600 IN TXT "a=0x0000000000000000000000000000000000000001"`); err != nil {
+					t.Fatal(err)
+				}
+				org, err := dnstest.NewZone("org.", dns.RSASHA256)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := s.AddZone(org, true); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.AddZone(zone, false); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason:   "DS example.org. not found",
+			insecure: "example.org.",
+		},
+		{
+			// org.'s only NSEC3 record both matches it and covers example.org.
+			name:     "NSEC3 opt-out delegation",
+			setup:    optOutSetup(),
+			reason:   "DS example.org. not found",
+			insecure: "example.org.",
+			optOut:   true,
+		},
+		{
+			// b.org.'s NSEC3 record covers example.org.
+			name:     "NSEC3 opt-out delegation with two records",
+			setup:    optOutSetup("b.org."),
+			reason:   "DS example.org. not found",
+			insecure: "example.org.",
+			optOut:   true,
+		},
+		{
+			name: "unsupported NSEC3 denial",
+			setup: func(t *testing.T, s *dnstest.Server) {
+				s.Zone("example.com.").SetOptOut(true)
+			},
+			reason: "NSEC3 records deny the name in a form that is not supported",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newExampleServer(t)
+			tc.setup(t, s)
+			serve(t, s)
+
+			name := "_ens.example.com."
+			if tc.insecure != "" {
+				name = "_ens." + tc.insecure
+			}
+			client := NewClient(resolvers, trustAnchors, map[uint8]struct{}{dns.RSASHA256: {}}, map[uint8]struct{}{dns.SHA256: {}})
+			client.trace = &traceNode{}
+			sets, found, err := client.QueryWithProof(dns.TypeTXT, dns.ClassINET, name)
+			if tc.sets == 0 {
+				if err == nil {
+					t.Fatalf("validated a chain of %d sets, want an error", len(sets))
+				}
+				if !rejectedFor(client.trace, tc.reason) {
+					var trace strings.Builder
+					renderTrace(&trace, client.trace)
+					t.Errorf("no step rejected for %q:\n%s", tc.reason, trace.String())
+				}
+				if tc.insecure != "" {
+					e, _ := client.findInsecureDelegation(name, err)
+					if e == nil || e.Zone != tc.insecure {
+						t.Fatalf("insecure delegation = %v, want one at %s", e, tc.insecure)
+					}
+					if e.OptOut() != tc.optOut {
+						t.Errorf("opt-out = %v, want %v", e.OptOut(), tc.optOut)
+					}
+					if !deniesDS(e.Proof, e.Zone) {
+						t.Errorf("proof for %s does not deny its DS", e.Zone)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != tc.found {
+				t.Errorf("found = %v, want %v", found, tc.found)
+			}
+			if len(sets) != tc.sets {
+				t.Errorf("got a chain of %d sets, want %d", len(sets), tc.sets)
+			}
+			last := sets[len(sets)-1].Rrs[0].Header().Rrtype
+			if want := map[bool]uint16{true: dns.TypeTXT, false: dns.TypeNSEC}[tc.found]; last != want {
+				t.Errorf("chain ends with %s, want %s", dns.TypeToString[last], dns.TypeToString[want])
+			}
+		})
+	}
+}
+
+func TestQueryWithProofAlgorithms(t *testing.T) {
+	savedAlgorithms, savedHashes := *algorithms, *hashes
+	defer func() { *algorithms, *hashes = savedAlgorithms, savedHashes }()
+	*hashes = "SHA256"
+
+	for _, alg := range dnstest.Algorithms {
+		alg := alg
+		t.Run(dns.AlgorithmToString[alg], func(t *testing.T) {
+			s, err := dnstest.NewServer(alg, "com.", "example.com.")
+			if err != nil {
+				t.Fatal(err)
+			}
+			serve(t, s)
+
+			// Each zone is signed with alg, so nothing validates without it.
+			other := "RSASHA256"
+			if alg == dns.RSASHA256 {
+				other = "ECDSAP256SHA256"
+			}
+			*algorithms = other
+			if _, _, err := getProofs(dns.TypeTXT, "_ens.example.com"); err == nil {
+				t.Errorf("validated with -algorithms %s", other)
+			}
+
+			*algorithms = dns.AlgorithmToString[alg]
+			sets, found, err := getProofs(dns.TypeTXT, "_ens.example.com")
+			if err != nil {
+				t.Fatalf("NSEC denial: %v", err)
+			}
+			if found || len(sets) != 6 || sets[5].Rrs[0].Header().Rrtype != dns.TypeNSEC {
+				t.Errorf("NSEC denial: found = %v with a chain of %d sets", found, len(sets))
+			}
+
+			if err := s.Zone("example.com.").Add(testTXT); err != nil {
+				t.Fatal(err)
+			}
+			sets, found, err = getProofs(dns.TypeTXT, "_ens.example.com")
+			if err != nil {
+				t.Fatalf("valid chain: %v", err)
+			}
+			if !found || len(sets) != 6 {
+				t.Errorf("valid chain: found = %v with a chain of %d sets", found, len(sets))
+			}
+			for _, set := range sets {
+				if set.Sig.Algorithm != alg {
+					t.Errorf("%s %s is signed with %s", dns.TypeToString[set.Sig.TypeCovered], set.Name, dns.AlgorithmToString[set.Sig.Algorithm])
+				}
+			}
+		})
+	}
+}
+
+// optOutSetup returns a setup function that makes org. deny records with NSEC3
+// opt-out proofs, and adds example.org. to it insecurely and each of secure
+// securely.
+func optOutSetup(secure ...string) func(t *testing.T, s *dnstest.Server) {
+	return func(t *testing.T, s *dnstest.Server) {
+		org, err := dnstest.NewZone("org.", dns.RSASHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		org.SetOptOut(true)
+		if err := s.AddZone(org, true); err != nil {
+			t.Fatal(err)
+		}
+		for _, origin := range append(secure, "example.org.") {
+			zone, err := dnstest.NewZone(origin, dns.RSASHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AddZone(zone, origin != "example.org."); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// rejectedFor reports whether a step in the trace was rejected with a reason
+// containing reason.
+func rejectedFor(node *traceNode, reason string) bool {
+	if node.Result == traceRejected && strings.Contains(node.Reason, reason) {
+		return true
+	}
+	for _, child := range node.Children {
+		if rejectedFor(child, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDecideProve(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// inOracle is whether the TXT record is submitted to the oracle, and
+		// inDNS whether it is still in DNS when prove runs.
+		inOracle, inDNS bool
+		action          string
+		message         string
+	}{
+		{"new record", false, true, proveSubmit, ""},
+		{"record already in oracle", true, true, proveNothing, "Nothing to do; exiting."},
+		{"record deleted from DNS", true, false, proveDelete, ""},
+		{"record in neither", false, false, proveNothing, "RRSet not found in oracle. Nothing to do; exiting"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newExampleServer(t)
+			serve(t, s)
+
+			absent, found, err := getProofs(dns.TypeTXT, "_ens.example.com")
+			if err != nil || found {
+				t.Fatalf("proving absence: found = %v, err = %v", found, err)
+			}
+			if err := s.Zone("example.com.").Add(testTXT); err != nil {
+				t.Fatal(err)
+			}
+			present, found, err := getProofs(dns.TypeTXT, "_ens.example.com")
+			if err != nil || !found {
+				t.Fatalf("proving record: found = %v, err = %v", found, err)
+			}
+
+			b := chaintest.NewBackend()
+			addr, err := b.DeployOracle(s.Anchors()[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			o, err := oracle.New(addr, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.inOracle {
+				key, _ := crypto.GenerateKey()
+				opts := bind.NewKeyedTransactor(key)
+				opts.Nonce = big.NewInt(0)
+				if _, err := o.SendProofs(opts, present, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sets, found := present, true
+			if !tc.inDNS {
+				sets, found = absent, false
+			}
+			decision, err := decideProve(o, dns.TypeTXT, "_ens.example.com.", sets, found)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.action != tc.action {
+				t.Errorf("action = %s (%s), want %s", decision.action, decision.reason, tc.action)
+			}
+			if decision.message != tc.message {
+				t.Errorf("message = %q, want %q", decision.message, tc.message)
+			}
+			if decision.action == proveDelete && decision.known != len(sets)-1 {
+				t.Errorf("oracle knows %d of %d sets, want all but the NSEC", decision.known, len(sets))
+			}
+		})
+	}
+}
+
+func TestMakeTransactorMissingKeyfile(t *testing.T) {
+	saved := *keyfile
+	*keyfile = t.TempDir() + "/missing"
+	defer func() { *keyfile = saved }()
+	// The error is returned, so -output json can report it.
+	if auth, err := makeTransactor(nil); err == nil {
+		t.Errorf("makeTransactor = %v, want an error", auth)
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package dnstest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Server answers queries for a set of zones, as a validating resolver's
+// upstream would, always including signatures. It serves them over UDP and
+// TCP, and over DNS-over-HTTPS (without TLS) at /dns-query.
+type Server struct {
+	// Addr is the address of the UDP and TCP servers.
+	Addr string
+	// URL is the DNS-over-HTTPS endpoint, suitable for the -server flag.
+	URL string
+
+	mu    sync.Mutex
+	zones map[string]*Zone
+	root  *Zone
+
+	udp  *dns.Server
+	tcp  *dns.Server
+	http *http.Server
+}
+
+// NewServer returns a server for a root zone signed with algorithm, and for
+// zones at each of origins signed the same way. Each zone is securely
+// delegated from the closest zone above it, so origins must list parents
+// before their children.
+func NewServer(algorithm uint8, origins ...string) (*Server, error) {
+	root, err := NewZone(".", algorithm)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{zones: make(map[string]*Zone), root: root}
+	s.zones["."] = root
+
+	for _, origin := range origins {
+		zone, err := NewZone(origin, algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.AddZone(zone, true); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// AddZone adds zone to the server, delegating to it from the closest zone
+// above it, securely or not.
+func (s *Server) AddZone(zone *Zone, secure bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.zones[zone.Origin]; ok {
+		return fmt.Errorf("zone %s already exists", zone.Origin)
+	}
+
+	parent := s.closest(zone.Origin, false)
+	var err error
+	if secure {
+		err = parent.Delegate(zone)
+	} else {
+		err = parent.DelegateInsecure(zone)
+	}
+	if err != nil {
+		return err
+	}
+	s.zones[zone.Origin] = zone
+	return nil
+}
+
+// Zone returns the zone with the given origin, or nil.
+func (s *Server) Zone(origin string) *Zone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.zones[strings.ToLower(dns.Fqdn(origin))]
+}
+
+// Root returns the root zone.
+func (s *Server) Root() *Zone {
+	return s.root
+}
+
+// Anchors returns trust anchors for the root zone.
+func (s *Server) Anchors() []*dns.DS {
+	return []*dns.DS{s.root.KSK.DS(dns.SHA256)}
+}
+
+// closest returns the zone that is authoritative for name. If apex is false,
+// a zone whose origin is name is passed over for its parent, as is right for
+// DS queries and delegations.
+func (s *Server) closest(name string, apex bool) *Zone {
+	labels := dns.SplitDomainName(name)
+	for i := 0; i <= len(labels); i++ {
+		origin := dns.Fqdn(strings.Join(labels[i:], "."))
+		if !apex && origin == name && name != "." {
+			continue
+		}
+		if zone, ok := s.zones[origin]; ok {
+			return zone
+		}
+	}
+	return s.root
+}
+
+// Answer returns the response to a query.
+func (s *Server) Answer(req *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	if len(req.Question) != 1 {
+		return resp.SetRcode(req, dns.RcodeFormatError)
+	}
+	resp.SetReply(req)
+	resp.Authoritative = true
+	if opt := req.IsEdns0(); opt != nil {
+		resp.SetEdns0(opt.UDPSize(), true)
+	}
+
+	q := req.Question[0]
+	name := strings.ToLower(dns.Fqdn(q.Name))
+
+	s.mu.Lock()
+	zone := s.closest(name, q.Qtype != dns.TypeDS)
+	s.mu.Unlock()
+
+	zone.mu.Lock()
+	defer zone.mu.Unlock()
+
+	var err error
+	if rrs := zone.lookup(name, q.Qtype); len(rrs) > 0 {
+		resp.Answer, err = zone.signed(rrs)
+	} else {
+		if !zone.exists(name) {
+			resp.Rcode = dns.RcodeNameError
+		}
+		resp.Ns, err = zone.denial(name)
+	}
+	if err != nil {
+		return resp.SetRcode(req, dns.RcodeServerFailure)
+	}
+	return resp
+}
+
+// ServeDNS implements dns.Handler.
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := s.Answer(req)
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		resp.Truncate(size)
+	}
+	w.WriteMsg(resp)
+}
+
+// ServeHTTP answers DNS-over-HTTPS queries, in either the RFC 8484 format or
+// the older application/dns-udpwireformat one.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		data, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+	case http.MethodPost:
+		data, err = ioutil.ReadAll(r.Body)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := new(dns.Msg)
+	if err := req.Unpack(data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out, err := s.Answer(req).Pack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/dns-message")
+	w.Write(out)
+}
+
+// Start listens on random local ports and serves until Close is called.
+func (s *Server) Start() error {
+	pc, l, err := listenUDPAndTCP()
+	if err != nil {
+		return err
+	}
+	hl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		pc.Close()
+		l.Close()
+		return err
+	}
+
+	s.Addr = pc.LocalAddr().String()
+	s.URL = "http://" + hl.Addr().String() + "/dns-query"
+
+	var started sync.WaitGroup
+	started.Add(2)
+	s.udp = &dns.Server{PacketConn: pc, Handler: s, NotifyStartedFunc: started.Done}
+	s.tcp = &dns.Server{Listener: l, Handler: s, NotifyStartedFunc: started.Done}
+	go s.udp.ActivateAndServe()
+	go s.tcp.ActivateAndServe()
+
+	mux := http.NewServeMux()
+	mux.Handle("/dns-query", s)
+	s.http = &http.Server{Handler: mux}
+	go s.http.Serve(hl)
+
+	started.Wait()
+	return nil
+}
+
+// listenUDPAndTCP listens for UDP and TCP on the same random local port.
+// The UDP port is chosen first, and may already be taken for TCP, so this
+// tries a few times.
+func listenUDPAndTCP() (net.PacketConn, net.Listener, error) {
+	var err error
+	for i := 0; i < 10; i++ {
+		var pc net.PacketConn
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			return nil, nil, err
+		}
+		var l net.Listener
+		if l, err = net.Listen("tcp", pc.LocalAddr().String()); err == nil {
+			return pc, l, nil
+		}
+		pc.Close()
+	}
+	return nil, nil, err
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	var errs []string
+	for _, srv := range []*dns.Server{s.udp, s.tcp} {
+		if srv != nil {
+			if err := srv.Shutdown(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if s.http != nil {
+		if err := s.http.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("closing server: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
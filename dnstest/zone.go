@@ -0,0 +1,444 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dnstest builds DNSSEC-signed zones with freshly generated keys and
+// serves them over DNS and DNS-over-HTTPS from within the process, so proofs
+// can be built and checked without network access.
+package dnstest
+
+import (
+	"crypto"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Algorithms lists every signing algorithm zones can be generated with.
+var Algorithms = []uint8{
+	dns.RSASHA1,
+	dns.RSASHA1NSEC3SHA1,
+	dns.RSASHA256,
+	dns.RSASHA512,
+	dns.ECDSAP256SHA256,
+	dns.ECDSAP384SHA384,
+	dns.ED25519,
+}
+
+// keyBits returns the key size to generate for an algorithm. RSA keys are
+// kept small so tests run quickly.
+func keyBits(algorithm uint8) int {
+	switch algorithm {
+	case dns.ECDSAP384SHA384:
+		return 384
+	case dns.ECDSAP256SHA256, dns.ED25519:
+		return 256
+	default:
+		return 1024
+	}
+}
+
+// TTL is the TTL given to generated records.
+const TTL = 3600
+
+// Key is a DNSKEY and its private key.
+type Key struct {
+	DNSKEY *dns.DNSKEY
+	signer crypto.Signer
+}
+
+// NewKey generates a key for origin. KSKs have the SEP flag set.
+func NewKey(origin string, algorithm uint8, ksk bool) (*Key, error) {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: dns.Fqdn(origin), Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: TTL},
+		Flags:     dns.ZONE,
+		Protocol:  3,
+		Algorithm: algorithm,
+	}
+	if ksk {
+		key.Flags |= dns.SEP
+	}
+	priv, err := key.Generate(keyBits(algorithm))
+	if err != nil {
+		return nil, fmt.Errorf("generating %s key for %s: %v", dns.AlgorithmToString[algorithm], origin, err)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s keys cannot sign", dns.AlgorithmToString[algorithm])
+	}
+	return &Key{key, signer}, nil
+}
+
+// DS returns a DS record for the key, using the given digest type.
+func (k *Key) DS(digest uint8) *dns.DS {
+	ds := k.DNSKEY.ToDS(digest)
+	ds.Hdr.Ttl = TTL
+	return ds
+}
+
+// Zone is a DNS zone with its own key signing and zone signing keys. Its
+// records are signed as they are served, so scenarios can be changed at any
+// time.
+type Zone struct {
+	Origin string
+	KSK    *Key
+	ZSK    *Key
+
+	mu         sync.Mutex
+	records    []dns.RR
+	unsigned   bool
+	optOut     bool
+	signingKey *Key
+	inception  time.Time
+	expiration time.Time
+}
+
+// NewZone creates a zone with an SOA record and newly generated keys.
+func NewZone(origin string, algorithm uint8) (*Zone, error) {
+	origin = strings.ToLower(dns.Fqdn(origin))
+	ksk, err := NewKey(origin, algorithm, true)
+	if err != nil {
+		return nil, err
+	}
+	zsk, err := NewKey(origin, algorithm, false)
+	if err != nil {
+		return nil, err
+	}
+
+	z := &Zone{
+		Origin:     origin,
+		KSK:        ksk,
+		ZSK:        zsk,
+		inception:  time.Now().Add(-time.Hour),
+		expiration: time.Now().Add(30 * 24 * time.Hour),
+	}
+	// Joined this way, the root's names are ns. and hostmaster., not ns..
+	host := strings.TrimSuffix(origin, ".")
+	soa := &dns.SOA{
+		Hdr:     dns.RR_Header{Name: origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: TTL},
+		Ns:      dns.Fqdn("ns." + host),
+		Mbox:    dns.Fqdn("hostmaster." + host),
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  TTL,
+	}
+	z.AddRR(soa)
+	return z, nil
+}
+
+// Add parses records in zone file format and adds them to the zone. Names
+// must be absolute.
+func (z *Zone) Add(records ...string) error {
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			return err
+		}
+		if rr == nil {
+			return fmt.Errorf("no record in %q", record)
+		}
+		if !dns.IsSubDomain(z.Origin, rr.Header().Name) {
+			return fmt.Errorf("record %s is not in zone %s", rr.Header().Name, z.Origin)
+		}
+		z.AddRR(rr)
+	}
+	return nil
+}
+
+// AddRR adds records to the zone.
+func (z *Zone) AddRR(rrs ...dns.RR) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for _, rr := range rrs {
+		rr.Header().Name = strings.ToLower(rr.Header().Name)
+		z.records = append(z.records, rr)
+	}
+}
+
+// Delegate adds NS and DS records to z that make child a secure delegation.
+func (z *Zone) Delegate(child *Zone) error {
+	if err := z.DelegateInsecure(child); err != nil {
+		return err
+	}
+	z.AddRR(child.KSK.DS(dns.SHA256))
+	return nil
+}
+
+// DelegateInsecure adds an NS record for child to z, without a DS record, so
+// child's keys cannot be validated.
+func (z *Zone) DelegateInsecure(child *Zone) error {
+	if child.Origin == z.Origin || !dns.IsSubDomain(z.Origin, child.Origin) {
+		return fmt.Errorf("%s is not a child of %s", child.Origin, z.Origin)
+	}
+	z.AddRR(&dns.NS{
+		Hdr: dns.RR_Header{Name: child.Origin, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: TTL},
+		Ns:  "ns." + child.Origin,
+	})
+	return nil
+}
+
+// SetUnsigned controls whether the zone's records are served without
+// signatures.
+func (z *Zone) SetUnsigned(unsigned bool) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.unsigned = unsigned
+}
+
+// SetOptOut controls whether the zone denies records with NSEC3 opt-out
+// proofs rather than NSEC records. Its insecure delegations are left out of
+// the NSEC3 chain, as RFC 5155 section 6 allows.
+func (z *Zone) SetOptOut(optOut bool) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.optOut = optOut
+}
+
+// SetValidity sets the inception and expiration of signatures made from now
+// on.
+func (z *Zone) SetValidity(inception, expiration time.Time) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.inception, z.expiration = inception, expiration
+}
+
+// Expire makes the zone's signatures expire a day ago.
+func (z *Zone) Expire() {
+	z.SetValidity(time.Now().Add(-30*24*time.Hour), time.Now().Add(-24*time.Hour))
+}
+
+// SignWithWrongKey signs the zone's records, other than its DNSKEYs, with a
+// newly generated key that is not published in the zone.
+func (z *Zone) SignWithWrongKey() error {
+	key, err := NewKey(z.Origin, z.ZSK.DNSKEY.Algorithm, false)
+	if err != nil {
+		return err
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.signingKey = key
+	return nil
+}
+
+// all returns every record in the zone, including its keys.
+func (z *Zone) all() []dns.RR {
+	return append([]dns.RR{z.KSK.DNSKEY, z.ZSK.DNSKEY}, z.records...)
+}
+
+// Lookup returns the records of type qtype at name.
+func (z *Zone) Lookup(name string, qtype uint16) []dns.RR {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.lookup(strings.ToLower(dns.Fqdn(name)), qtype)
+}
+
+func (z *Zone) lookup(name string, qtype uint16) []dns.RR {
+	var ret []dns.RR
+	for _, rr := range z.all() {
+		if rr.Header().Name == name && rr.Header().Rrtype == qtype {
+			ret = append(ret, dns.Copy(rr))
+		}
+	}
+	return ret
+}
+
+// exists reports whether the zone has any records at name.
+func (z *Zone) exists(name string) bool {
+	for _, rr := range z.all() {
+		if rr.Header().Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns a signature over rrset. DNSKEY sets are signed by the KSK and
+// everything else by the ZSK, or the wrong key if one has been set.
+func (z *Zone) Sign(rrset []dns.RR) (*dns.RRSIG, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.sign(rrset)
+}
+
+func (z *Zone) sign(rrset []dns.RR) (*dns.RRSIG, error) {
+	header := rrset[0].Header()
+	key := z.ZSK
+	if header.Rrtype == dns.TypeDNSKEY {
+		key = z.KSK
+	} else if z.signingKey != nil {
+		key = z.signingKey
+	}
+
+	sig := &dns.RRSIG{
+		Hdr:         dns.RR_Header{Name: header.Name, Rrtype: dns.TypeRRSIG, Class: header.Class, Ttl: header.Ttl},
+		TypeCovered: header.Rrtype,
+		Algorithm:   key.DNSKEY.Algorithm,
+		Labels:      uint8(dns.CountLabel(header.Name)),
+		OrigTtl:     header.Ttl,
+		Inception:   uint32(z.inception.Unix()),
+		Expiration:  uint32(z.expiration.Unix()),
+		KeyTag:      key.DNSKEY.KeyTag(),
+		SignerName:  z.Origin,
+	}
+	if err := sig.Sign(key.signer, rrset); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// denial returns the signed records proving that name has no records of the
+// type asked for.
+func (z *Zone) denial(name string) ([]dns.RR, error) {
+	if !z.optOut {
+		return z.signed([]dns.RR{z.nsec(name)})
+	}
+	var ret []dns.RR
+	for _, rr := range z.nsec3(name) {
+		rrs, err := z.signed([]dns.RR{rr})
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, rrs...)
+	}
+	return ret, nil
+}
+
+// signed returns rrset followed by its signature, unless the zone is unsigned.
+func (z *Zone) signed(rrset []dns.RR) ([]dns.RR, error) {
+	if z.unsigned || len(rrset) == 0 {
+		return rrset, nil
+	}
+	sig, err := z.sign(rrset)
+	if err != nil {
+		return nil, err
+	}
+	return append(rrset, sig), nil
+}
+
+// canonicalLess orders names as in RFC 4034 section 6.1: by their labels,
+// compared from the root down, ignoring case.
+func canonicalLess(a, b string) bool {
+	alabels := dns.SplitDomainName(strings.ToLower(a))
+	blabels := dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(alabels) && i <= len(blabels); i++ {
+		if c := strings.Compare(alabels[len(alabels)-i], blabels[len(blabels)-i]); c != 0 {
+			return c < 0
+		}
+	}
+	return len(alabels) < len(blabels)
+}
+
+// nsec returns the NSEC record proving that name has no records of the type
+// asked for: either the one at name itself, or the one covering it.
+func (z *Zone) nsec(name string) *dns.NSEC {
+	types := make(map[string]map[uint16]bool)
+	for _, rr := range z.all() {
+		owner := rr.Header().Name
+		if types[owner] == nil {
+			types[owner] = make(map[uint16]bool)
+		}
+		types[owner][rr.Header().Rrtype] = true
+	}
+
+	owners := make([]string, 0, len(types))
+	for owner := range types {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return canonicalLess(owners[i], owners[j]) })
+
+	i := sort.Search(len(owners), func(i int) bool { return canonicalLess(name, owners[i]) }) - 1
+	if i < 0 {
+		i = len(owners) - 1
+	}
+	owner, next := owners[i], owners[(i+1)%len(owners)]
+
+	bitmap := []uint16{dns.TypeNSEC, dns.TypeRRSIG}
+	for t := range types[owner] {
+		bitmap = append(bitmap, t)
+	}
+	sort.Slice(bitmap, func(i, j int) bool { return bitmap[i] < bitmap[j] })
+
+	return &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: TTL},
+		NextDomain: next,
+		TypeBitMap: bitmap,
+	}
+}
+
+// nsec3 returns the opt-out NSEC3 records proving that name has no records of
+// the type asked for: either the one matching name, or one matching its
+// closest encloser and one covering the next closer name (RFC 5155 section
+// 7.2.1). Hashes are SHA-1, unsalted, with no extra iterations.
+func (z *Zone) nsec3(name string) []*dns.NSEC3 {
+	types := make(map[string]map[uint16]bool)
+	for _, rr := range z.all() {
+		owner := rr.Header().Name
+		if types[owner] == nil {
+			types[owner] = make(map[uint16]bool)
+		}
+		types[owner][rr.Header().Rrtype] = true
+	}
+
+	chain := make(map[string]map[uint16]bool)
+	for owner, t := range types {
+		if owner != z.Origin && t[dns.TypeNS] && !t[dns.TypeDS] {
+			continue
+		}
+		chain[dns.HashName(owner, dns.SHA1, 0, "")] = t
+	}
+	hashes := make([]string, 0, len(chain))
+	for hash := range chain {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	record := func(i int) *dns.NSEC3 {
+		hash := hashes[i]
+		bitmap := []uint16{dns.TypeRRSIG}
+		for t := range chain[hash] {
+			bitmap = append(bitmap, t)
+		}
+		sort.Slice(bitmap, func(i, j int) bool { return bitmap[i] < bitmap[j] })
+		return &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: strings.ToLower(hash) + "." + z.Origin, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: TTL},
+			Hash:       dns.SHA1,
+			Flags:      1,
+			HashLength: 20,
+			NextDomain: hashes[(i+1)%len(hashes)],
+			TypeBitMap: bitmap,
+		}
+	}
+	// covering returns the record whose span includes hash.
+	covering := func(hash string) *dns.NSEC3 {
+		i := sort.SearchStrings(hashes, hash) - 1
+		if i < 0 {
+			i = len(hashes) - 1
+		}
+		return record(i)
+	}
+
+	if hash := dns.HashName(name, dns.SHA1, 0, ""); chain[hash] != nil {
+		return []*dns.NSEC3{record(sort.SearchStrings(hashes, hash))}
+	}
+	labels := dns.SplitDomainName(name)
+	for i := 1; i <= len(labels); i++ {
+		encloser := dns.Fqdn(strings.Join(labels[i:], "."))
+		hash := dns.HashName(encloser, dns.SHA1, 0, "")
+		if _, ok := chain[hash]; !ok {
+			continue
+		}
+		ce := record(sort.SearchStrings(hashes, hash))
+		nc := covering(dns.HashName(dns.Fqdn(strings.Join(labels[i-1:], ".")), dns.SHA1, 0, ""))
+		if ce.Hdr.Name == nc.Hdr.Name {
+			return []*dns.NSEC3{ce}
+		}
+		return []*dns.NSEC3{ce, nc}
+	}
+	return nil
+}
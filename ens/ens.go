@@ -7,31 +7,32 @@ package ens
 //go:generate abigen --sol ../contracts/resolver.sol --pkg contracts --out ../contracts/resolver.go
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/arachnid/dnsprove/contracts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 
 	"golang.org/x/crypto/sha3"
 )
 
-type Resolver struct {
-	resolver *contracts.Resolver
-	backend  bind.ContractBackend
-	node     common.Hash
-}
-
-func (r *Resolver) Addr() (common.Address, error) {
-	return r.resolver.Addr(nil, r.node)
-}
-
 type ENS struct {
 	ens     *contracts.ENS
 	backend bind.ContractBackend
 }
 
-func Namehash(name string) common.Hash {
+// Namehash returns the ENS node for name, after normalizing it.
+func Namehash(name string) (common.Hash, error) {
+	normalized, err := Normalize(name)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return namehash(normalized), nil
+}
+
+func namehash(name string) common.Hash {
 	if name == "" {
 		return common.Hash{}
 	}
@@ -44,7 +45,7 @@ func Namehash(name string) common.Hash {
 
 	parent := common.Hash{}
 	if len(parts) > 1 {
-		parent = Namehash(parts[1])
+		parent = namehash(parts[1])
 	}
 
 	h = sha3.NewLegacyKeccak256()
@@ -66,16 +67,25 @@ func New(addr common.Address, backend bind.ContractBackend) (*ENS, error) {
 }
 
 func (e *ENS) Owner(name string) (common.Address, error) {
-	h := Namehash(name)
+	h, err := Namehash(name)
+	if err != nil {
+		return common.Address{}, err
+	}
 	return e.ens.Owner(nil, h)
 }
 
 func (e *ENS) Resolver(name string) (*Resolver, error) {
-	h := Namehash(name)
+	h, err := Namehash(name)
+	if err != nil {
+		return nil, err
+	}
 	addr, err := e.ens.Resolver(nil, h)
 	if err != nil {
 		return nil, err
 	}
+	if addr == (common.Address{}) {
+		return nil, NoResolverError
+	}
 
 	contract, err := contracts.NewResolver(addr, e.backend)
 	if err != nil {
@@ -84,7 +94,71 @@ func (e *ENS) Resolver(name string) (*Resolver, error) {
 
 	return &Resolver{
 		contract,
+		addr,
 		e.backend,
 		h,
 	}, nil
 }
+
+// ResolverAddress returns the address of name's resolver, which is zero if it
+// has none.
+func (e *ENS) ResolverAddress(name string) (common.Address, error) {
+	h, err := Namehash(name)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return e.ens.Resolver(nil, h)
+}
+
+func (e *ENS) TTL(name string) (uint64, error) {
+	h, err := Namehash(name)
+	if err != nil {
+		return 0, err
+	}
+	return e.ens.Ttl(nil, h)
+}
+
+// SetOwner transfers ownership of name to owner.
+func (e *ENS) SetOwner(opts *bind.TransactOpts, name string, owner common.Address) (*types.Transaction, error) {
+	h, err := Namehash(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.ens.SetOwner(opts, h, owner)
+}
+
+func (e *ENS) SetResolver(opts *bind.TransactOpts, name string, resolver common.Address) (*types.Transaction, error) {
+	h, err := Namehash(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.ens.SetResolver(opts, h, resolver)
+}
+
+// SetSubnodeOwner creates or reassigns name, which must have a parent, on
+// behalf of the parent's owner.
+func (e *ENS) SetSubnodeOwner(opts *bind.TransactOpts, name string, owner common.Address) (*types.Transaction, error) {
+	normalized, err := Normalize(name)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(normalized, ".", 2)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("Name %q has no parent to create it under", name)
+	}
+
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(parts[0]))
+	var label [32]byte
+	copy(label[:], h.Sum(nil))
+
+	return e.ens.SetSubnodeOwner(opts, namehash(parts[1]), label, owner)
+}
+
+func (e *ENS) SetTTL(opts *bind.TransactOpts, name string, ttl uint64) (*types.Transaction, error) {
+	h, err := Namehash(name)
+	if err != nil {
+		return nil, err
+	}
+	return e.ens.SetTTL(opts, h, ttl)
+}
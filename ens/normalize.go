@@ -0,0 +1,139 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package ens
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// profile applies UTS-46 non-transitional mapping and validation, as ENSIP-15
+// does. STD3 rules are off so DNS names such as _ens.example.com are allowed;
+// underscores are instead restricted to the start of a label, as in ENSIP-15.
+var profile = idna.New(
+	idna.MapForLookup(),
+	idna.StrictDomainName(false),
+	idna.BidiRule(),
+)
+
+// NameError explains why a name could not be normalized.
+type NameError struct {
+	Name   string
+	Label  string
+	Reason string
+}
+
+func (e *NameError) Error() string {
+	if e.Label == "" {
+		return fmt.Sprintf("invalid name %q: %s", e.Name, e.Reason)
+	}
+	return fmt.Sprintf("invalid name %q: label %q %s", e.Name, e.Label, e.Reason)
+}
+
+// Normalize returns the normalized ENS form of name: mapped and validated
+// according to UTS-46, and without a trailing dot. Punycode A-labels are
+// checked but kept as they are, since ENSIP-15 does not decode them. The root
+// is the empty string.
+func Normalize(name string) (string, error) {
+	trimmed := strings.TrimSuffix(name, ".")
+	if trimmed == "" {
+		return "", nil
+	}
+	if !utf8.ValidString(trimmed) {
+		return "", &NameError{Name: name, Reason: "is not valid UTF-8"}
+	}
+
+	labels := strings.Split(trimmed, ".")
+	for i, label := range labels {
+		if label == "" {
+			return "", &NameError{Name: name, Reason: "contains an empty label"}
+		}
+		u, err := profile.ToUnicode(label)
+		if err != nil {
+			return "", &NameError{Name: name, Label: label, Reason: describe(err)}
+		}
+		if strings.Contains(strings.TrimLeft(u, "_"), "_") {
+			return "", &NameError{Name: name, Label: label, Reason: "has an underscore after the start of the label"}
+		}
+		if strings.HasPrefix(u, "xn--") {
+			return "", &NameError{Name: name, Label: label, Reason: "is not valid punycode"}
+		}
+		if strings.HasPrefix(strings.ToLower(label), "xn--") {
+			u = strings.ToLower(label)
+		}
+		labels[i] = u
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToASCII returns name normalized and converted to the A-label form used in
+// DNS, without a trailing dot. Names imported from DNS are registered in this
+// form, so it is the one to hash for them.
+func ToASCII(name string) (string, error) {
+	normalized, err := Normalize(name)
+	if err != nil {
+		return "", err
+	}
+	if normalized == "" {
+		return "", nil
+	}
+
+	labels := strings.Split(normalized, ".")
+	for i, label := range labels {
+		a, err := profile.ToASCII(label)
+		if err != nil {
+			return "", &NameError{Name: name, Label: label, Reason: describe(err)}
+		}
+		labels[i] = a
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// DNSName converts the U-labels in name to the A-labels used in DNS, without
+// a trailing dot. ASCII labels are passed through unchanged, since DNS allows
+// labels such as foo_bar and -foo that ENS names may not contain.
+func DNSName(name string) (string, error) {
+	trimmed := strings.TrimSuffix(name, ".")
+	if trimmed == "" {
+		return "", nil
+	}
+
+	labels := strings.Split(trimmed, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		a, err := profile.ToASCII(label)
+		if err != nil {
+			return "", &NameError{Name: name, Label: label, Reason: describe(err)}
+		}
+		labels[i] = a
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(err error) string {
+	reason := strings.TrimPrefix(err.Error(), "idna: ")
+	switch {
+	case strings.HasPrefix(reason, "disallowed rune"):
+		return "contains a character not permitted in names (" + strings.TrimPrefix(reason, "disallowed rune ") + ")"
+	case strings.HasPrefix(reason, "invalid label"):
+		return "breaks a label rule, such as a hyphen at the start or end or a misplaced combining mark"
+	case strings.Contains(reason, "bidirule"):
+		return "mixes left-to-right and right-to-left text in a way that is not permitted"
+	}
+	return reason
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package ens
+
+import "testing"
+
+func TestDNSName(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want string
+	}{
+		{"_ens.example.com.", "_ens.example.com"},
+		{"ab--cd.com", "ab--cd.com"},
+		{"-foo.com", "-foo.com"},
+		{"foo_bar.com", "foo_bar.com"},
+		{"Example.COM", "Example.COM"},
+		{"_ens.bücher.example", "_ens.xn--bcher-kva.example"},
+		{".", ""},
+	} {
+		got, err := DNSName(tc.name)
+		if err != nil {
+			t.Errorf("DNSName(%q): %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("DNSName(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	// DNSName allows these, but they are not valid ENS names.
+	for _, name := range []string{"ab--cd.com", "-foo.com", "foo_bar.com"} {
+		if _, err := Normalize(name); err == nil {
+			t.Errorf("Normalize(%q) succeeded", name)
+		}
+	}
+}
+
+func TestIDNForms(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		normalize string
+		ascii     string
+	}{
+		{"bücher.com", "bücher.com", "xn--bcher-kva.com"},
+		{"xn--bcher-kva.com", "xn--bcher-kva.com", "xn--bcher-kva.com"},
+		{"XN--BCHER-KVA.com.", "xn--bcher-kva.com", "xn--bcher-kva.com"},
+	} {
+		if got, err := Normalize(tc.name); err != nil || got != tc.normalize {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", tc.name, got, err, tc.normalize)
+		}
+		if got, err := ToASCII(tc.name); err != nil || got != tc.ascii {
+			t.Errorf("ToASCII(%q) = %q, %v; want %q", tc.name, got, err, tc.ascii)
+		}
+	}
+}
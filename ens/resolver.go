@@ -0,0 +1,205 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package ens
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/arachnid/dnsprove/contracts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Profile is a set of resolver functions identified by an ERC-165 interface ID.
+type Profile struct {
+	Name string
+	ID   [4]byte
+}
+
+var (
+	AddrProfile        = Profile{"addr", [4]byte{0x3b, 0x3b, 0x57, 0xde}}
+	MulticoinProfile   = Profile{"multicoin", [4]byte{0xf1, 0xcb, 0x7e, 0x06}}
+	TextProfile        = Profile{"text", [4]byte{0x59, 0xd1, 0xd4, 0x3c}}
+	ContenthashProfile = Profile{"contenthash", [4]byte{0xbc, 0x1c, 0x58, 0xd1}}
+	PubkeyProfile      = Profile{"pubkey", [4]byte{0xc8, 0x69, 0x02, 0x33}}
+	ABIProfile         = Profile{"ABI", [4]byte{0x22, 0x03, 0xab, 0x56}}
+	NameProfile        = Profile{"name", [4]byte{0x69, 0x1f, 0x34, 0x31}}
+
+	// Profiles lists every resolver profile this package knows about.
+	Profiles = []Profile{AddrProfile, MulticoinProfile, TextProfile, ContenthashProfile, PubkeyProfile, ABIProfile, NameProfile}
+
+	NoResolverError = errors.New("Name has no resolver")
+)
+
+// ProfileNotSupportedError is returned when a resolver does not implement the
+// profile needed for a record.
+type ProfileNotSupportedError struct {
+	Resolver common.Address
+	Profile  Profile
+}
+
+func (e *ProfileNotSupportedError) Error() string {
+	return fmt.Sprintf("Resolver %s does not support the %s profile", e.Resolver.String(), e.Profile.Name)
+}
+
+type Resolver struct {
+	resolver *contracts.Resolver
+	address  common.Address
+	backend  bind.ContractBackend
+	node     common.Hash
+}
+
+// ReverseName returns the name of addr's reverse record.
+func ReverseName(addr common.Address) string {
+	return hex.EncodeToString(addr[:]) + ".addr.reverse"
+}
+
+// Address returns the address of the resolver contract.
+func (r *Resolver) Address() common.Address {
+	return r.address
+}
+
+// Supports reports whether the resolver implements profile.
+func (r *Resolver) Supports(profile Profile) (bool, error) {
+	return r.resolver.SupportsInterface(nil, profile.ID)
+}
+
+// Profiles returns the known profiles the resolver implements.
+func (r *Resolver) Profiles() ([]Profile, error) {
+	var ret []Profile
+	for _, profile := range Profiles {
+		ok, err := r.Supports(profile)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ret = append(ret, profile)
+		}
+	}
+	return ret, nil
+}
+
+func (r *Resolver) require(profile Profile) error {
+	ok, err := r.Supports(profile)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &ProfileNotSupportedError{r.address, profile}
+	}
+	return nil
+}
+
+func (r *Resolver) Addr() (common.Address, error) {
+	return r.resolver.Addr(nil, r.node)
+}
+
+// MulticoinAddr returns the address for a SLIP-44 coin type, in its binary
+// encoding.
+func (r *Resolver) MulticoinAddr(coinType uint64) ([]byte, error) {
+	if err := r.require(MulticoinProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.Addr0(nil, r.node, new(big.Int).SetUint64(coinType))
+}
+
+func (r *Resolver) Text(key string) (string, error) {
+	if err := r.require(TextProfile); err != nil {
+		return "", err
+	}
+	return r.resolver.Text(nil, r.node, key)
+}
+
+func (r *Resolver) Contenthash() ([]byte, error) {
+	if err := r.require(ContenthashProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.Contenthash(nil, r.node)
+}
+
+func (r *Resolver) Pubkey() ([32]byte, [32]byte, error) {
+	if err := r.require(PubkeyProfile); err != nil {
+		return [32]byte{}, [32]byte{}, err
+	}
+	result, err := r.resolver.Pubkey(nil, r.node)
+	return result.X, result.Y, err
+}
+
+// ABI returns the first of the requested content types the resolver has an
+// ABI for, and the ABI itself.
+func (r *Resolver) ABI(contentTypes uint64) (uint64, []byte, error) {
+	if err := r.require(ABIProfile); err != nil {
+		return 0, nil, err
+	}
+	contentType, data, err := r.resolver.ABI(nil, r.node, new(big.Int).SetUint64(contentTypes))
+	if err != nil {
+		return 0, nil, err
+	}
+	return contentType.Uint64(), data, nil
+}
+
+// Name returns the name a reverse record points to.
+func (r *Resolver) Name() (string, error) {
+	if err := r.require(NameProfile); err != nil {
+		return "", err
+	}
+	return r.resolver.Name(nil, r.node)
+}
+
+func (r *Resolver) SetAddr(opts *bind.TransactOpts, addr common.Address) (*types.Transaction, error) {
+	if err := r.require(AddrProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.SetAddr(opts, r.node, addr)
+}
+
+func (r *Resolver) SetMulticoinAddr(opts *bind.TransactOpts, coinType uint64, addr []byte) (*types.Transaction, error) {
+	if err := r.require(MulticoinProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.SetAddr0(opts, r.node, new(big.Int).SetUint64(coinType), addr)
+}
+
+func (r *Resolver) SetText(opts *bind.TransactOpts, key, value string) (*types.Transaction, error) {
+	if err := r.require(TextProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.SetText(opts, r.node, key, value)
+}
+
+func (r *Resolver) SetContenthash(opts *bind.TransactOpts, hash []byte) (*types.Transaction, error) {
+	if err := r.require(ContenthashProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.SetContenthash(opts, r.node, hash)
+}
+
+func (r *Resolver) SetPubkey(opts *bind.TransactOpts, x, y [32]byte) (*types.Transaction, error) {
+	if err := r.require(PubkeyProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.SetPubkey(opts, r.node, x, y)
+}
+
+// SetABI sets the ABI for a single content type, which must be a power of two.
+func (r *Resolver) SetABI(opts *bind.TransactOpts, contentType uint64, data []byte) (*types.Transaction, error) {
+	if contentType == 0 || contentType&(contentType-1) != 0 {
+		return nil, fmt.Errorf("ABI content type %d is not a power of two", contentType)
+	}
+	if err := r.require(ABIProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.SetABI(opts, r.node, new(big.Int).SetUint64(contentType), data)
+}
+
+func (r *Resolver) SetName(opts *bind.TransactOpts, name string) (*types.Transaction, error) {
+	if err := r.require(NameProfile); err != nil {
+		return nil, err
+	}
+	return r.resolver.SetName(opts, r.node, name)
+}
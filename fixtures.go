@@ -0,0 +1,454 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+var (
+	fixturesFlags   = flag.NewFlagSet("export-fixtures", flag.ExitOnError)
+	fixturesFormat  = fixturesFlags.String("format", "json", "Output format: json, for Hardhat or Foundry's vm.parseJson, or solidity, for a library of constants")
+	fixturesOut     = fixturesFlags.String("out", "-", "File to write fixtures to, or - for stdout")
+	fixturesBatch   = fixturesFlags.String("batch", "", "File listing \"qtype qname\" pairs to export, one per line, or - for stdin")
+	fixturesLibrary = fixturesFlags.String("library", "DNSSECFixtures", "Name of the generated Solidity library")
+	fixturesStruct  = fixturesFlags.String("struct", "", "Solidity type to use for RRSetWithSignature, such as DNSSEC.RRSetWithSignature; the library declares its own if not given")
+	fixturesImport  = fixturesFlags.String("import", "", "Solidity file for the library to import, such as the one declaring -struct")
+)
+
+// fixtureFile is a set of proof chains for contract tests, with the results
+// the oracle contracts are expected to give for them.
+type fixtureFile struct {
+	// Anchors are the trust anchors the oracle must be deployed with.
+	Anchors  hexutil.Bytes `json:"anchors"`
+	Fixtures []*fixture    `json:"fixtures"`
+}
+
+type fixture struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Found bool   `json:"found"`
+	// ValidFrom and ValidUntil bound the times at which every signature in
+	// the chain is valid; pass one of them as verifyRRSet's now.
+	ValidFrom  uint32 `json:"validFrom"`
+	ValidUntil uint32 `json:"validUntil"`
+	// Input is the chain as RRSetWithSignature tuples, for stateless oracles.
+	Input []fixtureRRSet `json:"input"`
+	// Rrs and Inception are what verifyRRSet is expected to return.
+	Rrs       hexutil.Bytes `json:"rrs"`
+	Inception uint32        `json:"inception"`
+	// Submit is the calldata for submitRRSets on a legacy oracle holding only
+	// the anchors, and Rrdata what rrdata is expected to return afterwards.
+	Submit    fixtureSubmit   `json:"submitRRSets"`
+	Rrdata    []fixtureRrdata `json:"rrdata"`
+	Deletions []fixtureDelete `json:"deletions,omitempty"`
+}
+
+type fixtureRRSet struct {
+	Rrset hexutil.Bytes `json:"rrset"`
+	Sig   hexutil.Bytes `json:"sig"`
+}
+
+type fixtureSubmit struct {
+	Data  hexutil.Bytes `json:"data"`
+	Proof hexutil.Bytes `json:"proof"`
+}
+
+type fixtureRrdata struct {
+	Name      string        `json:"name"`
+	Type      string        `json:"type"`
+	DNSType   uint16        `json:"dnstype"`
+	DNSName   hexutil.Bytes `json:"dnsname"`
+	Inception uint32        `json:"inception"`
+	Hash      hexutil.Bytes `json:"hash"`
+}
+
+// fixtureDelete is a call to deleteRRSet on a legacy oracle holding the
+// chain, and whether it should succeed.
+type fixtureDelete struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	DeleteType uint16        `json:"deleteType"`
+	DeleteName hexutil.Bytes `json:"deleteName"`
+	NSEC       hexutil.Bytes `json:"nsec"`
+	Sig        hexutil.Bytes `json:"sig"`
+	Proof      hexutil.Bytes `json:"proof"`
+	Succeeds   bool          `json:"succeeds"`
+	Reason     string        `json:"reason"`
+}
+
+func exportFixturesCommand(args []string) {
+	fixturesFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] export-fixtures [export-fixtures options] qtype qname [qtype qname...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] export-fixtures [export-fixtures options] -batch file\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nWith -zones, proofs are built from signed zone files rather than live DNS.\n")
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExport-fixtures command options:\n")
+		fixturesFlags.PrintDefaults()
+	}
+	fixturesFlags.Parse(args)
+
+	if *fixturesFormat != "json" && *fixturesFormat != "solidity" {
+		log.Crit("Unrecognised output format", "format", *fixturesFormat)
+		exit(ExitError)
+	}
+
+	var entries []*batchEntry
+	if *fixturesBatch != "" {
+		r, err := openBatch(*fixturesBatch)
+		if err != nil {
+			log.Crit("Could not open batch file", "path", *fixturesBatch, "err", err)
+			exit(ExitError)
+		}
+		entries, err = readBatch(r)
+		r.Close()
+		if err != nil {
+			log.Crit("Could not read batch file", "path", *fixturesBatch, "err", err)
+			exit(ExitError)
+		}
+	}
+	if fixturesFlags.NArg()%2 != 0 {
+		fixturesFlags.Usage()
+		exit(ExitError)
+	}
+	for i := 0; i < fixturesFlags.NArg(); i += 2 {
+		qtype, ok := dns.StringToType[strings.ToUpper(fixturesFlags.Arg(i))]
+		if !ok {
+			log.Crit("Unrecognised query type", "qtype", fixturesFlags.Arg(i))
+			exit(ExitError)
+		}
+		entries = append(entries, &batchEntry{qtype: qtype, name: dns.Fqdn(fixturesFlags.Arg(i + 1))})
+	}
+	if len(entries) == 0 {
+		fixturesFlags.Usage()
+		exit(ExitError)
+	}
+
+	anchors, err := packAnchors(trustAnchors)
+	if err != nil {
+		log.Crit("Could not pack trust anchors", "err", err)
+		exit(ExitError)
+	}
+	file := &fixtureFile{Anchors: anchors}
+	ids := make(map[string]bool)
+	for _, entry := range entries {
+		sets, found, err := getProofs(entry.qtype, entry.name)
+		if err != nil {
+			log.Crit("Error resolving", "qtype", dns.TypeToString[entry.qtype], "name", entry.name, "err", err)
+			exit(proofExitCode(err))
+		}
+		f, err := newFixture(entry.qtype, entry.name, sets, found, anchors)
+		if err != nil {
+			log.Crit("Could not build fixture", "qtype", dns.TypeToString[entry.qtype], "name", entry.name, "err", err)
+			exit(ExitError)
+		}
+		f.ID = uniqueID(fixtureID(entry.qtype, entry.name), ids)
+		file.Fixtures = append(file.Fixtures, f)
+		log.Info("Exported fixture", "id", f.ID, "proofs", len(sets), "found", found)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *fixturesOut != "-" {
+		f, err := os.Create(*fixturesOut)
+		if err != nil {
+			log.Crit("Could not create output file", "path", *fixturesOut, "err", err)
+			exit(ExitError)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *fixturesFormat == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(file)
+	} else {
+		err = writeSolidityFixtures(out, file)
+	}
+	if err != nil {
+		log.Crit("Could not write fixtures", "err", err)
+		exit(ExitError)
+	}
+}
+
+// packAnchors encodes trust anchors as the oracle contracts store them.
+func packAnchors(anchors []*dns.DS) ([]byte, error) {
+	var ret []byte
+	for _, ds := range anchors {
+		buf := make([]byte, dns.Len(ds)+1)
+		off, err := dns.PackRR(ds, buf, 0, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, buf[:off]...)
+	}
+	return ret, nil
+}
+
+// newFixture builds the fixture for a validated chain.
+func newFixture(qtype uint16, name string, sets []proofs.SignedSet, found bool, anchors []byte) (*fixture, error) {
+	f := &fixture{
+		Name:       dns.Fqdn(name),
+		Type:       dns.TypeToString[qtype],
+		Found:      found,
+		ValidUntil: ^uint32(0),
+	}
+
+	input, err := oracle.ProofInput(sets)
+	if err != nil {
+		return nil, err
+	}
+	for i, set := range sets {
+		f.Input = append(f.Input, fixtureRRSet{input[i].Rrset, input[i].Sig})
+		if set.Sig.Inception > f.ValidFrom {
+			f.ValidFrom = set.Sig.Inception
+		}
+		if set.Sig.Expiration < f.ValidUntil {
+			f.ValidUntil = set.Sig.Expiration
+		}
+
+		header := set.Rrs[0].Header()
+		dnsname, err := oracle.PackName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := oracle.HashRRSet(set)
+		if err != nil {
+			return nil, err
+		}
+		f.Rrdata = append(f.Rrdata, fixtureRrdata{
+			Name:      header.Name,
+			Type:      dns.TypeToString[header.Rrtype],
+			DNSType:   header.Rrtype,
+			DNSName:   dnsname,
+			Inception: set.Sig.Inception,
+			Hash:      hash[:],
+		})
+	}
+
+	last := sets[len(sets)-1]
+	if f.Rrs, err = last.PackRRSet(); err != nil {
+		return nil, err
+	}
+	f.Inception = last.Sig.Inception
+	if f.Submit.Data, err = oracle.SerializeSets(sets); err != nil {
+		return nil, err
+	}
+	f.Submit.Proof = anchors
+
+	if !found {
+		if f.Deletions, err = nsecDeletions(qtype, name, sets); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// nsecDeletions returns the deletions the NSEC record ending sets should and
+// should not allow: the record it was fetched for, and a type its bitmap
+// shows does exist.
+func nsecDeletions(qtype uint16, name string, sets []proofs.SignedSet) ([]fixtureDelete, error) {
+	last := sets[len(sets)-1]
+	nsec, ok := last.Rrs[0].(*dns.NSEC)
+	if !ok {
+		return nil, fmt.Errorf("chain for a missing record ends with %s, not NSEC", dns.TypeToString[last.Rrs[0].Header().Rrtype])
+	}
+	data, err := last.Pack()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := last.PackSignature()
+	if err != nil {
+		return nil, err
+	}
+	proof, err := sets[len(sets)-2].PackRRSet()
+	if err != nil {
+		return nil, err
+	}
+
+	deletion := func(qtype uint16, name string, succeeds bool, reason string) (fixtureDelete, error) {
+		dnsname, err := oracle.PackName(name)
+		return fixtureDelete{
+			Name:       dns.Fqdn(name),
+			Type:       dns.TypeToString[qtype],
+			DeleteType: qtype,
+			DeleteName: dnsname,
+			NSEC:       data,
+			Sig:        sig,
+			Proof:      proof,
+			Succeeds:   succeeds,
+			Reason:     reason,
+		}, err
+	}
+
+	var ret []fixtureDelete
+	d, err := deletion(qtype, name, true, fmt.Sprintf("NSEC %s proves there is no %s record", nsec.Hdr.Name, dns.TypeToString[qtype]))
+	if err != nil {
+		return nil, err
+	}
+	ret = append(ret, d)
+
+	existing := uint16(dns.TypeNSEC)
+	for _, t := range nsec.TypeBitMap {
+		if t != dns.TypeNSEC && t != dns.TypeRRSIG {
+			existing = t
+			break
+		}
+	}
+	d, err = deletion(existing, nsec.Hdr.Name, false, fmt.Sprintf("NSEC %s shows a %s record exists", nsec.Hdr.Name, dns.TypeToString[existing]))
+	if err != nil {
+		return nil, err
+	}
+	return append(ret, d), nil
+}
+
+// fixtureID returns an identifier for a fixture, such as txtEnsExampleCom.
+// Only ASCII letters and digits are kept, as Solidity requires, and the type
+// comes first so it never starts with a digit.
+func fixtureID(qtype uint16, name string) string {
+	words := strings.FieldsFunc(strings.ToLower(dns.Type(qtype).String()+" "+name), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	})
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	return strings.Join(words, "")
+}
+
+// uniqueID returns id, with a number appended if it is already in ids.
+func uniqueID(id string, ids map[string]bool) string {
+	ret := id
+	for i := 2; ids[ret]; i++ {
+		ret = fmt.Sprintf("%s%d", id, i)
+	}
+	ids[ret] = true
+	return ret
+}
+
+// constantName converts an identifier such as txtEnsExampleCom to
+// TXT_ENS_EXAMPLE_COM.
+func constantName(id string) string {
+	buf := new(bytes.Buffer)
+	for i, r := range id {
+		if i > 0 && unicode.IsUpper(r) {
+			buf.WriteByte('_')
+		}
+		buf.WriteRune(unicode.ToUpper(r))
+	}
+	return buf.String()
+}
+
+func solidityHex(data []byte) string {
+	return fmt.Sprintf("hex\"%x\"", data)
+}
+
+var solidityTemplate = template.Must(template.New("solidity").Funcs(template.FuncMap{
+	"hex":      solidityHex,
+	"constant": constantName,
+	"time":     func(t uint32) string { return dns.TimeToString(t) },
+}).Parse(`// SPDX-License-Identifier: BSD-2-Clause
+// Code generated by dnsprove export-fixtures. DO NOT EDIT.
+pragma solidity ^0.8.4;
+{{if .Import}}
+import "{{.Import}}";
+{{end}}
+library {{.Library}} {
+{{- if not .External}}
+    struct RRSetWithSignature {
+        bytes rrset;
+        bytes sig;
+    }
+{{end}}
+    // Rrdata is what a legacy oracle's rrdata() returns for a set once the
+    // chain has been submitted, other than the time it was inserted.
+    struct Rrdata {
+        uint16 dnstype;
+        bytes name;
+        uint32 inception;
+        bytes20 hash;
+    }
+
+    // Deletion is a call to a legacy oracle's deleteRRSet(), once the chain
+    // has been submitted, and whether it should succeed.
+    struct Deletion {
+        uint16 deleteType;
+        bytes deleteName;
+        bytes nsec;
+        bytes sig;
+        bytes proof;
+        bool succeeds;
+    }
+
+    // ANCHORS are the trust anchors the oracle must be deployed with.
+    bytes internal constant ANCHORS = {{hex .File.Anchors}};
+{{- range .File.Fixtures}}
+{{$c := constant .ID}}
+    // {{.Type}} {{.Name}}{{if not .Found}} (absent; proven by NSEC){{end}}
+    // Every signature is valid from {{time .ValidFrom}} to {{time .ValidUntil}}.
+    uint32 internal constant {{$c}}_VALID_FROM = {{.ValidFrom}};
+    uint32 internal constant {{$c}}_VALID_UNTIL = {{.ValidUntil}};
+    bytes internal constant {{$c}}_RRS = {{hex .Rrs}};
+    uint32 internal constant {{$c}}_INCEPTION = {{.Inception}};
+    bytes internal constant {{$c}}_SUBMIT_DATA = {{hex .Submit.Data}};
+
+    function {{.ID}}() internal pure returns ({{$.Struct}}[] memory input) {
+        input = new {{$.Struct}}[]({{len .Input}});
+{{- range $i, $set := .Input}}
+        input[{{$i}}] = {{$.Struct}}({rrset: {{hex $set.Rrset}}, sig: {{hex $set.Sig}}});
+{{- end}}
+    }
+
+    function {{.ID}}Rrdata() internal pure returns (Rrdata[] memory rrdata) {
+        rrdata = new Rrdata[]({{len .Rrdata}});
+{{- range $i, $r := .Rrdata}}
+        // {{$r.Type}} {{$r.Name}}
+        rrdata[{{$i}}] = Rrdata({dnstype: {{$r.DNSType}}, name: {{hex $r.DNSName}}, inception: {{$r.Inception}}, hash: bytes20({{hex $r.Hash}})});
+{{- end}}
+    }
+{{- if .Deletions}}
+
+    function {{.ID}}Deletions() internal pure returns (Deletion[] memory deletions) {
+        deletions = new Deletion[]({{len .Deletions}});
+{{- range $i, $d := .Deletions}}
+        // {{$d.Type}} {{$d.Name}}: {{$d.Reason}}
+        deletions[{{$i}}] = Deletion({deleteType: {{$d.DeleteType}}, deleteName: {{hex $d.DeleteName}}, nsec: {{hex $d.NSEC}}, sig: {{hex $d.Sig}}, proof: {{hex $d.Proof}}, succeeds: {{$d.Succeeds}}});
+{{- end}}
+    }
+{{- end}}
+{{- end}}
+}
+`))
+
+// writeSolidityFixtures writes fixtures as a Solidity library. Constants hold
+// the byte strings, and functions build the arrays of structs, which cannot
+// be constants.
+func writeSolidityFixtures(w io.Writer, file *fixtureFile) error {
+	data := struct {
+		File     *fixtureFile
+		Library  string
+		Import   string
+		Struct   string
+		External bool
+	}{file, *fixturesLibrary, *fixturesImport, "RRSetWithSignature", *fixturesStruct != ""}
+	if data.External {
+		data.Struct = *fixturesStruct
+	}
+	return solidityTemplate.Execute(w, data)
+}
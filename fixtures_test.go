@@ -0,0 +1,28 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestFixtureID(t *testing.T) {
+	for _, tc := range []struct {
+		qtype uint16
+		name  string
+		want  string
+	}{
+		{dns.TypeTXT, "_ens.example.com.", "txtEnsExampleCom"},
+		{dns.TypeDS, "xn--bcher-kva.example.", "dsXnBcherKvaExample"},
+		{dns.TypeTXT, "bücher.example.", "txtBCherExample"},
+		{dns.TypeDNSKEY, ".", "dnskey"},
+		{65280, "1.example.", "type652801Example"},
+	} {
+		if got := fixtureID(tc.qtype, tc.name); got != tc.want {
+			t.Errorf("fixtureID(%s, %q) = %q, want %q", dns.Type(tc.qtype), tc.name, got, tc.want)
+		}
+	}
+}
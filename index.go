@@ -0,0 +1,194 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/arachnid/dnsprove/indexer"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+func indexCommand(args []string) {
+	indexFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] index [index options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nIndex command options:\n")
+		indexFlags.PrintDefaults()
+	}
+	indexFlags.Parse(args)
+
+	if indexFlags.NArg() != 0 {
+		indexFlags.Usage()
+		return
+	}
+
+	store, err := indexer.OpenStore(*indexDB)
+	if err != nil {
+		log.Crit("Could not open index database", "path", *indexDB, "err", err)
+		exit(1)
+	}
+	defer store.Close()
+
+	conn, err := dial()
+	if err != nil {
+		log.Crit("Error connecting to Ethereum node", "err", err)
+		exit(1)
+	}
+
+	o, err := openOracle(conn, *indexOracleAddress, "")
+	if err != nil {
+		log.Crit("Error creating oracle", "err", err)
+		exit(1)
+	}
+	if o.Stateless() {
+		log.Crit("Oracle is stateless and emits no RRSetUpdated events; there is nothing to index")
+		exit(1)
+	}
+
+	ix := indexer.New(o, conn, store, *indexStart, *indexConfirmations)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Info("Shutting down", "signal", sig)
+		cancel()
+	}()
+
+	if *indexOnce {
+		if err := ix.Sync(ctx); err != nil && ctx.Err() == nil {
+			log.Crit("Error syncing oracle events", "err", err)
+			exit(1)
+		}
+		return
+	}
+	ix.Run(ctx, *indexInterval)
+}
+
+func openIndex(path string) *indexer.Store {
+	if _, err := os.Stat(path); err != nil {
+		log.Crit("Could not open index database; run the index command first", "path", path, "err", err)
+		exit(1)
+	}
+	store, err := indexer.OpenStore(path)
+	if err != nil {
+		log.Crit("Could not open index database", "path", path, "err", err)
+		exit(1)
+	}
+	return store
+}
+
+func listCommand(args []string) {
+	listFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] list [list options] [suffix]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nList command options:\n")
+		listFlags.PrintDefaults()
+	}
+	listFlags.Parse(args)
+
+	if listFlags.NArg() > 1 {
+		listFlags.Usage()
+		return
+	}
+	suffix := ""
+	if listFlags.NArg() == 1 {
+		suffix = strings.ToLower(dns.Fqdn(listFlags.Arg(0)))
+	}
+
+	store := openIndex(*listDB)
+	defer store.Close()
+
+	recs, err := store.List()
+	if err != nil {
+		log.Crit("Could not read index database", "err", err)
+		exit(1)
+	}
+
+	head, _, err := store.Head()
+	if err != nil {
+		log.Crit("Could not read index database", "err", err)
+		exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tTYPE\tRECORDS\tBLOCK\n")
+	for _, rec := range recs {
+		if suffix != "" && rec.Name != suffix && !strings.HasSuffix(rec.Name, "."+suffix) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", rec.Name, dns.TypeToString[rec.Type], len(rec.RRs), rec.Block)
+	}
+	w.Flush()
+	fmt.Printf("\nIndexed to block %d.\n", head)
+}
+
+func showCommand(args []string) {
+	showFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] show [show options] qname [qtype]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nShow command options:\n")
+		showFlags.PrintDefaults()
+	}
+	showFlags.Parse(args)
+
+	if showFlags.NArg() < 1 || showFlags.NArg() > 2 {
+		showFlags.Usage()
+		return
+	}
+	name := showFlags.Arg(0)
+
+	store := openIndex(*showDB)
+	defer store.Close()
+
+	var recs []*indexer.Record
+	if showFlags.NArg() == 2 {
+		qtype, ok := dns.StringToType[strings.ToUpper(showFlags.Arg(1))]
+		if !ok {
+			log.Crit("Unrecognised query type", "qtype", showFlags.Arg(1))
+			exit(1)
+		}
+		rec, err := store.Get(name, qtype)
+		if err != nil && err != indexer.NotFoundError {
+			log.Crit("Could not read index database", "err", err)
+			exit(1)
+		}
+		if rec != nil {
+			recs = append(recs, rec)
+		}
+	} else {
+		var err error
+		recs, err = store.Lookup(name)
+		if err != nil {
+			log.Crit("Could not read index database", "err", err)
+			exit(1)
+		}
+	}
+
+	if len(recs) == 0 {
+		fmt.Printf("No records for %s in the oracle.\n", dns.Fqdn(name))
+		return
+	}
+
+	for _, rec := range recs {
+		fmt.Printf("; %s, updated in block %d by %s\n", rec, rec.Block, rec.TxHash.String())
+		for _, rr := range rec.RRs {
+			fmt.Printf("%s\n", rr)
+		}
+		fmt.Printf("\n")
+	}
+}
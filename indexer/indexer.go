@@ -0,0 +1,267 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package indexer follows a DNSSEC oracle's RRSetUpdated events and keeps a
+// local mirror of the records it holds.
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+const (
+	// MaxReorgDepth is the number of blocks of history kept to recover from
+	// chain reorganisations.
+	MaxReorgDepth = 256
+	// MaxBlockRange is the largest range of blocks requested in one log query.
+	MaxBlockRange = 5000
+)
+
+// ChainReader is the subset of an Ethereum client the indexer needs besides
+// the contract bindings.
+type ChainReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+type Indexer struct {
+	o             *oracle.Oracle
+	chain         ChainReader
+	store         *Store
+	start         uint64
+	confirmations uint64
+}
+
+// New returns an indexer that mirrors o into store, starting at block start if
+// the store is empty, and staying confirmations blocks behind the chain head.
+func New(o *oracle.Oracle, chain ChainReader, store *Store, start, confirmations uint64) *Indexer {
+	return &Indexer{o, chain, store, start, confirmations}
+}
+
+// DecodeRRSet decodes the name and rrset payloads of an RRSetUpdated event.
+func DecodeRRSet(name, rrset []byte) (string, []dns.RR, error) {
+	decodedName, _, err := dns.UnpackDomainName(name, 0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rrs, err := oracle.UnpackRRSet(rrset)
+	if err != nil {
+		return "", nil, err
+	}
+	return decodedName, rrs, nil
+}
+
+// Run syncs the store with the chain every interval until ctx is cancelled.
+func (ix *Indexer) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		if err := ix.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Error("Error syncing oracle events", "err", err)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// Sync brings the store up to date with the chain, first undoing any changes
+// from blocks that are no longer canonical.
+func (ix *Indexer) Sync(ctx context.Context) error {
+	if err := ix.handleReorg(ctx); err != nil {
+		return err
+	}
+
+	latest, err := ix.chain.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if latest.Number.Uint64() < ix.confirmations {
+		return nil
+	}
+	target := latest.Number.Uint64() - ix.confirmations
+
+	head, ok, err := ix.store.Head()
+	if err != nil {
+		return err
+	}
+	from := ix.start
+	if ok {
+		from = head + 1
+	}
+
+	for from <= target {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		to := from + MaxBlockRange - 1
+		if to > target {
+			to = target
+		}
+		if err := ix.index(ctx, from, to); err != nil {
+			return err
+		}
+		from = to + 1
+	}
+
+	if target > MaxReorgDepth {
+		return ix.store.Prune(target - MaxReorgDepth)
+	}
+	return nil
+}
+
+// handleReorg checks the most recent block we processed is still canonical,
+// and if not rolls the store back to the last block that is.
+func (ix *Indexer) handleReorg(ctx context.Context) error {
+	blocks, err := ix.store.RecentBlocks()
+	if err != nil {
+		return err
+	}
+
+	for i, number := range blocks {
+		hash, _, err := ix.store.BlockHash(number)
+		if err != nil {
+			return err
+		}
+		header, err := ix.chain.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return err
+		}
+		if header.Hash() == hash {
+			if i > 0 {
+				log.Warn("Chain reorganisation detected; rolling back", "block", number, "discarded", blocks[0]-number)
+				return ix.store.Rollback(number)
+			}
+			return nil
+		}
+	}
+
+	if len(blocks) > 0 {
+		return fmt.Errorf("Chain reorganisation deeper than %d blocks; resync from scratch", MaxReorgDepth)
+	}
+	return nil
+}
+
+// index applies the events in blocks from to to, inclusive.
+func (ix *Indexer) index(ctx context.Context, from, to uint64) error {
+	end := to
+	it, err := ix.o.GetContract().FilterRRSetUpdated(&bind.FilterOpts{Start: from, End: &end, Context: ctx})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	var changes []Change
+	hashes := make(map[uint64]common.Hash)
+	for it.Next() {
+		ev := it.Event
+		if ev.Raw.Removed {
+			continue
+		}
+		hashes[ev.Raw.BlockNumber] = ev.Raw.BlockHash
+
+		name, rrs, err := DecodeRRSet(ev.Name, ev.Rrset)
+		if err != nil {
+			log.Warn("Could not decode RRSetUpdated event", "block", ev.Raw.BlockNumber, "tx", ev.Raw.TxHash.String(), "err", err)
+			continue
+		}
+
+		if len(rrs) == 0 {
+			// A deletion; the event doesn't say which type was removed, so
+			// check the oracle for each type we have under this name.
+			deleted, err := ix.deletedTypes(name, changes, ev.Raw.BlockNumber)
+			if err != nil {
+				return err
+			}
+			for _, rrtype := range deleted {
+				log.Info("RRSet deleted", "name", name, "type", dns.TypeToString[rrtype], "block", ev.Raw.BlockNumber)
+				changes = append(changes, Change{Name: name, Type: rrtype, Block: ev.Raw.BlockNumber, Index: ev.Raw.Index})
+			}
+			continue
+		}
+
+		rec := &Record{
+			Name:   strings.ToLower(name),
+			Type:   rrs[0].Header().Rrtype,
+			Data:   ev.Rrset,
+			Block:  ev.Raw.BlockNumber,
+			TxHash: ev.Raw.TxHash,
+		}
+		for _, rr := range rrs {
+			rec.RRs = append(rec.RRs, rr.String())
+		}
+		log.Info("RRSet updated", "name", rec.Name, "type", dns.TypeToString[rec.Type], "block", rec.Block)
+		changes = append(changes, Change{Name: rec.Name, Type: rec.Type, Record: rec, Block: ev.Raw.BlockNumber, Index: ev.Raw.Index})
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	header, err := ix.chain.HeaderByNumber(ctx, new(big.Int).SetUint64(to))
+	if err != nil {
+		return err
+	}
+	hashes[to] = header.Hash()
+
+	log.Debug("Indexed blocks", "from", from, "to", to, "changes", len(changes))
+	return ix.store.Apply(changes, hashes, to)
+}
+
+// deletedTypes returns the types stored under name, either in the store or in
+// pending changes, that the oracle no longer held at block.
+func (ix *Indexer) deletedTypes(name string, pending []Change, block uint64) ([]uint16, error) {
+	recs, err := ix.store.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rrtypes := make(map[uint16]bool)
+	for _, rec := range recs {
+		rrtypes[rec.Type] = true
+	}
+	for _, change := range pending {
+		if strings.EqualFold(change.Name, name) {
+			rrtypes[change.Type] = change.Record != nil
+		}
+	}
+
+	var ret []uint16
+	for rrtype, present := range rrtypes {
+		if !present {
+			continue
+		}
+		// Later events may have re-added the type, so ask about the state
+		// as of the deletion, falling back to the latest for nodes that
+		// don't keep old state.
+		inception, _, _, err := ix.o.Rrdata(&bind.CallOpts{BlockNumber: new(big.Int).SetUint64(block)}, rrtype, name)
+		if err != nil {
+			log.Warn("Could not read oracle state at deletion; using the latest block", "name", name, "type", dns.TypeToString[rrtype], "block", block, "err", err)
+			inception, _, _, err = ix.o.Rrdata(nil, rrtype, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if inception == 0 {
+			ret = append(ret, rrtype)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package indexer_test
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/arachnid/dnsprove/chaintest"
+	"github.com/arachnid/dnsprove/dnstest"
+	"github.com/arachnid/dnsprove/indexer"
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	log.Root().SetHandler(log.DiscardHandler())
+}
+
+// query returns the signed set answering a query, or denying it.
+func query(t *testing.T, s *dnstest.Server, name string, qtype uint16) proofs.SignedSet {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	r := s.Answer(m)
+	rrs := r.Answer
+	if len(rrs) == 0 {
+		rrs = r.Ns
+	}
+	var set proofs.SignedSet
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.RRSIG:
+			set.Sig = rr
+		case *dns.SOA:
+		default:
+			set.Rrs = append(set.Rrs, rr)
+		}
+	}
+	if set.Sig == nil || len(set.Rrs) == 0 {
+		t.Fatalf("no signed answer for %s %s", dns.TypeToString[qtype], name)
+	}
+	set.Name = set.Rrs[0].Header().Name
+	return set
+}
+
+// chain returns the proof chain for _ens.example.com's TXT record.
+func chain(t *testing.T, s *dnstest.Server) []proofs.SignedSet {
+	t.Helper()
+	sets := []proofs.SignedSet{query(t, s, ".", dns.TypeDNSKEY)}
+	for _, zone := range []string{"com.", "example.com."} {
+		sets = append(sets, query(t, s, zone, dns.TypeDS), query(t, s, zone, dns.TypeDNSKEY))
+	}
+	return append(sets, query(t, s, "_ens.example.com.", dns.TypeTXT))
+}
+
+// indexSetup is an oracle on a simulated chain, an indexer following it, and
+// the proof chains for _ens.example.com before and after its TXT record is
+// added.
+type indexSetup struct {
+	b         *chaintest.Backend
+	opts      *bind.TransactOpts
+	oracle    *oracle.Oracle
+	store     *indexer.Store
+	ix        *indexer.Indexer
+	txt, nsec []proofs.SignedSet
+}
+
+func newIndexSetup(t *testing.T) *indexSetup {
+	t.Helper()
+	s, err := dnstest.NewServer(dns.ECDSAP256SHA256, "com.", "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := chaintest.NewBackend()
+	addr, err := b.DeployOracle(s.Anchors()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := oracle.New(addr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := bind.NewKeyedTransactor(key)
+	opts.Nonce = big.NewInt(0)
+
+	nsec := chain(t, s)
+	if err := s.Zone("example.com.").Add(`_ens.example.com. 3600 IN TXT "a=` + opts.From.Hex() + `"`); err != nil {
+		t.Fatal(err)
+	}
+	txt := chain(t, s)
+
+	store, err := indexer.OpenStore(t.TempDir() + "/index.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	p := &indexSetup{b, opts, o, store, indexer.New(o, b, store, 0, 0), txt, nsec}
+	p.sync(t)
+	return p
+}
+
+func (p *indexSetup) sync(t *testing.T) {
+	t.Helper()
+	if err := p.ix.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// send checks that a transaction was mined successfully.
+func (p *indexSetup) send(t *testing.T, tx *types.Transaction, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := p.b.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("%s failed", p.b.Method(tx))
+	}
+}
+
+func (p *indexSetup) submitTXT(t *testing.T) {
+	t.Helper()
+	known, err := p.oracle.FindFirstUnknownProof(p.txt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := p.oracle.SendProofs(p.opts, p.txt, known)
+	p.send(t, tx, err)
+	p.opts.Nonce.Add(p.opts.Nonce, big.NewInt(1))
+}
+
+func (p *indexSetup) deleteTXT(t *testing.T) {
+	t.Helper()
+	proof, err := p.nsec[len(p.nsec)-2].PackRRSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// DeleteRRSet moves on to the next nonce itself.
+	tx, err := p.oracle.DeleteRRSet(p.opts, dns.TypeTXT, "_ens.example.com", p.nsec[len(p.nsec)-1], proof)
+	p.send(t, tx, err)
+}
+
+// txtBlock returns the block _ens.example.com's TXT record was indexed at, or
+// 0 if the store doesn't have it.
+func (p *indexSetup) txtBlock(t *testing.T) uint64 {
+	t.Helper()
+	rec, err := p.store.Get("_ens.example.com", dns.TypeTXT)
+	if err == indexer.NotFoundError {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec.Block
+}
+
+func TestSyncReorg(t *testing.T) {
+	p := newIndexSetup(t)
+	p.submitTXT(t)
+	p.sync(t)
+	if block := p.txtBlock(t); block != 1 {
+		t.Fatalf("TXT record indexed at block %d, want 1", block)
+	}
+
+	// The block with the submission is replaced by an empty one.
+	p.b.Reorg(1)
+	p.sync(t)
+	if block := p.txtBlock(t); block != 0 {
+		t.Errorf("TXT record from block %d survived the reorg", block)
+	}
+	for _, name := range []string{".", "com.", "example.com."} {
+		if _, err := p.store.Get(name, dns.TypeDNSKEY); err != indexer.NotFoundError {
+			t.Errorf("DNSKEY %s survived the reorg (%v)", name, err)
+		}
+	}
+	if head, _, err := p.store.Head(); err != nil || head != 1 {
+		t.Errorf("Head = %d (%v) after the reorg, want 1", head, err)
+	}
+}
+
+func TestSyncDeletionAtBlock(t *testing.T) {
+	p := newIndexSetup(t)
+	p.submitTXT(t)
+	p.deleteTXT(t)
+	p.submitTXT(t)
+
+	// All three are indexed at once, after the record was re-added, so only
+	// the oracle's state at block 2 shows it was deleted there.
+	p.sync(t)
+	if block := p.txtBlock(t); block != 3 {
+		t.Fatalf("TXT record indexed at block %d, want 3", block)
+	}
+
+	// Undoing the re-addition leaves it deleted, not as of block 1.
+	p.b.Reorg(1)
+	p.sync(t)
+	if block := p.txtBlock(t); block != 0 {
+		t.Errorf("TXT record from block %d survived its deletion at block 2", block)
+	}
+}
+
+func TestSyncPrunes(t *testing.T) {
+	p := newIndexSetup(t)
+	p.submitTXT(t)
+	p.b.Mine(indexer.MaxReorgDepth + 10)
+	p.sync(t)
+
+	head := uint64(indexer.MaxReorgDepth + 11)
+	blocks, err := p.store.RecentBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{head}; !reflect.DeepEqual(blocks, want) {
+		t.Errorf("RecentBlocks = %v after pruning, want %v", blocks, want)
+	}
+
+	// The submission's undo history is gone, so it can no longer be rolled
+	// back.
+	if err := p.store.Rollback(0); err != nil {
+		t.Fatal(err)
+	}
+	if block := p.txtBlock(t); block != 1 {
+		t.Errorf("TXT record indexed at block %d after rolling back pruned blocks, want 1", block)
+	}
+}
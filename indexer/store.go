@@ -0,0 +1,320 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package indexer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/miekg/dns"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	recordsBucket = []byte("records")
+	undoBucket    = []byte("undo")
+	blocksBucket  = []byte("blocks")
+	metaBucket    = []byte("meta")
+
+	headKey = []byte("head")
+
+	NotFoundError = errors.New("Record not found")
+)
+
+// Record is the oracle's current view of a single RRSet, as reconstructed
+// from RRSetUpdated events.
+type Record struct {
+	Name   string        `json:"name"`
+	Type   uint16        `json:"type"`
+	RRs    []string      `json:"rrs"`
+	Data   hexutil.Bytes `json:"data"`
+	Block  uint64        `json:"block"`
+	TxHash common.Hash   `json:"txHash"`
+}
+
+// undoEntry records the value a key held before an event changed it, so the
+// change can be reverted if its block is reorged out.
+type undoEntry struct {
+	Key  hexutil.Bytes `json:"key"`
+	Prev *Record       `json:"prev"`
+}
+
+// Store is a local mirror of the oracle's state, backed by BoltDB.
+type Store struct {
+	db *bolt.DB
+}
+
+func OpenStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, undoBucket, blocksBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func recordKey(name string, rrtype uint16) []byte {
+	key := []byte(strings.ToLower(dns.Fqdn(name)) + "\x00")
+	return append(key, byte(rrtype>>8), byte(rrtype))
+}
+
+func encodeBlock(number uint64) []byte {
+	ret := make([]byte, 8)
+	binary.BigEndian.PutUint64(ret, number)
+	return ret
+}
+
+func undoKey(block uint64, index uint, rrtype uint16) []byte {
+	ret := make([]byte, 14)
+	binary.BigEndian.PutUint64(ret, block)
+	binary.BigEndian.PutUint32(ret[8:], uint32(index))
+	binary.BigEndian.PutUint16(ret[12:], rrtype)
+	return ret
+}
+
+// Head returns the last block processed, and whether any block has been
+// processed at all.
+func (s *Store) Head() (uint64, bool, error) {
+	var head uint64
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(metaBucket).Get(headKey); v != nil {
+			head, ok = binary.BigEndian.Uint64(v), true
+		}
+		return nil
+	})
+	return head, ok, err
+}
+
+// BlockHash returns the hash recorded for a processed block, if any.
+func (s *Store) BlockHash(number uint64) (common.Hash, bool, error) {
+	var hash common.Hash
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(blocksBucket).Get(encodeBlock(number)); v != nil {
+			hash, ok = common.BytesToHash(v), true
+		}
+		return nil
+	})
+	return hash, ok, err
+}
+
+// RecentBlocks returns the numbers of all blocks with recorded hashes, most
+// recent first.
+func (s *Store) RecentBlocks() ([]uint64, error) {
+	var ret []uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(blocksBucket).Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			ret = append(ret, binary.BigEndian.Uint64(k))
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// Get returns the record for a name and type.
+func (s *Store) Get(name string, rrtype uint16) (*Record, error) {
+	var rec *Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(recordsBucket).Get(recordKey(name, rrtype))
+		if v == nil {
+			return NotFoundError
+		}
+		rec = new(Record)
+		return json.Unmarshal(v, rec)
+	})
+	return rec, err
+}
+
+// Lookup returns all records for a name.
+func (s *Store) Lookup(name string) ([]*Record, error) {
+	prefix := []byte(strings.ToLower(dns.Fqdn(name)) + "\x00")
+	var ret []*Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(recordsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && len(k) == len(prefix)+2 && string(k[:len(prefix)]) == string(prefix); k, v = c.Next() {
+			rec := new(Record)
+			if err := json.Unmarshal(v, rec); err != nil {
+				return err
+			}
+			ret = append(ret, rec)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// List returns every record in the store, ordered by name and type.
+func (s *Store) List() ([]*Record, error) {
+	var ret []*Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(k, v []byte) error {
+			rec := new(Record)
+			if err := json.Unmarshal(v, rec); err != nil {
+				return err
+			}
+			ret = append(ret, rec)
+			return nil
+		})
+	})
+	return ret, err
+}
+
+// Change is a single update to apply to the store. A nil Record deletes the
+// RRSet identified by Name and Type.
+type Change struct {
+	Name   string
+	Type   uint16
+	Record *Record
+	Block  uint64
+	Index  uint
+}
+
+// Apply atomically applies a set of changes, records the hashes of the blocks
+// they came from, and advances the head to the given block.
+func (s *Store) Apply(changes []Change, hashes map[uint64]common.Hash, head uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+		undo := tx.Bucket(undoBucket)
+		for _, change := range changes {
+			key := recordKey(change.Name, change.Type)
+
+			entry := undoEntry{Key: key}
+			if v := records.Get(key); v != nil {
+				entry.Prev = new(Record)
+				if err := json.Unmarshal(v, entry.Prev); err != nil {
+					return err
+				}
+			}
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if err := undo.Put(undoKey(change.Block, change.Index, change.Type), data); err != nil {
+				return err
+			}
+
+			if change.Record == nil {
+				if err := records.Delete(key); err != nil {
+					return err
+				}
+				continue
+			}
+			data, err = json.Marshal(change.Record)
+			if err != nil {
+				return err
+			}
+			if err := records.Put(key, data); err != nil {
+				return err
+			}
+		}
+
+		blocks := tx.Bucket(blocksBucket)
+		for number, hash := range hashes {
+			if err := blocks.Put(encodeBlock(number), hash[:]); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(headKey, encodeBlock(head))
+	})
+}
+
+// Rollback reverts every change made after block, and resets the head to it.
+func (s *Store) Rollback(block uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+		undo := tx.Bucket(undoBucket)
+		start := undoKey(block+1, 0, 0)
+
+		c := undo.Cursor()
+		var keys [][]byte
+		for k, v := c.Last(); k != nil && string(k) >= string(start); k, v = c.Prev() {
+			var entry undoEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if entry.Prev == nil {
+				if err := records.Delete(entry.Key); err != nil {
+					return err
+				}
+			} else {
+				data, err := json.Marshal(entry.Prev)
+				if err != nil {
+					return err
+				}
+				if err := records.Put(entry.Key, data); err != nil {
+					return err
+				}
+			}
+			keys = append(keys, append([]byte{}, k...))
+		}
+		for _, k := range keys {
+			if err := undo.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		blocks := tx.Bucket(blocksBucket)
+		keys = nil
+		bc := blocks.Cursor()
+		for k, _ := bc.Seek(encodeBlock(block + 1)); k != nil; k, _ = bc.Next() {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		for _, k := range keys {
+			if err := blocks.Delete(k); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(headKey, encodeBlock(block))
+	})
+}
+
+// Prune discards undo history and block hashes for blocks before block, which
+// are considered too old to be reorged.
+func (s *Store) Prune(block uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, pair := range []struct {
+			bucket []byte
+			limit  []byte
+		}{{undoBucket, undoKey(block, 0, 0)}, {blocksBucket, encodeBlock(block)}} {
+			b := tx.Bucket(pair.bucket)
+			var keys [][]byte
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil && string(k) < string(pair.limit); k, _ = c.Next() {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Record) String() string {
+	return fmt.Sprintf("%s %s", r.Name, dns.TypeToString[r.Type])
+}
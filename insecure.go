@@ -0,0 +1,213 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arachnid/dnsprove/proofs"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// InsecureDelegationError is returned when a name can't be proven because it
+// is in a zone that isn't signed: the zone's parent, the last secure ancestor,
+// proves that it has no DS record.
+type InsecureDelegationError struct {
+	Name   string
+	Zone   string
+	Parent string
+	// Proof is the chain for DS Zone, ending with the NSEC or NSEC3 record
+	// that denies it, or the NSEC3 records of an opt-out proof.
+	Proof []proofs.SignedSet
+	// Err is the error validating Name.
+	Err error
+}
+
+func (e *InsecureDelegationError) Error() string {
+	if strings.EqualFold(e.Name, e.Zone) {
+		return fmt.Sprintf("%s is not DNSSEC-signed: %s has no DS for it", displayName(e.Zone), displayZone(e.Parent))
+	}
+	return fmt.Sprintf("%s is in %s, which is not DNSSEC-signed: %s has no DS for it", displayName(e.Name), displayName(e.Zone), displayZone(e.Parent))
+}
+
+// OptOut reports whether Proof ends with an NSEC3 opt-out proof, rather than
+// a record at Zone denying the DS.
+func (e *InsecureDelegationError) OptOut() bool {
+	rr, ok := e.Proof[len(e.Proof)-1].Rrs[0].(*dns.NSEC3)
+	return ok && !rr.Match(e.Zone)
+}
+
+func displayName(name string) string {
+	if name == "." {
+		return name
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+// displayZone formats a parent zone as it is usually written: .xyz for a TLD.
+func displayZone(zone string) string {
+	if zone == "." {
+		return "the root"
+	}
+	return "." + strings.TrimSuffix(zone, ".")
+}
+
+// notDNSSECEnabled reports whether err means a name has no signed records to
+// prove, as opposed to records that fail to validate.
+func notDNSSECEnabled(err error) bool {
+	if e, ok := err.(*InsecureDelegationError); ok {
+		err = e.Err
+	}
+	return err == NotDNSSECEnabledError
+}
+
+// findInsecureDelegation looks for the zone cut above name, or at it, that the
+// chain of trust doesn't cross, walking down from the TLD. It returns nil if
+// every delegation down to name is secure, or if one can't be proven either
+// way, and an error if a DS is denied by a proof that isn't supported.
+func (client *Client) findInsecureDelegation(name string, cause error) (*InsecureDelegationError, error) {
+	node := &traceNode{Kind: "insecure", Name: name, Detail: "looking for an unsigned delegation"}
+	defer client.enter(node)()
+
+	labels := dns.SplitDomainName(name)
+	for i := len(labels) - 1; i >= 0; i-- {
+		zone := dns.Fqdn(strings.Join(labels[i:], "."))
+		sets, found, err := client.QueryWithProof(dns.TypeDS, dns.ClassINET, zone)
+		if e, ok := err.(*UnsupportedDenialError); ok {
+			node.reject(e.Error())
+			return nil, e
+		}
+		if err != nil {
+			node.skip(fmt.Sprintf("DS %s cannot be proven either way", zone))
+			return nil, nil
+		}
+		if found || !deniesDS(sets, zone) {
+			continue
+		}
+
+		e := &InsecureDelegationError{
+			Name:   name,
+			Zone:   zone,
+			Parent: sets[len(sets)-1].Sig.SignerName,
+			Proof:  sets,
+			Err:    cause,
+		}
+		node.accept(e.Error())
+		log.Info("Found insecure delegation", "name", name, "zone", zone, "parent", e.Parent)
+		return e, nil
+	}
+	node.skip("every delegation is secure")
+	return nil, nil
+}
+
+// deniesDS reports whether the chain sets ends with an NSEC or NSEC3 record
+// showing that zone is delegated, but has no DS record, or with an NSEC3
+// opt-out proof that zone may be an unsigned delegation.
+func deniesDS(sets []proofs.SignedSet, zone string) bool {
+	set := sets[len(sets)-1]
+	if rr, ok := set.Rrs[0].(*dns.NSEC3); ok && !rr.Match(zone) {
+		// The closest encloser's record comes before the covering one,
+		// unless a single record is both.
+		rrs := []dns.RR{rr}
+		if len(sets) > 1 {
+			rrs = append(rrs, sets[len(sets)-2].Rrs[0])
+		}
+		ce, _ := optOutProof(rrs, zone)
+		return ce != nil
+	}
+
+	var bitmap []uint16
+	switch rr := set.Rrs[0].(type) {
+	case *dns.NSEC:
+		if !strings.EqualFold(rr.Hdr.Name, zone) {
+			return false
+		}
+		bitmap = rr.TypeBitMap
+	case *dns.NSEC3:
+		if !rr.Match(zone) {
+			return false
+		}
+		bitmap = rr.TypeBitMap
+	default:
+		return false
+	}
+
+	delegated := false
+	for _, t := range bitmap {
+		switch t {
+		case dns.TypeNS:
+			delegated = true
+		case dns.TypeDS, dns.TypeSOA:
+			return false
+		}
+	}
+	return delegated
+}
+
+// optOutFlag marks an NSEC3 record whose span may cover unsigned delegations
+// (RFC 5155 section 3.1.2.1).
+const optOutFlag = 1
+
+// optOutProof finds the NSEC3 records among rrs proving that name, which has
+// no NSEC3 record of its own, may be an unsigned delegation (RFC 5155 section
+// 8.6): ce matches the closest encloser, and nc, which has the opt-out flag,
+// covers the next closer name. It returns nils if rrs have no such proof.
+func optOutProof(rrs []dns.RR, name string) (ce, nc *dns.NSEC3) {
+	var nsec3s []*dns.NSEC3
+	for _, rr := range rrs {
+		if rr, ok := rr.(*dns.NSEC3); ok {
+			nsec3s = append(nsec3s, rr)
+		}
+	}
+	if len(nsec3s) == 0 {
+		return nil, nil
+	}
+
+	labels := dns.SplitDomainName(name)
+	for i := 1; i <= len(labels); i++ {
+		encloser := dns.Fqdn(strings.Join(labels[i:], "."))
+		nextCloser := dns.Fqdn(strings.Join(labels[i-1:], "."))
+		if ce = matchingNSEC3(nsec3s, encloser); ce == nil {
+			continue
+		}
+		for _, rr := range nsec3s {
+			if rr.Flags&optOutFlag != 0 && sameZone(rr, ce) && rr.Cover(nextCloser) {
+				return ce, rr
+			}
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+func matchingNSEC3(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, rr := range nsec3s {
+		if rr.Match(name) {
+			return rr
+		}
+	}
+	return nil
+}
+
+// sameZone reports whether two NSEC3 records belong to the same zone, which
+// their owner names, a hash prepended to the zone's name, show.
+func sameZone(a, b *dns.NSEC3) bool {
+	ia, ib := dns.Split(a.Hdr.Name), dns.Split(b.Hdr.Name)
+	if len(ia) < 2 || len(ib) < 2 {
+		return false
+	}
+	return strings.EqualFold(a.Hdr.Name[ia[1]:], b.Hdr.Name[ib[1]:])
+}
+
+// hasNSEC3 reports whether rrs include any NSEC3 records.
+func hasNSEC3(rrs []dns.RR) bool {
+	for _, rr := range rrs {
+		if _, ok := rr.(*dns.NSEC3); ok {
+			return true
+		}
+	}
+	return false
+}
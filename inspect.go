@@ -0,0 +1,265 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// oracleRecord is the oracle's stored metadata for an RRSet.
+type oracleRecord struct {
+	Exists    bool          `json:"exists"`
+	Inception uint32        `json:"inception"`
+	Inserted  time.Time     `json:"inserted"`
+	Hash      hexutil.Bytes `json:"hash"`
+}
+
+// inspectLink is the oracle's view of one set in a proof chain.
+type inspectLink struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Status     string        `json:"status"`
+	State      string        `json:"state"`
+	Oracle     oracleRecord  `json:"oracle"`
+	Inception  uint32        `json:"inception"`
+	Expiration uint32        `json:"expiration"`
+	Hash       hexutil.Bytes `json:"hash"`
+}
+
+type inspectResult struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Version    string        `json:"oracleVersion"`
+	Verified   bool          `json:"verified"`
+	VerifyErr  string        `json:"verifyError,omitempty"`
+	Oracle     oracleRecord  `json:"oracle"`
+	DNSFound   bool          `json:"dnsFound"`
+	DNSError   string        `json:"dnsError,omitempty"`
+	MatchesDNS bool          `json:"matchesDNS"`
+	Chain      []inspectLink `json:"chain"`
+	Anchors    []string      `json:"anchors"`
+}
+
+func newOracleRecord(inception uint32, inserted uint64, hash [20]byte) oracleRecord {
+	rec := oracleRecord{Exists: inception != 0, Inception: inception, Hash: hash[:]}
+	if inserted != 0 {
+		rec.Inserted = time.Unix(int64(inserted), 0).UTC()
+	}
+	return rec
+}
+
+// linkStatus summarises a SetState as fresh, stale or missing.
+func linkStatus(state oracle.SetState) string {
+	switch state {
+	case oracle.StateCurrent:
+		return "fresh"
+	case oracle.StateMissing:
+		return "missing"
+	case oracle.StateNewer:
+		return "newer"
+	default:
+		return "stale"
+	}
+}
+
+func inspect(o *oracle.Oracle, qtype uint16, name string) (*inspectResult, error) {
+	name = dns.Fqdn(name)
+	result := &inspectResult{
+		Name:    name,
+		Type:    dns.TypeToString[qtype],
+		Version: o.Version().String(),
+	}
+
+	inception, inserted, hash, err := o.Rrdata(nil, qtype, name)
+	if err != nil {
+		return nil, err
+	}
+	result.Oracle = newOracleRecord(inception, inserted, hash)
+
+	anchors, err := o.Anchors()
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range anchors {
+		result.Anchors = append(result.Anchors, rr.String())
+	}
+
+	sets, found, err := getProofs(qtype, name)
+	if err != nil {
+		result.DNSError = err.Error()
+		return result, nil
+	}
+	result.DNSFound = found
+
+	if o.Stateless() {
+		// Nothing is stored, so the only question is whether the oracle
+		// accepts the chain.
+		for _, set := range sets {
+			header := set.Rrs[0].Header()
+			result.Chain = append(result.Chain, inspectLink{
+				Name:       header.Name,
+				Type:       dns.TypeToString[header.Rrtype],
+				Status:     "n/a",
+				State:      "n/a",
+				Inception:  set.Sig.Inception,
+				Expiration: set.Sig.Expiration,
+			})
+		}
+		if found {
+			if _, _, err := o.Verify(sets); err != nil {
+				result.VerifyErr = err.Error()
+			} else {
+				result.Verified = true
+				result.MatchesDNS = true
+			}
+		}
+		return result, nil
+	}
+
+	for i, set := range sets {
+		status, err := o.CheckSet(set)
+		if err != nil {
+			return nil, err
+		}
+		ourhash, err := oracle.HashRRSet(set)
+		if err != nil {
+			return nil, err
+		}
+		header := set.Rrs[0].Header()
+		result.Chain = append(result.Chain, inspectLink{
+			Name:       header.Name,
+			Type:       dns.TypeToString[header.Rrtype],
+			Status:     linkStatus(status.State),
+			State:      status.State.String(),
+			Oracle:     newOracleRecord(status.Inception, status.Inserted, status.Hash),
+			Inception:  set.Sig.Inception,
+			Expiration: set.Sig.Expiration,
+			Hash:       ourhash[:],
+		})
+		if i == len(sets)-1 && found {
+			result.MatchesDNS = status.Hash == ourhash
+		}
+	}
+	if !found {
+		// DNS proves the record doesn't exist; the oracle matches if it agrees.
+		result.MatchesDNS = !result.Oracle.Exists
+	}
+
+	return result, nil
+}
+
+func (r *inspectResult) print() {
+	fmt.Printf("%s %s\n", r.Type, r.Name)
+	fmt.Printf("  Version:   %s oracle\n", r.Version)
+	if r.Version == oracle.VersionStateless.String() {
+		if r.Verified {
+			fmt.Printf("  Oracle:    verifies current DNS proof\n")
+		} else if r.VerifyErr != "" {
+			fmt.Printf("  Oracle:    rejects current DNS proof: %s\n", r.VerifyErr)
+		} else {
+			fmt.Printf("  Oracle:    stores no records\n")
+		}
+	} else if r.Oracle.Exists {
+		fmt.Printf("  Oracle:    inception %d, inserted %s, hash %s\n", r.Oracle.Inception, r.Oracle.Inserted.Format(time.RFC3339), r.Oracle.Hash)
+	} else {
+		fmt.Printf("  Oracle:    no record\n")
+	}
+	switch {
+	case r.DNSError != "":
+		fmt.Printf("  DNS:       error: %s\n", r.DNSError)
+	case r.DNSFound:
+		fmt.Printf("  DNS:       found\n")
+	default:
+		fmt.Printf("  DNS:       proven not to exist\n")
+	}
+	if r.DNSError == "" {
+		fmt.Printf("  Matches:   %t\n", r.MatchesDNS)
+	}
+
+	if len(r.Chain) > 0 {
+		fmt.Printf("\nProof chain:\n")
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "  STATUS\tTYPE\tNAME\tORACLE INCEPTION\tDNS INCEPTION\tEXPIRES\n")
+		for _, link := range r.Chain {
+			status := link.Status
+			if link.State != link.Status && link.Status == "stale" {
+				status = fmt.Sprintf("stale (%s)", link.State)
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%d\t%s\n", status, link.Type, link.Name, link.Oracle.Inception, link.Inception, time.Unix(int64(link.Expiration), 0).UTC().Format(time.RFC3339))
+		}
+		w.Flush()
+	}
+
+	fmt.Printf("\nTrust anchors:\n")
+	for _, anchor := range r.Anchors {
+		fmt.Printf("  %s\n", anchor)
+	}
+}
+
+func inspectCommand(args []string) {
+	inspectFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] inspect [inspect options] qtype qname\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nInspect command options:\n")
+		inspectFlags.PrintDefaults()
+	}
+	inspectFlags.Parse(args)
+
+	if inspectFlags.NArg() != 2 {
+		inspectFlags.Usage()
+		return
+	}
+
+	qtype, ok := dns.StringToType[strings.ToUpper(inspectFlags.Arg(0))]
+	if !ok {
+		log.Crit("Unrecognised query type", "qtype", inspectFlags.Arg(0))
+		exit(1)
+	}
+	name := inspectFlags.Arg(1)
+
+	conn, err := dial()
+	if err != nil {
+		log.Crit("Error connecting to Ethereum node", "err", err)
+		exit(1)
+	}
+
+	o, err := openOracle(conn, *inspectOracleAddress, name)
+	if err != nil {
+		log.Crit("Error creating oracle", "err", err)
+		exit(1)
+	}
+
+	result, err := inspect(o, qtype, name)
+	if err != nil {
+		log.Crit("Error inspecting oracle", "qtype", inspectFlags.Arg(0), "name", name, "err", err)
+		exit(1)
+	}
+
+	switch *inspectOutput {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			log.Crit("Error encoding result", "err", err)
+			exit(1)
+		}
+	case "text":
+		result.print()
+	default:
+		log.Crit("Unrecognised output format", "output", *inspectOutput)
+		exit(1)
+	}
+}
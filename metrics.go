@@ -0,0 +1,204 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+var (
+	dnsQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "dnsprove_dns_query_duration_seconds",
+		Help: "Time taken by DNS queries.",
+	}, []string{"type"})
+	dnsQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dnsprove_dns_query_errors_total",
+		Help: "DNS queries that failed.",
+	}, []string{"type"})
+	dnsQueriesSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dnsprove_dns_queries_saved_total",
+		Help: "DNS queries answered by records already fetched.",
+	}, []string{"source"})
+	resolverFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dnsprove_resolver_failures_total",
+		Help: "DNS queries a resolver failed to answer.",
+	}, []string{"resolver"})
+	resolverUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dnsprove_resolver_up",
+		Help: "Whether a resolver is in use (1), or marked down after failing to answer (0).",
+	}, []string{"resolver"})
+	resolverConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dnsprove_resolver_disagreements_total",
+		Help: "Queries on which resolvers gave different answers, or some gave none that validated.",
+	}, []string{"strategy"})
+	validationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dnsprove_validation_failures_total",
+		Help: "DNSSEC validation failures.",
+	}, []string{"reason"})
+	transactionsSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dnsprove_transactions_total",
+		Help: "Transactions the Ethereum node accepted.",
+	})
+	transactionGas = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dnsprove_transaction_gas_used_total",
+		Help: "Gas used by sent transactions, counted once they are mined.",
+	})
+	transactionCost = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dnsprove_transaction_cost_gwei_total",
+		Help: "Cost of sent transactions, in gwei, counted once they are mined.",
+	})
+	signatureExpiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dnsprove_signature_expiry_seconds",
+		Help: "Seconds until the first signature in a watched record's proof chain expires.",
+	}, []string{"type", "name"})
+	oracleExpiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dnsprove_oracle_expiry_seconds",
+		Help: "Seconds until a watched record's copy in the oracle goes stale.",
+	}, []string{"type", "name"})
+	recordsRefreshed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dnsprove_records_refreshed_total",
+		Help: "Watched records resubmitted because they were nearing expiry in the oracle.",
+	})
+)
+
+// Reasons recorded by validationFailures.
+const (
+	reasonUnsupportedAlgorithm = "unsupported_algorithm"
+	reasonMissingDNSKEY        = "missing_dnskey"
+	reasonMissingDS            = "missing_ds"
+	reasonBadSignature         = "bad_signature"
+	reasonExpired              = "expired"
+	reasonUnsigned             = "unsigned"
+)
+
+// Sources recorded by dnsQueriesSaved.
+const (
+	savedPrefetch = "prefetch"
+	savedHarvest  = "harvest"
+)
+
+// startMetrics serves metrics on the -metrics address, if one was given.
+func startMetrics() {
+	if *metricsAddr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		log.Info("Serving metrics", "addr", *metricsAddr)
+		if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+			log.Error("Error serving metrics", "err", err)
+		}
+	}()
+}
+
+// pushMetrics sends metrics to the -pushgateway URL, if one was given,
+// including the gas used by any sent transactions mined by now.
+func pushMetrics() {
+	if *pushgateway == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	recordGasUsed(ctx)
+	cancel()
+	if err := push.New(*pushgateway, *metricsJob).Gatherer(prometheus.DefaultGatherer).Push(); err != nil {
+		log.Error("Could not push metrics", "url", *pushgateway, "err", err)
+	}
+}
+
+// exit pushes metrics and then exits with code.
+func exit(code int) {
+	pushMetrics()
+	os.Exit(code)
+}
+
+// chainClient is an Ethereum client that records the transactions it sends in
+// the transaction metrics.
+type chainClient struct {
+	*ethclient.Client
+}
+
+// unmined holds the transactions the node has accepted whose gas use isn't
+// known yet, and the gas prices they pay.
+var unmined = struct {
+	sync.Mutex
+	txs map[common.Hash]unminedTx
+}{txs: make(map[common.Hash]unminedTx)}
+
+type unminedTx struct {
+	client   *chainClient
+	gasPrice *big.Int
+}
+
+// SendTransaction sends tx, counting it only if the node accepts it.
+func (c *chainClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := c.Client.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	transactionsSent.Inc()
+	unmined.Lock()
+	unmined.txs[tx.Hash()] = unminedTx{c, tx.GasPrice()}
+	unmined.Unlock()
+	return nil
+}
+
+// recordGasUsed adds the gas used by sent transactions that have since been
+// mined to the transaction metrics. Others are left for a later call.
+func recordGasUsed(ctx context.Context) {
+	unmined.Lock()
+	defer unmined.Unlock()
+	for hash, tx := range unmined.txs {
+		receipt, err := tx.client.TransactionReceipt(ctx, hash)
+		if err == ethereum.NotFound {
+			continue
+		} else if err != nil {
+			log.Debug("Could not get transaction receipt", "tx", hash.String(), "err", err)
+			continue
+		}
+		transactionGas.Add(float64(receipt.GasUsed))
+		wei := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.gasPrice)
+		cost, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e9)).Float64()
+		transactionCost.Add(cost)
+		delete(unmined.txs, hash)
+	}
+}
+
+// recordExpiry updates the expiry gauges for a watched record.
+func recordExpiry(qtype uint16, name string, sigExpires, oracleExpires time.Time) {
+	typ := dns.TypeToString[qtype]
+	if !sigExpires.IsZero() {
+		signatureExpiry.WithLabelValues(typ, name).Set(time.Until(sigExpires).Seconds())
+	}
+	if !oracleExpires.IsZero() {
+		oracleExpiry.WithLabelValues(typ, name).Set(time.Until(oracleExpires).Seconds())
+	}
+}
+
+func observeQuery(qtype uint16, start time.Time, err error) {
+	typ := dns.TypeToString[qtype]
+	if typ == "" {
+		typ = strconv.Itoa(int(qtype))
+	}
+	dnsQueryDuration.WithLabelValues(typ).Observe(time.Since(start).Seconds())
+	if err != nil {
+		dnsQueryErrors.WithLabelValues(typ).Inc()
+	}
+}
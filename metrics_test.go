@@ -0,0 +1,94 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := c.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestChainClientCountsAcceptedTransactions(t *testing.T) {
+	accept, mined := false, false
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch {
+		case req.Method == "eth_getTransactionReceipt" && mined:
+			resp["result"] = &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 60000, Logs: []*types.Log{}}
+		case req.Method == "eth_getTransactionReceipt":
+			resp["result"] = nil
+		case accept:
+			resp["result"] = common.Hash{}.Hex()
+		default:
+			resp["error"] = map[string]interface{}{"code": -32000, "message": "nonce too low"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer node.Close()
+
+	conn, err := ethclient.Dial(node.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &chainClient{conn}
+
+	key, _ := crypto.GenerateKey()
+	signer := types.HomesteadSigner{}
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{}, nil, 100000, big.NewInt(1e9), nil), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, gas, cost := counterValue(t, transactionsSent), counterValue(t, transactionGas), counterValue(t, transactionCost)
+	if err := client.SendTransaction(context.Background(), tx); err == nil {
+		t.Fatal("rejected transaction sent without error")
+	}
+	if got := counterValue(t, transactionsSent); got != sent {
+		t.Errorf("rejected transaction counted: %v sent, want %v", got, sent)
+	}
+
+	accept = true
+	if err := client.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if got := counterValue(t, transactionsSent); got != sent+1 {
+		t.Errorf("%v transactions sent, want %v", got, sent+1)
+	}
+
+	// Gas is only known once the transaction is mined, and counted once.
+	for i, want := range []float64{gas, gas + 60000, gas + 60000} {
+		recordGasUsed(context.Background())
+		if got := counterValue(t, transactionGas); got != want {
+			t.Errorf("check %d: gas = %v, want %v", i, got, want)
+		}
+		mined = true
+	}
+	if got := counterValue(t, transactionCost); got != cost+60000 {
+		t.Errorf("cost = %v gwei, want %v", got, cost+60000)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package oracle
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	callDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "dnsprove_oracle_call_duration_seconds",
+		Help: "Time taken by read-only calls to the oracle.",
+	}, []string{"method"})
+	callErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dnsprove_oracle_call_errors_total",
+		Help: "Read-only calls to the oracle that failed.",
+	}, []string{"method"})
+	proofsSubmitted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dnsprove_proofs_submitted_total",
+		Help: "RRSets sent to the oracle in submitRRSets transactions.",
+	})
+	rrsetsDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "dnsprove_rrsets_deleted_total",
+		Help: "deleteRRSet transactions sent to the oracle.",
+	})
+)
+
+// observeCall records the duration and outcome of a call to the oracle.
+func observeCall(method string, start time.Time, err error) {
+	callDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		callErrors.WithLabelValues(method).Inc()
+	}
+}
@@ -4,6 +4,7 @@
 package oracle
 
 //go:generate abigen --sol ../contracts/dnssec.sol --pkg contracts --out ../contracts/dnssec.go
+//go:generate abigen --sol ../contracts/dnssecimpl.sol --pkg contracts --out ../contracts/dnssecimpl.go
 
 import (
 	"bytes"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/arachnid/dnsprove/contracts"
+	"github.com/arachnid/dnsprove/ens"
 	"github.com/arachnid/dnsprove/proofs"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -20,30 +22,53 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	log "github.com/inconshreveable/log15"
 	"github.com/miekg/dns"
-	"golang.org/x/crypto/sha3"
 )
 
+// Oracle wraps either generation of the DNSSEC oracle. Legacy oracles store
+// proven RRSets; stateless oracles store nothing and verify a full chain of
+// proofs on every call.
 type Oracle struct {
 	o       *contracts.DNSSEC
+	impl    *contracts.DNSSECImpl
+	version Version
 	backend bind.ContractBackend
 }
 
+// New returns an Oracle for the contract at addr, detecting which generation
+// of the oracle interface it implements.
 func New(addr common.Address, backend bind.ContractBackend) (*Oracle, error) {
 	oracle, err := contracts.NewDNSSEC(addr, backend)
 	if err != nil {
 		return nil, err
 	}
 
+	impl, err := contracts.NewDNSSECImpl(addr, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := detectVersion(oracle, impl)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Detected oracle version", "address", addr.String(), "version", version)
+
 	return &Oracle{
 		oracle,
+		impl,
+		version,
 		backend,
 	}, nil
 }
 
+// PackName converts any U-labels in name to A-labels and returns it in DNS
+// wire format.
 func PackName(name string) ([]byte, error) {
-	if name[len(name)-1] != '.' {
-		name = name + "."
+	name, err := ens.DNSName(name)
+	if err != nil {
+		return nil, err
 	}
+	name = dns.Fqdn(name)
 
 	ret := make([]byte, len(name)+1)
 	pos, err := dns.PackDomainName(name, ret, 0, nil, false)
@@ -53,110 +78,189 @@ func PackName(name string) ([]byte, error) {
 	return ret[:pos], nil
 }
 
-func (o *Oracle) FindFirstUnknownProof(p []proofs.SignedSet) (int, error) {
-	for i, set := range p {
-		if matches, err := o.RecordMatches(set); err != nil || !matches {
-			return i, err
+// UnpackRRSet decodes a packed RRSet, as stored in the oracle's anchors or
+// emitted in RRSetUpdated events.
+func UnpackRRSet(data []byte) ([]dns.RR, error) {
+	var rrs []dns.RR
+	for off := 0; off < len(data); {
+		var rr dns.RR
+		var err error
+		rr, off, err = dns.UnpackRR(data, off)
+		if err != nil {
+			return nil, err
 		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// Anchors returns the oracle's trust anchors.
+func (o *Oracle) Anchors() ([]dns.RR, error) {
+	data, err := o.anchors()
+	if err != nil {
+		return nil, err
 	}
-	return len(p), nil
+	return UnpackRRSet(data)
 }
 
-func (o *Oracle) Rrdata(rrtype uint16, name string) (uint32, uint64, [20]byte, error) {
+// FindFirstUnknownProof returns the index of the first set in p that needs to
+// be submitted to prove the last set. Sets before it are either current in the
+// oracle, or are not needed because a later set can already be used as proof.
+func (o *Oracle) FindFirstUnknownProof(p []proofs.SignedSet) (int, error) {
+	plan, err := o.PlanProofs(p)
+	if err != nil {
+		return 0, err
+	}
+	plan.Log()
+	return plan.First, nil
+}
+
+// Rrdata returns the inception, insertion time and hash of the oracle's
+// rrtype record at name, as of the block in opts, or the latest if opts is nil.
+func (o *Oracle) Rrdata(opts *bind.CallOpts, rrtype uint16, name string) (uint32, uint64, [20]byte, error) {
+	if o.Stateless() {
+		// Stateless oracles hold no records.
+		return 0, 0, [20]byte{}, nil
+	}
+
 	packed, err := PackName(name)
 	if err != nil {
 		return 0, 0, [20]byte{}, err
 	}
 
-	result, err := o.o.Rrdata(nil, rrtype, packed)
+	start := time.Now()
+	result, err := o.o.Rrdata(opts, rrtype, packed)
+	observeCall("rrdata", start, err)
 	return result.Inception, result.Inserted, result.Hash, err
 }
 
 func (o *Oracle) RecordMatches(set proofs.SignedSet) (bool, error) {
 	header := set.Rrs[0].Header()
 
-	inception, inserted, hash, err := o.Rrdata(header.Rrtype, header.Name)
+	status, err := o.CheckSet(set)
 	if err != nil {
 		return false, err
 	}
 
-	rrset, err := set.PackRRSet()
-	if err != nil {
-		return false, err
-	}
-	h := sha3.NewLegacyKeccak256()
-	h.Write(rrset)
-	ourhash := h.Sum(nil)
-
-	if inception == 0 {
+	switch status.State {
+	case StateMissing:
 		log.Info("RRSET does not exist", "name", header.Name, "type", dns.TypeToString[header.Rrtype])
 		return false, nil
-	} else if inception <= set.Sig.Inception && (!bytes.Equal(hash[:], ourhash[:20]) || int64(inserted)+int64(header.Ttl) < time.Now().Unix()) {
-		log.Info("RRSET exists but is out of date", "name", header.Name, "type", dns.TypeToString[header.Rrtype], "current", inception, "new", set.Sig.Inception, "oldhash", hexutil.Encode(hash[:]), "newhash", hexutil.Encode(ourhash[:20]))
+	case StateNewer:
+		return false, fmt.Errorf("Oracle's RRSET has inception after our record's inception: name=%s, type=%s, oracleInception=%d, inception=%d", header.Name, dns.TypeToString[header.Rrtype], status.Inception, set.Sig.Inception)
+	case StateOutdated, StateExpired:
+		ourhash, _ := HashRRSet(set)
+		log.Info("RRSET exists but is out of date", "name", header.Name, "type", dns.TypeToString[header.Rrtype], "current", status.Inception, "new", set.Sig.Inception, "oldhash", hexutil.Encode(status.Hash[:]), "newhash", hexutil.Encode(ourhash[:]))
 		return false, nil
-	} else if inception > set.Sig.Inception {
-		return false, fmt.Errorf("Oracle's RRSET has inception after our record's inception: name=%s, type=%s, oracleInception=%d, inception=%d", header.Name, dns.TypeToString[header.Rrtype], inception, set.Sig.Inception)
 	}
 
 	log.Info("RRSET already exists", "name", header.Name, "type", dns.TypeToString[header.Rrtype])
 	return true, nil
 }
 
+// Batch is a run of proofs that can be submitted in a single transaction.
+// Each set is proven by the one before it; the first set is proven by Proof,
+// or by the oracle's trust anchors if Proof is nil.
+type Batch struct {
+	Proof *proofs.SignedSet
+	Sets  []proofs.SignedSet
+}
+
+// Rough gas costs used to size batched submissions. Signature verification
+// and storage dominate, so each set is charged a fixed amount on top of its
+// calldata.
+const (
+	TxBaseGas       = 21000
+	SubmitSetGas    = 200000
+	CalldataByteGas = 68
+)
+
+// EstimateSubmitGas returns a conservative gas estimate for submitting sets
+// serialized as data.
+func EstimateSubmitGas(data []byte, count int) uint64 {
+	return TxBaseGas + uint64(count)*SubmitSetGas + uint64(len(data))*CalldataByteGas
+}
+
 func (o *Oracle) SerializeProofs(p []proofs.SignedSet, known int) ([]byte, []byte, error) {
-	buf := new(bytes.Buffer)
+	b := Batch{Sets: p[known:]}
+	if known > 0 {
+		b.Proof = &p[known-1]
+	}
+	return o.SerializeBatch(b)
+}
 
+func (o *Oracle) SerializeBatch(b Batch) ([]byte, []byte, error) {
 	var proof []byte
-	if known == 0 {
+	if b.Proof == nil {
 		// Get the trust anchors as initial proof
 		var err error
-		proof, err = o.o.Anchors(nil)
+		proof, err = o.anchors()
 		if err != nil {
 			return nil, nil, err
 		}
 	} else {
 		var err error
-		proof, err = p[known-1].PackRRSet()
+		proof, err = b.Proof.PackRRSet()
 		if err != nil {
 			return nil, nil, err
 		}
 	}
 
-	for i := known; i < len(p); i++ {
-		set := p[i]
+	data, err := SerializeSets(b.Sets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, proof, nil
+}
+
+// SerializeSets encodes sets in the length-prefixed format expected by
+// submitRRSets.
+func SerializeSets(sets []proofs.SignedSet) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, set := range sets {
 		header := set.Rrs[0].Header()
 
 		data, err := set.Pack()
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		sig, err := set.PackSignature()
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		if err := binary.Write(buf, binary.BigEndian, uint16(len(data))); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		if _, err := buf.Write(data); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		if err := binary.Write(buf, binary.BigEndian, uint16(len(sig))); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		if _, err := buf.Write(sig); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 		log.Info("Adding proof to transaction", "name", header.Name, "type", dns.TypeToString[header.Rrtype])
 	}
-	return buf.Bytes(), proof, nil
+	return buf.Bytes(), nil
 }
 
+// GetContract returns the binding for a legacy oracle, or nil if the oracle is
+// stateless.
 func (o *Oracle) GetContract() *contracts.DNSSEC {
+	if o.Stateless() {
+		return nil
+	}
 	return o.o
 }
 
 func (o *Oracle) SendProofs(opts *bind.TransactOpts, p []proofs.SignedSet, known int) (*types.Transaction, error) {
+	if o.Stateless() {
+		return nil, StatelessOracleError
+	}
+
 	data, proof, err := o.SerializeProofs(p, known)
 	if err != nil {
 		return nil, err
@@ -168,11 +272,41 @@ func (o *Oracle) SendProofs(opts *bind.TransactOpts, p []proofs.SignedSet, known
 	if err != nil {
 		return nil, err
 	}
+	proofsSubmitted.Add(float64(len(p) - known))
 
 	return tx, nil
 }
 
+// SendBatch submits a single batch of proofs. The gas limit is set from
+// EstimateSubmitGas, since batches may depend on earlier transactions that
+// have not yet been mined and so cannot be estimated by the node.
+func (o *Oracle) SendBatch(opts *bind.TransactOpts, b Batch) (*types.Transaction, error) {
+	if o.Stateless() {
+		return nil, StatelessOracleError
+	}
+
+	data, proof, err := o.SerializeBatch(b)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Submitting batch transaction.", "proofs", len(b.Sets), "bytes", len(data))
+	log.Debug("Signature info", "data", hexutil.Encode(data))
+	opts.GasLimit = EstimateSubmitGas(data, len(b.Sets))
+	tx, err := o.o.SubmitRRSets(opts, data, proof)
+	opts.GasLimit = 0
+	if err == nil {
+		proofsSubmitted.Add(float64(len(b.Sets)))
+	}
+
+	return tx, err
+}
+
 func (o *Oracle) DeleteRRSet(opts *bind.TransactOpts, dnsType uint16, name string, nsec proofs.SignedSet, proof []byte) (*types.Transaction, error) {
+	if o.Stateless() {
+		return nil, StatelessOracleError
+	}
+
 	log.Info("Deleting RRSet", "type", dns.TypeToString[dnsType], "name", name, "nsec", nsec.Rrs)
 	packedName, err := PackName(name)
 	if err != nil {
@@ -191,8 +325,12 @@ func (o *Oracle) DeleteRRSet(opts *bind.TransactOpts, dnsType uint16, name strin
 
 	opts.GasLimit = 150000
 	tx, err := o.o.DeleteRRSet(opts, dnsType, packedName, data, sig, proof)
-	opts.Nonce = opts.Nonce.Add(opts.Nonce, big.NewInt(1))
 	opts.GasLimit = 0
+	// A transaction that wasn't sent doesn't use up its nonce.
+	if err == nil {
+		opts.Nonce = opts.Nonce.Add(opts.Nonce, big.NewInt(1))
+		rrsetsDeleted.Inc()
+	}
 
 	return tx, err
 }
@@ -0,0 +1,227 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package oracle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arachnid/dnsprove/proofs"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/sha3"
+)
+
+// SetState describes how a proof compares with the oracle's copy of the same
+// RRSet.
+type SetState int
+
+const (
+	// StateMissing means the oracle has no copy of the RRSet.
+	StateMissing SetState = iota
+	// StateOutdated means the oracle holds different or older data.
+	StateOutdated
+	// StateExpired means the oracle holds the same data, but it was inserted
+	// more than a TTL ago. It can still be used to prove other sets.
+	StateExpired
+	// StateCurrent means the oracle holds the same data, and it is fresh.
+	StateCurrent
+	// StateNewer means the oracle holds data with a later inception than ours,
+	// so ours cannot be submitted.
+	StateNewer
+)
+
+var stateNames = map[SetState]string{
+	StateMissing:  "missing",
+	StateOutdated: "outdated",
+	StateExpired:  "expired",
+	StateCurrent:  "current",
+	StateNewer:    "newer",
+}
+
+func (s SetState) String() string {
+	return stateNames[s]
+}
+
+// Usable reports whether the oracle's copy can be used as a proof for other
+// sets. The oracle only checks that a proof's hash matches what it has stored.
+func (s SetState) Usable() bool {
+	return s == StateExpired || s == StateCurrent
+}
+
+// SetStatus is the result of comparing a proof with the oracle's state.
+type SetStatus struct {
+	Set       proofs.SignedSet
+	State     SetState
+	Inception uint32
+	Inserted  uint64
+	Hash      [20]byte
+}
+
+// HashRRSet returns the hash the oracle stores for a set.
+func HashRRSet(set proofs.SignedSet) ([20]byte, error) {
+	var ret [20]byte
+
+	rrset, err := set.PackRRSet()
+	if err != nil {
+		return ret, err
+	}
+	h := sha3.NewLegacyKeccak256()
+	h.Write(rrset)
+	copy(ret[:], h.Sum(nil))
+	return ret, nil
+}
+
+// CheckSet compares set against the oracle's copy of the same RRSet.
+func (o *Oracle) CheckSet(set proofs.SignedSet) (SetStatus, error) {
+	header := set.Rrs[0].Header()
+
+	inception, inserted, hash, err := o.Rrdata(nil, header.Rrtype, header.Name)
+	if err != nil {
+		return SetStatus{}, err
+	}
+
+	ourhash, err := HashRRSet(set)
+	if err != nil {
+		return SetStatus{}, err
+	}
+
+	status := SetStatus{
+		Set:       set,
+		Inception: inception,
+		Inserted:  inserted,
+		Hash:      hash,
+	}
+	switch {
+	case inception == 0:
+		status.State = StateMissing
+	case inception > set.Sig.Inception:
+		status.State = StateNewer
+	case !bytes.Equal(hash[:], ourhash[:]):
+		status.State = StateOutdated
+	case int64(inserted)+int64(header.Ttl) < time.Now().Unix():
+		status.State = StateExpired
+	default:
+		status.State = StateCurrent
+	}
+	return status, nil
+}
+
+// Step is the planned action for one set in a proof chain.
+type Step struct {
+	Status SetStatus
+	Submit bool
+	Reason string
+}
+
+// Plan is the minimal set of submissions needed to prove the last set in a
+// chain. Sets are always submitted as a contiguous run ending with the last
+// set, proven by the set before First.
+type Plan struct {
+	Steps []Step
+	First int
+}
+
+// Submissions returns the number of sets that need to be submitted.
+func (p *Plan) Submissions() int {
+	return len(p.Steps) - p.First
+}
+
+// Log records each step of the plan, including the sets that are skipped and
+// why.
+func (p *Plan) Log() {
+	for _, step := range p.Steps {
+		header := step.Status.Set.Rrs[0].Header()
+		log.Info("Proof plan", "name", header.Name, "type", dns.TypeToString[header.Rrtype], "state", step.Status.State, "submit", step.Submit, "reason", step.Reason)
+	}
+}
+
+type plannerKey struct {
+	name   string
+	rrtype uint16
+	hash   [20]byte
+}
+
+// Planner works out which sets in a proof chain need submitting. It caches
+// oracle lookups, so a single Planner can be used for many chains that share
+// a common prefix.
+type Planner struct {
+	o     *Oracle
+	cache map[plannerKey]SetStatus
+}
+
+func (o *Oracle) NewPlanner() *Planner {
+	return &Planner{o, make(map[plannerKey]SetStatus)}
+}
+
+func (p *Planner) check(set proofs.SignedSet) (SetStatus, error) {
+	hash, err := HashRRSet(set)
+	if err != nil {
+		return SetStatus{}, err
+	}
+	header := set.Rrs[0].Header()
+	key := plannerKey{strings.ToLower(header.Name), header.Rrtype, hash}
+	if status, ok := p.cache[key]; ok {
+		return status, nil
+	}
+	status, err := p.o.CheckSet(set)
+	if err != nil {
+		return status, err
+	}
+	p.cache[key] = status
+	return status, nil
+}
+
+// Plan checks every set in chain against the oracle independently, then walks
+// back from the last set to find the minimal run that must be submitted. A
+// set only needs submitting if it is the last set and is not current, or if a
+// set being submitted depends on it and the oracle's copy is not usable as a
+// proof.
+func (p *Planner) Plan(chain []proofs.SignedSet) (*Plan, error) {
+	plan := &Plan{Steps: make([]Step, len(chain)), First: len(chain)}
+	for i, set := range chain {
+		status, err := p.check(set)
+		if err != nil {
+			return nil, err
+		}
+		plan.Steps[i].Status = status
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		step := &plan.Steps[i]
+		header := step.Status.Set.Rrs[0].Header()
+
+		if i == len(chain)-1 {
+			if step.Status.State == StateCurrent {
+				step.Reason = "already current in oracle"
+				break
+			}
+		} else if step.Status.State.Usable() {
+			step.Reason = fmt.Sprintf("oracle's copy is %s and can prove %s %s", step.Status.State, dns.TypeToString[chain[i+1].Rrs[0].Header().Rrtype], chain[i+1].Rrs[0].Header().Name)
+			break
+		}
+
+		if step.Status.State == StateNewer {
+			return nil, fmt.Errorf("Oracle's RRSET has inception after our record's inception: name=%s, type=%s, oracleInception=%d, inception=%d", header.Name, dns.TypeToString[header.Rrtype], step.Status.Inception, step.Status.Set.Sig.Inception)
+		}
+		step.Submit = true
+		step.Reason = fmt.Sprintf("oracle's copy is %s", step.Status.State)
+		plan.First = i
+	}
+
+	for i := 0; i < len(chain); i++ {
+		if step := &plan.Steps[i]; step.Reason == "" {
+			step.Reason = fmt.Sprintf("oracle's copy is %s, but not needed as a later set is already usable", step.Status.State)
+		}
+	}
+
+	return plan, nil
+}
+
+// PlanProofs returns the minimal submission plan for a single chain.
+func (o *Oracle) PlanProofs(chain []proofs.SignedSet) (*Plan, error) {
+	return o.NewPlanner().Plan(chain)
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package oracle
+
+import (
+	"errors"
+	"time"
+
+	"github.com/arachnid/dnsprove/contracts"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// Version identifies which generation of the oracle interface a contract
+// implements.
+type Version int
+
+const (
+	// VersionLegacy oracles store proven RRSets, which are submitted with
+	// submitRRSets and removed with deleteRRSet.
+	VersionLegacy Version = iota + 1
+	// VersionStateless oracles store nothing, and verify a chain of
+	// RRSetWithSignature structs on every call to verifyRRSet.
+	VersionStateless
+)
+
+func (v Version) String() string {
+	switch v {
+	case VersionLegacy:
+		return "legacy"
+	case VersionStateless:
+		return "stateless"
+	}
+	return "unknown"
+}
+
+// VERIFY_RRSET_INTERFACE_ID is the ERC-165 interface ID of
+// verifyRRSet((bytes,bytes)[]).
+var VERIFY_RRSET_INTERFACE_ID = [4]byte{0xbd, 0xf9, 0x5f, 0xef}
+
+var (
+	UnknownOracleError   = errors.New("Contract does not implement a known DNSSEC oracle interface")
+	StatelessOracleError = errors.New("Oracle is stateless; proofs are passed directly to the contracts that use them")
+)
+
+// detectVersion works out which interface an oracle implements, first by
+// ERC-165 and then by probing for methods only one generation has.
+func detectVersion(legacy *contracts.DNSSEC, impl *contracts.DNSSECImpl) (Version, error) {
+	if ok, err := impl.SupportsInterface(nil, VERIFY_RRSET_INTERFACE_ID); err == nil && ok {
+		return VersionStateless, nil
+	}
+
+	if _, err := legacy.Rrdata(nil, dns.TypeTXT, []byte{0}); err == nil {
+		return VersionLegacy, nil
+	}
+
+	// An empty chain verifies trivially, returning the trust anchors.
+	if _, err := impl.VerifyRRSet(nil, nil); err == nil {
+		return VersionStateless, nil
+	}
+
+	return 0, UnknownOracleError
+}
+
+// Version returns the generation of oracle interface the contract implements.
+func (o *Oracle) Version() Version {
+	return o.version
+}
+
+// Stateless reports whether the oracle stores nothing, and so never needs
+// proofs submitting to it.
+func (o *Oracle) Stateless() bool {
+	return o.version == VersionStateless
+}
+
+func (o *Oracle) anchors() (data []byte, err error) {
+	start := time.Now()
+	defer func() { observeCall("anchors", start, err) }()
+	if o.Stateless() {
+		return o.impl.Anchors(nil)
+	}
+	return o.o.Anchors(nil)
+}
+
+// ProofInput encodes a proof chain as the RRSetWithSignature structs expected
+// by stateless oracles and the contracts that use them.
+func ProofInput(sets []proofs.SignedSet) ([]contracts.DNSSECImplRRSetWithSignature, error) {
+	input := make([]contracts.DNSSECImplRRSetWithSignature, 0, len(sets))
+	for _, set := range sets {
+		data, err := set.Pack()
+		if err != nil {
+			return nil, err
+		}
+		sig, err := set.PackSignature()
+		if err != nil {
+			return nil, err
+		}
+		input = append(input, contracts.DNSSECImplRRSetWithSignature{Rrset: data, Sig: sig})
+	}
+	return input, nil
+}
+
+// Verify asks the oracle to check a complete proof chain, starting from its
+// trust anchors, without changing any state. It returns the verified RRSet
+// and its inception. For legacy oracles this simulates submitting the chain.
+func (o *Oracle) Verify(sets []proofs.SignedSet) ([]byte, uint32, error) {
+	if o.Stateless() {
+		input, err := ProofInput(sets)
+		if err != nil {
+			return nil, 0, err
+		}
+		start := time.Now()
+		result, err := o.impl.VerifyRRSet(nil, input)
+		observeCall("verifyRRSet", start, err)
+		if err != nil {
+			return nil, 0, err
+		}
+		log.Info("Oracle verified proofs", "count", len(sets), "inception", result.Inception)
+		return result.Rrs, result.Inception, nil
+	}
+
+	data, proof, err := o.SerializeProofs(sets, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	var rrs []byte
+	raw := &contracts.DNSSECRaw{Contract: o.o}
+	start := time.Now()
+	err = raw.Call(&bind.CallOpts{}, &rrs, "submitRRSets", data, proof)
+	observeCall("submitRRSets", start, err)
+	if err != nil {
+		return nil, 0, err
+	}
+	log.Info("Oracle verified proofs", "count", len(sets))
+	return rrs, sets[len(sets)-1].Sig.Inception, nil
+}
+
+// Accepts checks, without changing any state, that the oracle would accept
+// sets, given that those before known are already usable in it. Stateless
+// oracles always check the whole chain.
+func (o *Oracle) Accepts(sets []proofs.SignedSet, known int) error {
+	if o.Stateless() {
+		_, _, err := o.Verify(sets)
+		return err
+	}
+	if known >= len(sets) {
+		return nil
+	}
+
+	data, proof, err := o.SerializeProofs(sets, known)
+	if err != nil {
+		return err
+	}
+	var rrs []byte
+	raw := &contracts.DNSSECRaw{Contract: o.o}
+	start := time.Now()
+	err = raw.Call(&bind.CallOpts{}, &rrs, "submitRRSets", data, proof)
+	observeCall("submitRRSets", start, err)
+	return err
+}
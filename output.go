@@ -0,0 +1,280 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arachnid/dnsprove/ens"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+	prompt "github.com/segmentio/go-prompt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Exit codes. Scripts rely on these, so they must not change.
+const (
+	ExitOK          = 0
+	ExitError       = 1 // Anything not covered below, such as bad arguments.
+	ExitDNS         = 2 // DNS queries could not be completed.
+	ExitValidation  = 3 // DNS answers or proofs did not validate, or a record says not to proceed.
+	ExitChain       = 4 // Talking to the Ethereum node or a contract failed.
+	ExitAborted     = 5 // The user declined to send a transaction.
+	ExitNothingToDo = 6 // Everything is already up to date.
+)
+
+var exitCodeNames = map[int]string{
+	ExitOK:          "ok",
+	ExitError:       "error",
+	ExitDNS:         "dns",
+	ExitValidation:  "validation",
+	ExitChain:       "chain",
+	ExitAborted:     "aborted",
+	ExitNothingToDo: "nothing-to-do",
+}
+
+// chainEntry summarises one set in a proof chain.
+type chainEntry struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Inception  uint32        `json:"inception"`
+	Expiration uint32        `json:"expiration"`
+	Submit     bool          `json:"submit"`
+	Data       hexutil.Bytes `json:"data"`
+	Sig        hexutil.Bytes `json:"sig"`
+}
+
+// insecureInfo identifies where the chain of trust to a name breaks.
+type insecureInfo struct {
+	Zone    string `json:"zone"`
+	Parent  string `json:"parent"`
+	Message string `json:"message"`
+}
+
+type resultError struct {
+	Code    string                 `json:"code"`
+	Exit    int                    `json:"exit"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// commandResult is what a command reports when run with -output json.
+type commandResult struct {
+	Command      string        `json:"command"`
+	Name         string        `json:"name,omitempty"`
+	Type         string        `json:"type,omitempty"`
+	Outcome      string        `json:"outcome"`
+	Found        *bool         `json:"found,omitempty"`
+	Chain        []chainEntry  `json:"chain,omitempty"`
+	Transactions []string      `json:"transactions,omitempty"`
+	Decisions    []string      `json:"decisions,omitempty"`
+	Status       interface{}   `json:"status,omitempty"`
+	Trace        []*traceNode  `json:"trace,omitempty"`
+	Insecure     *insecureInfo `json:"insecure,omitempty"`
+	Alternatives []pathChoice  `json:"alternatives,omitempty"`
+	Error        *resultError  `json:"error,omitempty"`
+}
+
+// reporter collects a command's result and ends the command, either logging
+// and printing text as the tool always has, or printing a single JSON object.
+type reporter struct {
+	json   bool
+	result commandResult
+}
+
+var report = &reporter{}
+
+// confirm asks the user a yes or no question. With -output json the question
+// is asked on stderr, leaving stdout to the JSON result.
+func confirm(format string, args ...interface{}) bool {
+	if !report.JSON() {
+		return prompt.Confirm(format, args...)
+	}
+	in := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, format+": ", args...)
+		line, err := in.ReadString('\n')
+		switch strings.TrimSpace(line) {
+		case "Yes", "yes", "y", "Y":
+			return true
+		case "No", "no", "n", "N":
+			return false
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// password asks the user for a password, on stderr with -output json.
+func password(label string) string {
+	if !report.JSON() {
+		return prompt.Password(label)
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	pass, _ := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(pass)
+}
+
+// startReport sets up the report for command, in the given output format.
+func startReport(command, format string) {
+	report.result = commandResult{Command: command}
+	switch format {
+	case "json":
+		report.json = true
+	case "text":
+		report.json = false
+	default:
+		log.Crit("Unrecognised output format", "output", format)
+		exit(ExitError)
+	}
+}
+
+// JSON reports whether output is JSON, in which case commands must not print
+// anything else to stdout.
+func (r *reporter) JSON() bool {
+	return r.json
+}
+
+func (r *reporter) setTarget(qtype uint16, name string) {
+	r.result.Name = name
+	if qtype != 0 {
+		r.result.Type = dns.TypeToString[qtype]
+	}
+}
+
+func (r *reporter) setFound(found bool) {
+	r.result.Found = &found
+}
+
+// setChain records a proof chain, and which of its sets will be submitted.
+func (r *reporter) setChain(sets []proofs.SignedSet, known int) {
+	r.result.Chain = nil
+	for i, set := range sets {
+		header := set.Rrs[0].Header()
+		entry := chainEntry{
+			Name:       header.Name,
+			Type:       dns.TypeToString[header.Rrtype],
+			Inception:  set.Sig.Inception,
+			Expiration: set.Sig.Expiration,
+			Submit:     i >= known,
+		}
+		entry.Data, _ = set.Pack()
+		entry.Sig, _ = set.PackSignature()
+		r.result.Chain = append(r.result.Chain, entry)
+	}
+}
+
+// setInsecure records that the name is in an unsigned zone, and the chain
+// proving its parent has no DS record for it.
+func (r *reporter) setInsecure(e *InsecureDelegationError) {
+	r.result.Insecure = &insecureInfo{Zone: e.Zone, Parent: e.Parent, Message: e.Error()}
+	r.setChain(e.Proof, 0)
+}
+
+func (r *reporter) addTransactions(txs ...*types.Transaction) {
+	for _, tx := range txs {
+		if tx != nil {
+			r.result.Transactions = append(r.result.Transactions, tx.Hash().String())
+		}
+	}
+}
+
+func (r *reporter) addTransactionHashes(hashes ...common.Hash) {
+	for _, hash := range hashes {
+		r.result.Transactions = append(r.result.Transactions, hash.String())
+	}
+}
+
+// decide logs a decision the command made, and records it in the result.
+func (r *reporter) decide(msg string, ctx ...interface{}) {
+	log.Info(msg, ctx...)
+	r.result.Decisions = append(r.result.Decisions, msg)
+}
+
+// fail ends the command with an error and the given exit code.
+func (r *reporter) fail(code int, msg string, ctx ...interface{}) {
+	if !r.json {
+		log.Crit(msg, ctx...)
+		exit(code)
+	}
+
+	e := &resultError{Code: exitCodeNames[code], Exit: code, Message: msg}
+	for i := 0; i+1 < len(ctx); i += 2 {
+		if e.Details == nil {
+			e.Details = make(map[string]interface{})
+		}
+		value := ctx[i+1]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		e.Details[fmt.Sprint(ctx[i])] = value
+	}
+	r.result.Error = e
+	r.result.Outcome = "failed"
+	r.emit()
+	exit(code)
+}
+
+// finish ends the command with the given exit code and outcome. In text mode
+// message, if any, is printed.
+func (r *reporter) finish(code int, outcome string, message string) {
+	if r.json {
+		r.result.Outcome = outcome
+		r.emit()
+	} else if message != "" {
+		fmt.Println(message)
+	}
+	exit(code)
+}
+
+func (r *reporter) emit() {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r.result); err != nil {
+		log.Crit("Error encoding result", "err", err)
+	}
+}
+
+// codedError is an error with the exit code it should end the command with.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func withCode(code int, err error) error {
+	return &codedError{code, err}
+}
+
+// exitCode returns the exit code attached to err, or ExitError.
+func exitCode(err error) int {
+	if e, ok := err.(*codedError); ok {
+		return e.code
+	}
+	return ExitError
+}
+
+// proofExitCode classifies an error from getProofs.
+func proofExitCode(err error) int {
+	switch err.(type) {
+	case *DNSQueryError:
+		return ExitDNS
+	case *ens.NameError:
+		return ExitError
+	}
+	return ExitValidation
+}
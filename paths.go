@@ -0,0 +1,172 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// maxPaths limits how many alternative chains are kept for each RRSet, so
+// zones publishing many signatures don't make the search blow up.
+const maxPaths = 16
+
+// pathSize returns the number of bytes path takes as submitRRSets calldata.
+func pathSize(path []proofs.SignedSet) int {
+	size := 0
+	for _, set := range path {
+		data, _ := set.Pack()
+		sig, _ := set.PackSignature()
+		size += 4 + len(data) + len(sig)
+	}
+	return size
+}
+
+// pathExpiration returns when the first signature in path expires.
+func pathExpiration(path []proofs.SignedSet) uint32 {
+	expiration := ^uint32(0)
+	for _, set := range path {
+		if set.Sig.Expiration < expiration {
+			expiration = set.Sig.Expiration
+		}
+	}
+	return expiration
+}
+
+// rankPaths orders paths by what can be judged without an oracle: smallest
+// first, then longest-lived. It keeps at most maxPaths of them.
+func rankPaths(paths [][]proofs.SignedSet) [][]proofs.SignedSet {
+	type ranked struct {
+		path       []proofs.SignedSet
+		size       int
+		expiration uint32
+	}
+	candidates := make([]ranked, len(paths))
+	for i, path := range paths {
+		candidates[i] = ranked{path, pathSize(path), pathExpiration(path)}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].size != candidates[j].size {
+			return candidates[i].size < candidates[j].size
+		}
+		return candidates[i].expiration > candidates[j].expiration
+	})
+
+	if len(candidates) > maxPaths {
+		candidates = candidates[:maxPaths]
+	}
+	ret := make([][]proofs.SignedSet, len(candidates))
+	for i, c := range candidates {
+		ret[i] = c.path
+	}
+	return ret
+}
+
+// describePath lists the signature used for each set in path.
+func describePath(path []proofs.SignedSet) []string {
+	ret := make([]string, 0, len(path))
+	for _, set := range path {
+		header := set.Rrs[0].Header()
+		ret = append(ret, fmt.Sprintf("%s %s %s/%d", dns.TypeToString[header.Rrtype], header.Name, dns.AlgorithmToString[set.Sig.Algorithm], set.Sig.KeyTag))
+	}
+	return ret
+}
+
+// pathChoice is one proof chain considered for an oracle.
+type pathChoice struct {
+	Sigs    []string `json:"sigs"`
+	Size    int      `json:"size"`
+	Expires uint32   `json:"expires"`
+	Submit  int      `json:"submit"`
+	Chosen  bool     `json:"chosen"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// choosePath picks the chain to use with o from paths, which are ranked best
+// first by rankPaths, and reports the alternatives it considered.
+func choosePath(o *oracle.Oracle, paths [][]proofs.SignedSet) ([]proofs.SignedSet, error) {
+	if len(paths) == 1 {
+		return paths[0], nil
+	}
+	chosen, choices, err := selectPath(o, paths)
+	report.result.Alternatives = choices
+	if err != nil {
+		return nil, err
+	}
+	report.decide(fmt.Sprintf("Chose proof path %d of %d", chosen+1, len(paths)))
+	return paths[chosen], nil
+}
+
+// bestPath is like choosePath, but only logs its choice, for commands that
+// handle many records.
+func bestPath(o *oracle.Oracle, paths [][]proofs.SignedSet) ([]proofs.SignedSet, error) {
+	if len(paths) == 1 {
+		return paths[0], nil
+	}
+	chosen, _, err := selectPath(o, paths)
+	if err != nil {
+		return nil, err
+	}
+	return paths[chosen], nil
+}
+
+// selectPath returns the index of the chain to use with o. Chains needing
+// fewest sets submitted are tried first, and the first the oracle accepts,
+// which it won't if it lacks one of the chain's algorithms, is chosen.
+func selectPath(o *oracle.Oracle, paths [][]proofs.SignedSet) (int, []pathChoice, error) {
+	planner := o.NewPlanner()
+	choices := make([]pathChoice, len(paths))
+	known := make([]int, len(paths))
+	var order []int
+	for i, path := range paths {
+		choices[i] = pathChoice{Sigs: describePath(path), Size: pathSize(path), Expires: pathExpiration(path)}
+		plan, err := planner.Plan(path)
+		if err != nil {
+			choices[i].Reason = err.Error()
+			continue
+		}
+		known[i] = plan.First
+		choices[i].Submit = len(path) - plan.First
+		order = append(order, i)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return choices[order[i]].Submit < choices[order[j]].Submit
+	})
+
+	chosen := -1
+	for _, i := range order {
+		if err := o.Accepts(paths[i], known[i]); err != nil {
+			choices[i].Reason = "oracle rejected it: " + err.Error()
+			continue
+		}
+		chosen = i
+		choices[i].Chosen = true
+		choices[i].Reason = "fewest sets to submit, then smallest and longest-lived, that the oracle accepts"
+		break
+	}
+	for i := range choices {
+		if choices[i].Reason == "" {
+			choices[i].Reason = "not needed; a better path was accepted"
+		}
+		log.Info("Considered proof path", "chosen", choices[i].Chosen, "submit", choices[i].Submit, "bytes", choices[i].Size, "expires", dns.TimeToString(choices[i].Expires), "sigs", strings.Join(choices[i].Sigs, ", "), "reason", choices[i].Reason)
+	}
+
+	if chosen < 0 {
+		return -1, choices, fmt.Errorf("oracle accepts none of the %d valid proof paths", len(paths))
+	}
+	return chosen, choices, nil
+}
+
+func countPaths(n int) string {
+	if n == 1 {
+		return "1 path"
+	}
+	return fmt.Sprintf("%d paths", n)
+}
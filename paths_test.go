@@ -0,0 +1,75 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arachnid/dnsprove/chaintest"
+	"github.com/arachnid/dnsprove/dnstest"
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/miekg/dns"
+)
+
+func TestBestPathSkipsUnsupportedAlgorithm(t *testing.T) {
+	saved := *algorithms
+	*algorithms = "RSASHA256,ECDSAP256SHA256"
+	defer func() { *algorithms = saved }()
+
+	// Two roots stand in for a zone signed with both algorithms during a
+	// rollover. The ECDSA chain is smaller, so it is ranked first.
+	var paths [][]proofs.SignedSet
+	var anchors []dns.RR
+	for _, alg := range []uint8{dns.ECDSAP256SHA256, dns.RSASHA256} {
+		s, err := dnstest.NewServer(alg, "com.", "example.com.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Zone("example.com.").Add(testTXT); err != nil {
+			t.Fatal(err)
+		}
+		serve(t, s)
+		sets, _, err := getProofs(dns.TypeTXT, "_ens.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, sets)
+		anchors = append(anchors, s.Anchors()[0])
+	}
+	if ranked := rankPaths(paths); !reflect.DeepEqual(ranked[0], paths[0]) {
+		t.Fatal("ECDSA chain not ranked first")
+	}
+
+	b := chaintest.NewBackend()
+	addr, err := b.DeployOracle(anchors...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := oracle.New(addr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path, err := bestPath(o, paths); err != nil || !reflect.DeepEqual(path, paths[0]) {
+		t.Fatalf("with every algorithm supported, bestPath chose another path (%v)", err)
+	}
+
+	if err := b.SetAlgorithms(addr, dns.RSASHA256); err != nil {
+		t.Fatal(err)
+	}
+	path, err := bestPath(o, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(path, paths[1]) {
+		t.Errorf("bestPath chose the chain signed with %s, which the oracle rejects", dns.AlgorithmToString[path[0].Sig.Algorithm])
+	}
+	if err := b.SetAlgorithms(addr, dns.RSASHA1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bestPath(o, paths); err == nil {
+		t.Error("bestPath chose a path the oracle rejects")
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/arachnid/dnsprove/ens"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	log "github.com/inconshreveable/log15"
+)
+
+// defaultABIContentTypes requests any of the ABI encodings defined by EIP-205.
+const defaultABIContentTypes = 0xf
+
+// recordKey identifies a resolver record, as written on the command line:
+// addr, addr.<coinType>, text.<key>, contenthash, pubkey, abi[.<contentType>]
+// or name.
+type recordKey struct {
+	kind string
+	arg  string
+}
+
+func parseRecordKey(s string) (recordKey, error) {
+	parts := strings.SplitN(s, ".", 2)
+	key := recordKey{kind: strings.ToLower(parts[0])}
+	if len(parts) > 1 {
+		key.arg = parts[1]
+	}
+
+	switch key.kind {
+	case "addr", "abi":
+		if key.arg != "" {
+			if _, err := strconv.ParseUint(key.arg, 10, 64); err != nil {
+				return key, fmt.Errorf("Invalid number in record key %q", s)
+			}
+		}
+	case "text":
+		if key.arg == "" {
+			return key, fmt.Errorf("Record key %q needs a text key, as in text.url", s)
+		}
+	case "contenthash", "pubkey", "name":
+		if key.arg != "" {
+			return key, fmt.Errorf("Record key %q takes no argument", s)
+		}
+	default:
+		return key, fmt.Errorf("Unknown record key %q", s)
+	}
+	return key, nil
+}
+
+func (k recordKey) String() string {
+	if k.arg == "" {
+		return k.kind
+	}
+	return k.kind + "." + k.arg
+}
+
+func (k recordKey) number() uint64 {
+	n, _ := strconv.ParseUint(k.arg, 10, 64)
+	return n
+}
+
+func getRecord(r *ens.Resolver, key recordKey) (string, error) {
+	switch key.kind {
+	case "addr":
+		if key.arg == "" {
+			addr, err := r.Addr()
+			return addr.String(), err
+		}
+		data, err := r.MulticoinAddr(key.number())
+		return hexutil.Encode(data), err
+	case "text":
+		return r.Text(key.arg)
+	case "contenthash":
+		data, err := r.Contenthash()
+		return hexutil.Encode(data), err
+	case "pubkey":
+		x, y, err := r.Pubkey()
+		return hexutil.Encode(x[:]) + "," + hexutil.Encode(y[:]), err
+	case "abi":
+		contentTypes := uint64(defaultABIContentTypes)
+		if key.arg != "" {
+			contentTypes = key.number()
+		}
+		contentType, data, err := r.ABI(contentTypes)
+		if err != nil || contentType == 0 {
+			return "", err
+		}
+		return fmt.Sprintf("%d %s", contentType, hexutil.Encode(data)), nil
+	case "name":
+		return r.Name()
+	}
+	return "", fmt.Errorf("Unknown record key %q", key)
+}
+
+// parseBytes accepts hex data, or @path to read raw data from a file.
+func parseBytes(value string) ([]byte, error) {
+	if strings.HasPrefix(value, "@") {
+		return ioutil.ReadFile(value[1:])
+	}
+	return hexutil.Decode(value)
+}
+
+func setRecord(auth *bind.TransactOpts, r *ens.Resolver, key recordKey, value string) (*types.Transaction, error) {
+	switch key.kind {
+	case "addr":
+		if key.arg == "" {
+			if !common.IsHexAddress(value) {
+				return nil, fmt.Errorf("Invalid address %q", value)
+			}
+			return r.SetAddr(auth, common.HexToAddress(value))
+		}
+		data, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return r.SetMulticoinAddr(auth, key.number(), data)
+	case "text":
+		return r.SetText(auth, key.arg, value)
+	case "contenthash":
+		data, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return r.SetContenthash(auth, data)
+	case "pubkey":
+		parts := strings.Split(value, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Public key must be given as x,y")
+		}
+		x, err := hexutil.Decode(parts[0])
+		if err != nil || len(x) != 32 {
+			return nil, fmt.Errorf("Invalid public key x coordinate %q", parts[0])
+		}
+		y, err := hexutil.Decode(parts[1])
+		if err != nil || len(y) != 32 {
+			return nil, fmt.Errorf("Invalid public key y coordinate %q", parts[1])
+		}
+		return r.SetPubkey(auth, common.BytesToHash(x), common.BytesToHash(y))
+	case "abi":
+		if key.arg == "" {
+			return nil, fmt.Errorf("Setting an ABI needs a content type, as in abi.1")
+		}
+		data, err := parseBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return r.SetABI(auth, key.number(), data)
+	case "name":
+		return r.SetName(auth, value)
+	}
+	return nil, fmt.Errorf("Unknown record key %q", key)
+}
+
+// recordsName normalizes a name argument, treating an address as shorthand for
+// its reverse record.
+func recordsName(arg string) (string, error) {
+	if common.IsHexAddress(arg) {
+		return ens.ReverseName(common.HexToAddress(arg)), nil
+	}
+	return ens.Normalize(arg)
+}
+
+func recordsCommand(args []string) {
+	recordsFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] records [records options] get name [key...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] records [records options] set name key value\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nKeys are addr, addr.<coinType>, text.<key>, contenthash, pubkey, abi[.<contentType>] and name.\n")
+		fmt.Fprintf(os.Stderr, "Binary values are hex, or @path to read them from a file. Public keys are x,y.\n")
+		fmt.Fprintf(os.Stderr, "An address may be given in place of a name to use its reverse record.\n")
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nRecords command options:\n")
+		recordsFlags.PrintDefaults()
+	}
+	recordsFlags.Parse(args)
+
+	if recordsFlags.NArg() < 2 {
+		recordsFlags.Usage()
+		return
+	}
+	op := recordsFlags.Arg(0)
+	if (op != "get" && op != "set") || (op == "set" && recordsFlags.NArg() != 4) {
+		recordsFlags.Usage()
+		return
+	}
+
+	name, err := recordsName(recordsFlags.Arg(1))
+	if err != nil {
+		log.Crit("Invalid name", "err", err)
+		exit(1)
+	}
+
+	keyArgs := recordsFlags.Args()[2:]
+	if op == "set" {
+		keyArgs = keyArgs[:1]
+	}
+	var keys []recordKey
+	for _, arg := range keyArgs {
+		key, err := parseRecordKey(arg)
+		if err != nil {
+			log.Crit("Invalid record key", "err", err)
+			exit(1)
+		}
+		keys = append(keys, key)
+	}
+
+	conn, err := dial()
+	if err != nil {
+		log.Crit("Error connecting to Ethereum node", "err", err)
+		exit(1)
+	}
+
+	registry, err := openRegistry(conn, *recordsRegistryAddress)
+	if err != nil {
+		log.Crit("Error instantiating registry", "err", err)
+		exit(1)
+	}
+
+	resolver, err := registry.Resolver(name)
+	if err != nil {
+		log.Crit("Could not get resolver", "name", name, "err", err)
+		exit(1)
+	}
+
+	if op == "set" {
+		owner := getOwner(registry, name)
+		auth := ownerTransactor(conn, owner, *recordsYes, "Send a transaction to set %s for %s to %q on resolver %s?", keys[0], name, recordsFlags.Arg(3), resolver.Address().String())
+		if auth == nil {
+			return
+		}
+		tx, err := setRecord(auth, resolver, keys[0], recordsFlags.Arg(3))
+		if err != nil {
+			log.Crit("Error setting record", "name", name, "key", keys[0], "err", err)
+			exit(1)
+		}
+		log.Info("Sent transaction", "tx", tx.Hash().String())
+		return
+	}
+
+	profiles, err := resolver.Profiles()
+	if err != nil {
+		log.Crit("Error checking resolver profiles", "resolver", resolver.Address().String(), "err", err)
+		exit(1)
+	}
+	names := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		names = append(names, profile.Name)
+	}
+	fmt.Printf("%s\n", name)
+	fmt.Printf("  resolver     %s (%s)\n", resolver.Address().String(), strings.Join(names, ", "))
+
+	if len(keys) == 0 {
+		// Show every record that doesn't need a key to look it up.
+		for _, profile := range profiles {
+			switch profile {
+			case ens.AddrProfile, ens.ContenthashProfile, ens.PubkeyProfile, ens.NameProfile:
+				keys = append(keys, recordKey{kind: strings.ToLower(profile.Name)})
+			}
+		}
+	}
+	for _, key := range keys {
+		value, err := getRecord(resolver, key)
+		if err != nil {
+			value = "error: " + err.Error()
+		}
+		fmt.Printf("  %-12s %s\n", key, value)
+	}
+}
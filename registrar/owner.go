@@ -0,0 +1,46 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package registrar
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/miekg/dns"
+)
+
+// OwnerPrefix is the prefix of the TXT string naming an ENS owner, as in
+// "a=0x314159265dd8dbb310642f98f50c066173c1259b".
+const OwnerPrefix = "a=0x"
+
+// ParseOwner returns the address named by an _ens TXT RRSet, and false if it
+// names none. As in the registrar, the first well-formed string wins.
+func ParseOwner(rrs []dns.RR) (common.Address, bool) {
+	for _, rr := range rrs {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		for _, s := range txt.Txt {
+			if !strings.HasPrefix(s, OwnerPrefix) {
+				continue
+			}
+			hex := s[len(OwnerPrefix):]
+			if len(hex) != 2*common.AddressLength || !isHex(hex) {
+				continue
+			}
+			return common.HexToAddress(hex), true
+		}
+	}
+	return common.Address{}, false
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -4,6 +4,7 @@
 package registrar
 
 //go:generate abigen --sol ../contracts/dnsregistrar.sol --pkg contracts --out ../contracts/dnsregistrar.go
+//go:generate abigen --sol ../contracts/dnsregistrar2.sol --pkg contracts --out ../contracts/dnsregistrar2.go
 
 import (
 	"errors"
@@ -20,11 +21,23 @@ import (
 	"github.com/miekg/dns"
 )
 
+// DNSSEC_CLAIM_INTERFACE_ID is the interface implemented by legacy registrars,
+// which use oracles that store proven records.
 var DNSSEC_CLAIM_INTERFACE_ID = [4]byte{0x1a, 0xa2, 0xe6, 0x41}
+
+// DNS_REGISTRAR_INTERFACE_ID is the interface implemented by current
+// registrars, which take RRSetWithSignature chains for a stateless oracle.
+var DNS_REGISTRAR_INTERFACE_ID = [4]byte{0x2f, 0x43, 0x54, 0x28}
+
 var InterfaceNotSupportedError = errors.New("Interface not supported")
+var StatelessOracleError = errors.New("Contract uses a stateless oracle, which its legacy interface cannot use")
+var ResolverNotSupportedError = errors.New("Registrar does not support setting a resolver when claiming")
+var UnclaimNotSupportedError = errors.New("Registrar does not support unclaiming names")
 
 type DNSRegistrar struct {
 	r       *contracts.DNSRegistrar
+	current *contracts.DNSRegistrar2
+	legacy  bool
 	backend bind.ContractBackend
 }
 
@@ -34,19 +47,48 @@ func New(addr common.Address, backend bind.ContractBackend) (*DNSRegistrar, erro
 		return nil, err
 	}
 
-	// Check it really implements the interface we need.
-	if ok, err := registrar.SupportsInterface(nil, DNSSEC_CLAIM_INTERFACE_ID); !ok || err != nil {
-		return nil, InterfaceNotSupportedError
+	current, err := contracts.NewDNSRegistrar2(addr, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check it really implements an interface we know, preferring the current one.
+	legacy := false
+	if ok, err := current.SupportsInterface(nil, DNS_REGISTRAR_INTERFACE_ID); !ok || err != nil {
+		if ok, err := registrar.SupportsInterface(nil, DNSSEC_CLAIM_INTERFACE_ID); !ok || err != nil {
+			return nil, InterfaceNotSupportedError
+		}
+		legacy = true
 	}
 
 	return &DNSRegistrar{
 		registrar,
+		current,
+		legacy,
 		backend,
 	}, nil
 }
 
+// Legacy reports whether the registrar implements only the legacy claim
+// interface.
+func (r *DNSRegistrar) Legacy() bool {
+	return r.legacy
+}
+
+// SupportsResolver reports whether the registrar can set a resolver and
+// address in the same transaction as a claim.
+func (r *DNSRegistrar) SupportsResolver() bool {
+	return !r.legacy
+}
+
 func (r *DNSRegistrar) GetOracle() (*oracle.Oracle, error) {
-	addr, err := r.r.Oracle(nil)
+	var addr common.Address
+	var err error
+	if r.legacy {
+		addr, err = r.r.Oracle(nil)
+	} else {
+		addr, err = r.current.Oracle(nil)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -60,10 +102,23 @@ func (r *DNSRegistrar) Claim(opts *bind.TransactOpts, name string, sets []proofs
 		return nil, err
 	}
 
+	if !r.legacy {
+		input, err := oracle.ProofInput(sets)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Info("Transaction to proveAndClaim()", "name", name, "proofs", len(input))
+		return r.current.ProveAndClaim(opts, dnsname, input)
+	}
+
 	o, err := r.GetOracle()
 	if err != nil {
 		return nil, err
 	}
+	if o.Stateless() {
+		return nil, StatelessOracleError
+	}
 
 	// If the RRset already matches, we just need to claim, not prove.
 	matches, err := o.RecordMatches(sets[len(sets)-1])
@@ -95,13 +150,44 @@ func (r *DNSRegistrar) Claim(opts *bind.TransactOpts, name string, sets []proofs
 	}
 }
 
+// ClaimWithResolver claims a name and sets its resolver and address in the same
+// transaction. Only current registrars support this.
+func (r *DNSRegistrar) ClaimWithResolver(opts *bind.TransactOpts, name string, sets []proofs.SignedSet, resolver, addr common.Address) (*types.Transaction, error) {
+	if r.legacy {
+		return nil, ResolverNotSupportedError
+	}
+
+	dnsname, err := oracle.PackName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := oracle.ProofInput(sets)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Transaction to proveAndClaimWithResolver()", "name", name, "proofs", len(input), "resolver", resolver.String(), "addr", addr.String())
+	return r.current.ProveAndClaimWithResolver(opts, dnsname, input, resolver, addr)
+}
+
+// Unclaim deletes the name's _ens TXT record from the oracle, using the NSEC
+// record that ends sets, and then claims the name for no one. opts.Nonce is
+// advanced past every transaction but the last, as for a single claim.
 func (r *DNSRegistrar) Unclaim(opts *bind.TransactOpts, name string, sets []proofs.SignedSet) ([]*types.Transaction, error) {
+	if !r.legacy {
+		return nil, UnclaimNotSupportedError
+	}
+
 	var txs []*types.Transaction
 
 	o, err := r.GetOracle()
 	if err != nil {
 		return nil, err
 	}
+	if o.Stateless() {
+		return nil, StatelessOracleError
+	}
 
 	nsec, sets := sets[len(sets)-1], sets[:len(sets)-1]
 
@@ -111,7 +197,7 @@ func (r *DNSRegistrar) Unclaim(opts *bind.TransactOpts, name string, sets []proo
 	}
 
 	// We're deleting a domain. If it's not there, there's nothing to do.
-	_, _, hash, err := o.Rrdata(dns.TypeTXT, "_ens."+name)
+	_, _, hash, err := o.Rrdata(nil, dns.TypeTXT, "_ens."+name)
 	if err != nil {
 		return nil, err
 	}
@@ -129,18 +215,17 @@ func (r *DNSRegistrar) Unclaim(opts *bind.TransactOpts, name string, sets []proo
 		}
 
 		// Use the NSEC's signing record as proof of its validity
-		proof, err = sets[len(sets)-2].PackRRSet()
+		proof, err = sets[len(sets)-1].PackRRSet()
 		if err != nil {
 			return txs, err
 		}
 
-		// Send the NSEC to delete the TXT records
+		// Send the NSEC to delete the TXT records. DeleteRRSet advances the nonce.
 		log.Info("Sending transaction to delete RRSet", "type", "TXT", "name", "_ens."+name)
 		deletetx, err := o.DeleteRRSet(opts, dns.TypeTXT, "_ens."+name, nsec, proof)
 		if err != nil {
 			return txs, err
 		}
-		opts.Nonce = opts.Nonce.Add(opts.Nonce, big.NewInt(1))
 		txs = append(txs, deletetx)
 	}
 
@@ -0,0 +1,290 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arachnid/dnsprove/proofs"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// Strategies for using several resolvers, set with -resolver-strategy.
+const (
+	strategyFailover   = "failover"
+	strategyFirstValid = "first-valid"
+	strategyQuorum     = "quorum"
+)
+
+// A resolver that fails to answer is marked down for minBackoff, doubling
+// with each consecutive failure up to maxBackoff.
+const (
+	minBackoff = 5 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// resolver is a DNS server given with -server.
+type resolver struct {
+	addr string
+
+	mu        sync.Mutex
+	failures  int
+	downUntil time.Time
+}
+
+// response is a DNS response and the resolver it came from.
+type response struct {
+	msg  *dns.Msg
+	from *resolver
+}
+
+// resolvers are shared by every client, so a resolver marked down stays down
+// across proofs.
+var resolvers []*resolver
+
+// loadResolvers sets up the resolvers given with -server, and checks
+// -resolver-strategy and -quorum.
+func loadResolvers() {
+	for _, addr := range strings.Split(*server, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			resolvers = append(resolvers, &resolver{addr: addr})
+			resolverUp.WithLabelValues(addr).Set(1)
+		}
+	}
+	if len(resolvers) == 0 && offlineZones == nil {
+		log.Crit("No DNS server given")
+		exit(ExitError)
+	}
+
+	switch *strategy {
+	case strategyFailover, strategyFirstValid, strategyQuorum:
+	default:
+		log.Crit("Unknown resolver strategy", "strategy", *strategy)
+		exit(ExitError)
+	}
+	if *strategy != strategyQuorum || len(resolvers) == 0 {
+		// Zones loaded with -zones need no resolvers, and so no quorum.
+		return
+	}
+	if *quorum == 0 {
+		*quorum = len(resolvers)/2 + 1
+	}
+	if *quorum < 0 || *quorum > len(resolvers) {
+		log.Crit("Quorum must be between 1 and the number of servers", "quorum", *quorum, "servers", len(resolvers))
+		exit(ExitError)
+	}
+}
+
+// up reports whether res isn't marked down.
+func (res *resolver) up() bool {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	return !time.Now().Before(res.downUntil)
+}
+
+func (res *resolver) succeeded() {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if res.failures > 0 {
+		log.Info("Resolver is answering again", "resolver", res.addr)
+		resolverUp.WithLabelValues(res.addr).Set(1)
+	}
+	res.failures = 0
+	res.downUntil = time.Time{}
+}
+
+func (res *resolver) failed(err error) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	backoff := maxBackoff
+	if res.failures < 8 {
+		backoff = minBackoff << uint(res.failures)
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	res.failures++
+	res.downUntil = time.Now().Add(backoff)
+	resolverFailures.WithLabelValues(res.addr).Inc()
+	resolverUp.WithLabelValues(res.addr).Set(0)
+	log.Warn("Resolver failed; marking it down", "resolver", res.addr, "failures", res.failures, "backoff", backoff, "err", err)
+}
+
+// candidates returns the resolvers that are up, in the order given. If none
+// is, it returns them all, since a resolver that's down is better than none.
+func (client *Client) candidates() []*resolver {
+	var ret []*resolver
+	for _, res := range client.resolvers {
+		if res.up() {
+			ret = append(ret, res)
+		}
+	}
+	if len(ret) == 0 {
+		return client.resolvers
+	}
+	return ret
+}
+
+// source describes the resolver a response came from, for the trace, where
+// there is more than one it could have.
+func (client *Client) source(res *resolver) string {
+	if res == nil || len(client.resolvers) < 2 {
+		return ""
+	}
+	return "from " + res.addr
+}
+
+// exchange sends a query to each resolver that's up in turn, until one
+// answers. One that answers SERVFAIL or REFUSED is passed over, but its
+// response is returned if no other resolver does better.
+func (client *Client) exchange(qtype uint16, qclass uint16, name string) (*dns.Msg, *resolver, error) {
+	var fallback response
+	var err error
+	for _, res := range client.candidates() {
+		var r *dns.Msg
+		r, err = client.exchangeWith(res, qtype, qclass, name)
+		if err != nil {
+			continue
+		}
+		if r.Rcode == dns.RcodeServerFailure || r.Rcode == dns.RcodeRefused {
+			log.Warn("Resolver could not answer", "resolver", res.addr, "type", dns.TypeToString[qtype], "name", name, "rcode", dns.RcodeToString[r.Rcode])
+			if fallback.msg == nil {
+				fallback = response{r, res}
+			}
+			continue
+		}
+		return r, res, nil
+	}
+	if fallback.msg != nil {
+		return fallback.msg, fallback.from, nil
+	}
+	return nil, nil, err
+}
+
+// tryOtherResolvers asks every resolver but from, whose answer didn't
+// validate with err, for one that does. If none does, it returns from's
+// result.
+func (client *Client) tryOtherResolvers(qtype, qclass uint16, name string, from *resolver, found bool, err error) ([][]proofs.SignedSet, bool, error) {
+	log.Warn("Resolver's answer did not validate; trying others", "resolver", from.addr, "type", dns.TypeToString[qtype], "name", name, "err", err)
+	for _, res := range client.candidates() {
+		if res == from {
+			continue
+		}
+		r, qerr := client.exchangeWith(res, qtype, qclass, name)
+		if qerr != nil {
+			continue
+		}
+		paths, rfound, verr := client.validateResponse(qtype, qclass, name, r, client.source(res))
+		if verr != nil {
+			log.Warn("Resolver's answer did not validate", "resolver", res.addr, "type", dns.TypeToString[qtype], "name", name, "err", verr)
+			continue
+		}
+		resolverConflicts.WithLabelValues(strategyFirstValid).Inc()
+		log.Warn("Resolvers disagree; using the answer that validates", "type", dns.TypeToString[qtype], "name", name, "resolver", res.addr, "rejected", from.addr)
+		return paths, rfound, nil
+	}
+	return nil, found, err
+}
+
+// queryQuorum sends a query to every resolver that's up at once, and
+// validates each answer. The result is accepted only if at least -quorum
+// resolvers gave valid answers with the same records.
+func (client *Client) queryQuorum(qtype, qclass uint16, name string) ([][]proofs.SignedSet, bool, error) {
+	targets := client.candidates()
+	if len(targets) < *quorum {
+		targets = client.resolvers
+	}
+
+	node := &traceNode{Kind: "consensus", Name: name, Type: dns.TypeToString[qtype], Detail: fmt.Sprintf("%d of %d resolvers must agree", *quorum, len(client.resolvers))}
+	defer client.enter(node)()
+
+	responses := make([]*dns.Msg, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, res := range targets {
+		wg.Add(1)
+		go func(i int, res *resolver) {
+			defer wg.Done()
+			responses[i], errs[i] = client.exchangeWith(res, qtype, qclass, name)
+		}(i, res)
+	}
+	wg.Wait()
+
+	// Validation isn't safe to run concurrently, and needn't be: the DNSKEY
+	// and DS records each answer needs are only queried for once.
+	type vote struct {
+		paths     [][]proofs.SignedSet
+		found     bool
+		resolvers []string
+	}
+	var best *vote
+	votes := make(map[string]*vote)
+	answers := make([]string, len(targets))
+	var err error
+	for i, res := range targets {
+		if errs[i] != nil {
+			answers[i] = "no response: " + errs[i].Error()
+			err = client.queryFailed(qtype, name, client.source(res), errs[i])
+			continue
+		}
+		paths, found, verr := client.validateResponse(qtype, qclass, name, responses[i], client.source(res))
+		if verr != nil {
+			answers[i] = "invalid: " + verr.Error()
+			err = verr
+			continue
+		}
+		answers[i] = answerKey(qtype, paths[0], found)
+		v := votes[answers[i]]
+		if v == nil {
+			v = &vote{paths: paths, found: found}
+			votes[answers[i]] = v
+		}
+		v.resolvers = append(v.resolvers, res.addr)
+		if best == nil || len(v.resolvers) > len(best.resolvers) {
+			best = v
+		}
+	}
+
+	if best == nil || len(best.resolvers) < len(targets) {
+		resolverConflicts.WithLabelValues(strategyQuorum).Inc()
+		log.Warn("Resolvers disagree", "type", dns.TypeToString[qtype], "name", name, "resolvers", len(targets), "answers", len(votes))
+		for i, res := range targets {
+			log.Warn("Resolver answer", "resolver", res.addr, "type", dns.TypeToString[qtype], "name", name, "answer", answers[i])
+		}
+	}
+	if best == nil {
+		node.reject("no resolver gave a valid answer")
+		return nil, false, err
+	}
+	if len(best.resolvers) < *quorum {
+		reason := fmt.Sprintf("at most %d resolvers agree, %d needed", len(best.resolvers), *quorum)
+		node.reject(reason)
+		return nil, false, fmt.Errorf("No quorum for %s %s: %s", dns.TypeToString[qtype], name, reason)
+	}
+	node.accept(fmt.Sprintf("%d agree: %s", len(best.resolvers), strings.Join(best.resolvers, ", ")))
+	return best.paths, best.found, nil
+}
+
+// answerKey summarises a validated answer so that resolvers' answers can be
+// compared: its records, without the TTLs that vary between caches, or that
+// there are none, which any valid denial proves equally well.
+func answerKey(qtype uint16, path []proofs.SignedSet, found bool) string {
+	if !found {
+		return "no " + dns.TypeToString[qtype] + " records"
+	}
+	set := path[len(path)-1]
+	rrs := make([]string, len(set.Rrs))
+	for i, rr := range set.Rrs {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = 0
+		rrs[i] = rr.String()
+	}
+	sort.Strings(rrs)
+	return strings.Join(rrs, "; ")
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import "testing"
+
+func TestLoadResolversQuorum(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		server   string
+		strategy string
+		quorum   int
+		want     int
+	}{
+		{"offline zones with no servers", "", strategyQuorum, 0, 0},
+		{"default majority", "a,b,c", strategyQuorum, 0, 2},
+		{"explicit quorum", "a,b,c", strategyQuorum, 3, 3},
+		{"quorum ignored by failover", "a", strategyFailover, 5, 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			savedResolvers, savedZones := resolvers, offlineZones
+			savedServer, savedStrategy, savedQuorum := *server, *strategy, *quorum
+			defer func() {
+				resolvers, offlineZones = savedResolvers, savedZones
+				*server, *strategy, *quorum = savedServer, savedStrategy, savedQuorum
+			}()
+
+			resolvers = nil
+			offlineZones = &zoneFiles{}
+			*server, *strategy, *quorum = tc.server, tc.strategy, tc.quorum
+			loadResolvers()
+			if *quorum != tc.want {
+				t.Errorf("quorum = %d, want %d", *quorum, tc.want)
+			}
+		})
+	}
+}
@@ -22,6 +22,8 @@ import (
 
 var DNSSEC_ROOT_CLAIM_INTERFACE_ID = [4]byte{0xc7, 0xfe, 0x16, 0xbf}
 var InterfaceNotSupportedError = errors.New("Interface not supported")
+var StatelessOracleError = errors.New("Contract uses a stateless oracle, which its legacy interface cannot use")
+var UndeletableRecordError = errors.New("Oracle holds a TXT record that cannot be proven deleted")
 
 type Root struct {
 	r       *contracts.Root
@@ -64,6 +66,9 @@ func (r *Root) Claim(opts *bind.TransactOpts, name string, sets []proofs.SignedS
 	if err != nil {
 		return nil, err
 	}
+	if o.Stateless() {
+		return nil, StatelessOracleError
+	}
 
 	// If the RRset already matches, we just need to claim, not prove.
 	matches, err := o.RecordMatches(sets[len(sets)-1])
@@ -95,6 +100,11 @@ func (r *Root) Claim(opts *bind.TransactOpts, name string, sets []proofs.SignedS
 	}
 }
 
+// ClaimDefault assigns a TLD to the default registrar. If the oracle holds an
+// _ens.nic TXT record for it, that is first deleted using the NSEC record that
+// ends nsecsets; if nsecsets is empty, as for an unsigned zone, it returns
+// UndeletableRecordError. opts.Nonce is advanced past every transaction but
+// the last.
 func (r *Root) ClaimDefault(opts *bind.TransactOpts, name string, nsecsets, dssets []proofs.SignedSet) ([]*types.Transaction, error) {
 	var txs []*types.Transaction
 
@@ -102,24 +112,30 @@ func (r *Root) ClaimDefault(opts *bind.TransactOpts, name string, nsecsets, dsse
 	if err != nil {
 		return nil, err
 	}
+	if o.Stateless() {
+		return nil, StatelessOracleError
+	}
 
 	// Check if the TXT record is there and so requires deleting.
-	_, _, hash, err := o.Rrdata(dns.TypeTXT, "_ens.nic."+name)
+	_, _, hash, err := o.Rrdata(nil, dns.TypeTXT, "_ens.nic."+name)
 	if err != nil {
 		return nil, err
 	}
 	if hash != [20]byte{} {
-    nsec, nsecsets := nsecsets[len(nsecsets)-1], nsecsets[:len(nsecsets)-1]
+		if len(nsecsets) == 0 {
+			return nil, UndeletableRecordError
+		}
+		nsec, nsecsets := nsecsets[len(nsecsets)-1], nsecsets[:len(nsecsets)-1]
 
-  	known, err := o.FindFirstUnknownProof(nsecsets)
-  	if err != nil {
-  		return nil, err
-  	}
+		known, err := o.FindFirstUnknownProof(nsecsets)
+		if err != nil {
+			return nil, err
+		}
 
 		var proof []byte
 		// Update proofs so the NSEC can be verified.
 		if known < len(nsecsets) {
-			log.Info("Sending transaction to update proofs", "name", "_ens."+name, "count", len(nsecsets)-known)
+			log.Info("Sending transaction to update proofs", "name", "_ens.nic."+name, "count", len(nsecsets)-known)
 			tx, err := o.SendProofs(opts, nsecsets, known)
 			if err != nil {
 				return nil, err
@@ -129,51 +145,50 @@ func (r *Root) ClaimDefault(opts *bind.TransactOpts, name string, nsecsets, dsse
 		}
 
 		// Use the NSEC's signing record as proof of its validity
-		proof, err = nsecsets[len(nsecsets)-2].PackRRSet()
+		proof, err = nsecsets[len(nsecsets)-1].PackRRSet()
 		if err != nil {
 			return txs, err
 		}
 
-		// Send the NSEC to delete the TXT records
-		log.Info("Sending transaction to delete RRSet", "type", "TXT", "name", "_ens."+name)
-		deletetx, err := o.DeleteRRSet(opts, dns.TypeTXT, "_ens."+name, nsec, proof)
+		// Send the NSEC to delete the TXT records. DeleteRRSet advances the nonce.
+		log.Info("Sending transaction to delete RRSet", "type", "TXT", "name", "_ens.nic."+name)
+		deletetx, err := o.DeleteRRSet(opts, dns.TypeTXT, "_ens.nic."+name, nsec, proof)
 		if err != nil {
 			return txs, err
 		}
-		opts.Nonce = opts.Nonce.Add(opts.Nonce, big.NewInt(1))
 		txs = append(txs, deletetx)
 	}
 
-  known, err := o.FindFirstUnknownProof(dssets)
-  if err != nil {
-    return nil, err
-  }
+	known, err := o.FindFirstUnknownProof(dssets)
+	if err != nil {
+		return txs, err
+	}
 
-  dnsname, err := oracle.PackName(name)
+	dnsname, err := oracle.PackName(name)
 	if err != nil {
-		return nil, err
+		return txs, err
 	}
 
-  if known < len(dssets) {
-    data, proof, err := o.SerializeProofs(dssets, known)
+	if known < len(dssets) {
+		data, proof, err := o.SerializeProofs(dssets, known)
 		if err != nil {
-			return nil, err
+			return txs, err
 		}
 
 		log.Info("Transaction to proveAndRegisterDefaultTLD()", "name", name, "data", hexutil.Encode(data), "lastProof", hexutil.Encode(proof))
 		tx, err := r.r.ProveAndRegisterDefaultTLD(opts, dnsname, data, proof)
-    if err != nil {
-      return nil, err
-    }
-    txs = append(txs, tx)
-  } else {
-  	log.Info("Sending transaction to set name to default registrar", "name", name)
-  	tx, err := r.r.RegisterTLD(opts, dnsname, []byte{})
-  	if err != nil {
-  		return txs, err
-  	}
-  	txs = append(txs, tx)
-  }
+		if err != nil {
+			return txs, err
+		}
+		txs = append(txs, tx)
+	} else {
+		log.Info("Sending transaction to set name to default registrar", "name", name)
+		tx, err := r.r.RegisterTLD(opts, dnsname, []byte{})
+		if err != nil {
+			return txs, err
+		}
+		txs = append(txs, tx)
+	}
 
 	return txs, nil
 }
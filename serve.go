@@ -0,0 +1,471 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/arachnid/dnsprove/ens"
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// proofCacheEntry is a validated proof chain, kept until the first of its
+// signatures or TTLs expires.
+type proofCacheEntry struct {
+	sets    []proofs.SignedSet
+	found   bool
+	expires time.Time
+}
+
+type proofCache struct {
+	mu      sync.Mutex
+	entries map[string]*proofCacheEntry
+}
+
+func newProofCache() *proofCache {
+	return &proofCache{entries: make(map[string]*proofCacheEntry)}
+}
+
+func proofCacheKey(qtype uint16, name string) string {
+	return dns.TypeToString[qtype] + " " + strings.ToLower(dns.Fqdn(name))
+}
+
+// chainExpiry returns when a proof chain stops being usable: the earliest of
+// its signatures' expirations and its records' TTLs.
+func chainExpiry(sets []proofs.SignedSet, now time.Time) time.Time {
+	expires := time.Time{}
+	for _, set := range sets {
+		candidates := []time.Time{time.Unix(int64(set.Sig.Expiration), 0)}
+		for _, rr := range set.Rrs {
+			candidates = append(candidates, now.Add(time.Duration(rr.Header().Ttl)*time.Second))
+		}
+		for _, t := range candidates {
+			if expires.IsZero() || t.Before(expires) {
+				expires = t
+			}
+		}
+	}
+	return expires
+}
+
+// get returns the proofs for a record, from the cache if they are still valid.
+func (c *proofCache) get(qtype uint16, name string) ([]proofs.SignedSet, bool, error) {
+	key := proofCacheKey(qtype, name)
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.sets, entry.found, nil
+	}
+
+	sets, found, err := getProofs(qtype, name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = &proofCacheEntry{sets, found, chainExpiry(sets, now)}
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.mu.Unlock()
+	return sets, found, nil
+}
+
+// rateLimiter is a token bucket per client address.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	clients map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), clients: make(map[string]*bucket)}
+}
+
+func (l *rateLimiter) allow(client string) bool {
+	if l.rate <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.clients[client]
+	if !ok {
+		// Forget clients whose buckets have refilled, so the map doesn't grow
+		// without bound.
+		for k, other := range l.clients {
+			if now.Sub(other.last).Seconds()*l.rate >= l.burst {
+				delete(l.clients, k)
+			}
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type proofServer struct {
+	conn     *chainClient
+	oracle   *oracle.Oracle
+	registry *ens.ENS
+	cache    *proofCache
+	limiter  *rateLimiter
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// proofEntry is one set of a proof chain, in structured form and in the form
+// printed by prove -print.
+type proofEntry struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Inception  uint32        `json:"inception"`
+	Expiration uint32        `json:"expiration"`
+	RRs        []string      `json:"rrs"`
+	Signature  string        `json:"signature"`
+	Data       hexutil.Bytes `json:"data"`
+	Sig        hexutil.Bytes `json:"sig"`
+	Print      string        `json:"print"`
+}
+
+type proofResponse struct {
+	Name  string       `json:"name"`
+	Type  string       `json:"type"`
+	Found bool         `json:"found"`
+	Chain []proofEntry `json:"chain"`
+	Print string       `json:"print"`
+}
+
+// calldataResponse holds the arguments for the transactions prove would send.
+// For legacy oracles, Data and Proof are the arguments to submitRRSets, trimmed
+// to the sets the oracle doesn't already have, and Delete holds the arguments
+// to deleteRRSet if the record doesn't exist. For stateless oracles, Input is
+// the argument to verifyRRSet.
+type calldataResponse struct {
+	Name   string                   `json:"name"`
+	Type   string                   `json:"type"`
+	Found  bool                     `json:"found"`
+	Oracle string                   `json:"oracleVersion"`
+	Known  int                      `json:"known"`
+	Submit int                      `json:"submit"`
+	Data   hexutil.Bytes            `json:"data,omitempty"`
+	Proof  hexutil.Bytes            `json:"proof,omitempty"`
+	Delete *deleteCalldata          `json:"delete,omitempty"`
+	Input  []rrsetWithSignatureJSON `json:"input,omitempty"`
+}
+
+type deleteCalldata struct {
+	DeleteType uint16        `json:"deleteType"`
+	DeleteName hexutil.Bytes `json:"deleteName"`
+	NSEC       hexutil.Bytes `json:"nsec"`
+	Sig        hexutil.Bytes `json:"sig"`
+	Proof      hexutil.Bytes `json:"proof"`
+}
+
+type rrsetWithSignatureJSON struct {
+	RRSet hexutil.Bytes `json:"rrset"`
+	Sig   hexutil.Bytes `json:"sig"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug("Error writing response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, struct {
+		Error apiError `json:"error"`
+	}{apiError{code, message}})
+}
+
+// writeProofError maps an error from getProofs to an HTTP response.
+func writeProofError(w http.ResponseWriter, err error) {
+	switch proofExitCode(err) {
+	case ExitDNS:
+		writeError(w, http.StatusBadGateway, exitCodeNames[ExitDNS], err.Error())
+	case ExitValidation:
+		writeError(w, http.StatusUnprocessableEntity, exitCodeNames[ExitValidation], err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, exitCodeNames[ExitError], err.Error())
+	}
+}
+
+// parseQuery reads the type and name parameters of a request.
+func parseQuery(w http.ResponseWriter, r *http.Request) (uint16, string, bool) {
+	typ := r.URL.Query().Get("type")
+	if typ == "" {
+		typ = "TXT"
+	}
+	qtype, ok := dns.StringToType[strings.ToUpper(typ)]
+	if !ok {
+		writeError(w, http.StatusBadRequest, exitCodeNames[ExitError], fmt.Sprintf("Unrecognised query type %q", typ))
+		return 0, "", false
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, exitCodeNames[ExitError], "Missing name parameter")
+		return 0, "", false
+	}
+	return qtype, name, true
+}
+
+func (s *proofServer) handleProof(w http.ResponseWriter, r *http.Request) {
+	qtype, name, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	sets, found, err := s.cache.get(qtype, name)
+	if err != nil {
+		writeProofError(w, err)
+		return
+	}
+
+	resp := proofResponse{Name: dns.Fqdn(name), Type: dns.TypeToString[qtype], Found: found}
+	for _, set := range sets {
+		header := set.Rrs[0].Header()
+		entry := proofEntry{
+			Name:       header.Name,
+			Type:       dns.TypeToString[header.Rrtype],
+			Inception:  set.Sig.Inception,
+			Expiration: set.Sig.Expiration,
+			Signature:  set.Sig.String(),
+		}
+		for _, rr := range set.Rrs {
+			entry.RRs = append(entry.RRs, rr.String())
+		}
+		if entry.Data, err = set.Pack(); err == nil {
+			entry.Sig, err = set.PackSignature()
+		}
+		if err == nil {
+			entry.Print, err = formatProof(set)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, exitCodeNames[ExitError], err.Error())
+			return
+		}
+		resp.Print += entry.Print
+		resp.Chain = append(resp.Chain, entry)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *proofServer) handleCalldata(w http.ResponseWriter, r *http.Request) {
+	qtype, name, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	if s.oracle == nil {
+		writeError(w, http.StatusServiceUnavailable, exitCodeNames[ExitChain], "No oracle configured")
+		return
+	}
+	sets, found, err := s.cache.get(qtype, name)
+	if err != nil {
+		writeProofError(w, err)
+		return
+	}
+
+	resp := calldataResponse{Name: dns.Fqdn(name), Type: dns.TypeToString[qtype], Found: found, Oracle: s.oracle.Version().String()}
+	if s.oracle.Stateless() {
+		input, err := oracle.ProofInput(sets)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, exitCodeNames[ExitError], err.Error())
+			return
+		}
+		for _, in := range input {
+			resp.Input = append(resp.Input, rrsetWithSignatureJSON{in.Rrset, in.Sig})
+		}
+		resp.Submit = len(input)
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	chain := sets
+	if !found {
+		// The NSEC is sent separately, to deleteRRSet.
+		chain = sets[:len(sets)-1]
+	}
+	known, err := s.oracle.FindFirstUnknownProof(chain)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, exitCodeNames[ExitChain], err.Error())
+		return
+	}
+	resp.Known, resp.Submit = known, len(chain)-known
+	if known < len(chain) {
+		if resp.Data, resp.Proof, err = s.oracle.SerializeProofs(chain, known); err != nil {
+			writeError(w, http.StatusServiceUnavailable, exitCodeNames[ExitChain], err.Error())
+			return
+		}
+	}
+
+	if !found {
+		nsec := sets[len(sets)-1]
+		del := &deleteCalldata{DeleteType: qtype}
+		if del.DeleteName, err = oracle.PackName(name); err == nil {
+			if del.NSEC, err = nsec.Pack(); err == nil {
+				if del.Sig, err = nsec.PackSignature(); err == nil {
+					del.Proof, err = sets[len(sets)-2].PackRRSet()
+				}
+			}
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, exitCodeNames[ExitError], err.Error())
+			return
+		}
+		resp.Delete = del
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *proofServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	name, err := ens.ToASCII(r.URL.Query().Get("name"))
+	if err != nil || name == "" {
+		writeError(w, http.StatusBadRequest, exitCodeNames[ExitError], "Missing or invalid name parameter")
+		return
+	}
+	if s.registry == nil {
+		writeError(w, http.StatusServiceUnavailable, exitCodeNames[ExitChain], "No ENS registry configured")
+		return
+	}
+	status, err := getClaimStatus(s.conn, s.registry, name)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, exitCodeNames[ExitChain], err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status.report())
+}
+
+// limit wraps a handler with per-client rate limiting.
+func (s *proofServer) limit(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, exitCodeNames[ExitError], "Only GET is supported")
+			return
+		}
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if !s.limiter.allow(client) {
+			writeError(w, http.StatusTooManyRequests, "rate-limited", "Too many requests")
+			return
+		}
+		log.Debug("Serving request", "client", client, "path", r.URL.Path, "query", r.URL.RawQuery)
+		h(w, r)
+	}
+}
+
+func serveCommand(args []string) {
+	serveFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] serve [serve options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nServes GET /proof, /calldata and /status, each taking name and (except status) type parameters,\nand Prometheus metrics on /metrics.\n")
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nServe command options:\n")
+		serveFlags.PrintDefaults()
+	}
+	serveFlags.Parse(args)
+
+	if serveFlags.NArg() != 0 {
+		serveFlags.Usage()
+		return
+	}
+
+	s := &proofServer{
+		cache:   newProofCache(),
+		limiter: newRateLimiter(*serveRate, *serveBurst),
+	}
+
+	conn, err := dial()
+	if err != nil {
+		log.Warn("Could not connect to Ethereum node; only /proof is available", "err", err)
+	} else {
+		s.conn = conn
+		if s.oracle, err = openOracle(conn, *serveOracleAddress, ""); err != nil {
+			log.Warn("Could not create oracle; /calldata is unavailable", "err", err)
+		}
+		if s.registry, err = openRegistry(conn, *serveRegistryAddress); err != nil {
+			log.Warn("Could not instantiate registry; /status is unavailable", "err", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	timeout := func(h http.HandlerFunc) http.Handler {
+		body, _ := json.Marshal(struct {
+			Error apiError `json:"error"`
+		}{apiError{"timeout", "Request timed out"}})
+		return http.TimeoutHandler(s.limit(h), *serveTimeout, string(body))
+	}
+	mux.Handle("/proof", timeout(s.handleProof))
+	mux.Handle("/calldata", timeout(s.handleCalldata))
+	mux.Handle("/status", timeout(s.handleStatus))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:         *serveListen,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: *serveTimeout + 5*time.Second,
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Info("Shutting down", "signal", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	log.Info("Serving proofs", "addr", *serveListen)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Crit("Error serving", "err", err)
+		exit(1)
+	}
+}
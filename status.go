@@ -0,0 +1,432 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arachnid/dnsprove/ens"
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/arachnid/dnsprove/registrar"
+	"github.com/arachnid/dnsprove/root"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/miekg/dns"
+)
+
+// claimStatus is everything claim would look at for a name, gathered without
+// sending any transactions.
+type claimStatus struct {
+	name        string
+	owner       common.Address
+	parent      string
+	parentOwner common.Address
+	kind        string
+	record      string
+	txt         []string
+	txtSet      *proofs.SignedSet
+	found       bool
+	dnsErr      error
+	unsigned    bool
+	dnsOwner    common.Address
+	hasDNSOwner bool
+	version     string
+	oracleState string
+	action      string
+}
+
+// inSync reports whether claim would find nothing to do, as checkInSync
+// decides. A root with no TXT record registers the default registrar instead.
+func (s *claimStatus) inSync() bool {
+	if s.dnsErr != nil || s.kind == "root" && !s.found {
+		return false
+	}
+	return ownerInSync(s.owner, s.txtSet)
+}
+
+// syncedAction is the action for a name claim would leave alone.
+func (s *claimStatus) syncedAction() string {
+	if !s.found {
+		return "none: name is not claimed and has no TXT record (override with -force)"
+	}
+	return "none: ENS owner already matches DNS (override with -force)"
+}
+
+// oracleState describes what the oracle holds for the last set in sets.
+func oracleState(o *oracle.Oracle, sets []proofs.SignedSet, found bool) (string, error) {
+	if o.Stateless() {
+		if !found {
+			return "stateless; nothing to verify", nil
+		}
+		if _, _, err := o.Verify(sets); err != nil {
+			return fmt.Sprintf("stateless; rejects proof: %v", err), nil
+		}
+		return "stateless; accepts proof", nil
+	}
+
+	if !found {
+		// The last set is the NSEC proving the TXT record doesn't exist.
+		header := sets[len(sets)-1].Rrs[0].Header()
+		_, _, hash, err := o.Rrdata(nil, dns.TypeTXT, header.Name)
+		if err != nil {
+			return "", err
+		}
+		if hash != [20]byte{} {
+			return "holds a TXT record that no longer exists in DNS", nil
+		}
+		return "holds no TXT record", nil
+	}
+
+	status, err := o.CheckSet(sets[len(sets)-1])
+	if err != nil {
+		return "", err
+	}
+	plan, err := o.PlanProofs(sets)
+	if err != nil {
+		return status.State.String(), nil
+	}
+	return fmt.Sprintf("%s; %d of %d proofs need submitting", status.State, plan.Submissions(), len(sets)), nil
+}
+
+// registrarAction describes what claimWithRegistrar would send.
+func registrarAction(reg *registrar.DNSRegistrar, o *oracle.Oracle, s *claimStatus, sets []proofs.SignedSet) (string, error) {
+	if s.inSync() {
+		return s.syncedAction(), nil
+	}
+	if reg.Legacy() && o.Stateless() {
+		return "refuse: " + registrar.StatelessOracleError.Error(), nil
+	}
+	if s.found {
+		if !s.hasDNSOwner {
+			return "refuse: TXT record names no owner (override with -force)", nil
+		}
+		if !reg.Legacy() {
+			return fmt.Sprintf("call proveAndClaim() with %d proofs, assigning the name to %s", len(sets), s.dnsOwner.String()), nil
+		}
+		matches, err := o.RecordMatches(sets[len(sets)-1])
+		if err != nil {
+			return "", err
+		}
+		if matches {
+			return fmt.Sprintf("call claim() with the oracle's existing record, assigning the name to %s", s.dnsOwner.String()), nil
+		}
+		plan, err := o.PlanProofs(sets)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("call proveAndClaim() submitting %d proofs, assigning the name to %s", len(sets)-plan.First, s.dnsOwner.String()), nil
+	}
+
+	if !reg.Legacy() {
+		return "none: registrar does not support unclaiming names", nil
+	}
+	var steps []string
+	_, _, hash, err := o.Rrdata(nil, dns.TypeTXT, "_ens."+s.name)
+	if err != nil {
+		return "", err
+	}
+	if hash != [20]byte{} {
+		nsecsets := sets[:len(sets)-1]
+		plan, err := o.PlanProofs(nsecsets)
+		if err != nil {
+			return "", err
+		}
+		if n := len(nsecsets) - plan.First; n > 0 {
+			steps = append(steps, fmt.Sprintf("submit %d proofs", n))
+		}
+		steps = append(steps, "delete the oracle's TXT record with an NSEC proof")
+	}
+	steps = append(steps, "call claim() with an empty proof, unassigning the name")
+	return strings.Join(steps, ", then "), nil
+}
+
+// rootAction describes what claimWithRoot would send.
+func rootAction(o *oracle.Oracle, s *claimStatus, sets []proofs.SignedSet) (string, error) {
+	if s.inSync() {
+		return s.syncedAction(), nil
+	}
+	if o.Stateless() {
+		return "refuse: " + root.StatelessOracleError.Error(), nil
+	}
+	if s.found {
+		if !s.hasDNSOwner {
+			return "refuse: TXT record names no owner (override with -force)", nil
+		}
+		matches, err := o.RecordMatches(sets[len(sets)-1])
+		if err != nil {
+			return "", err
+		}
+		if matches {
+			return fmt.Sprintf("call registerTLD() with the oracle's existing record, assigning the name to %s", s.dnsOwner.String()), nil
+		}
+		plan, err := o.PlanProofs(sets)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("call proveAndRegisterTLD() submitting %d proofs, assigning the name to %s", len(sets)-plan.First, s.dnsOwner.String()), nil
+	}
+
+	dspaths, found, err := getProofPaths(dns.TypeDS, s.name)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "refuse: name not found in DNS", nil
+	}
+	dssets, err := bestPath(o, dspaths)
+	if err != nil {
+		return "refuse: " + err.Error(), nil
+	}
+
+	var steps []string
+	_, _, hash, err := o.Rrdata(nil, dns.TypeTXT, "_ens.nic."+s.name)
+	if err != nil {
+		return "", err
+	}
+	if hash != [20]byte{} {
+		if len(sets) == 0 {
+			return "refuse: " + root.UndeletableRecordError.Error(), nil
+		}
+		nsecsets := sets[:len(sets)-1]
+		plan, err := o.PlanProofs(nsecsets)
+		if err != nil {
+			return "", err
+		}
+		if n := len(nsecsets) - plan.First; n > 0 {
+			steps = append(steps, fmt.Sprintf("submit %d proofs", n))
+		}
+		steps = append(steps, "delete the oracle's TXT record with an NSEC proof")
+	}
+	plan, err := o.PlanProofs(dssets)
+	if err != nil {
+		return "", err
+	}
+	if n := len(dssets) - plan.First; n > 0 {
+		steps = append(steps, fmt.Sprintf("call proveAndRegisterDefaultTLD() submitting %d proofs", n))
+	} else {
+		steps = append(steps, "call registerTLD() with an empty proof, assigning the name to the default registrar")
+	}
+	return strings.Join(steps, ", then "), nil
+}
+
+func getClaimStatus(conn bind.ContractBackend, registry *ens.ENS, name string) (*claimStatus, error) {
+	s := &claimStatus{name: name}
+
+	var err error
+	if s.owner, err = registry.Owner(name); err != nil {
+		return nil, err
+	}
+
+	if nameparts := strings.SplitN(name, ".", 2); len(nameparts) > 1 {
+		s.parent = nameparts[1]
+	}
+	if s.parentOwner, err = registry.Owner(s.parent); err != nil {
+		return nil, err
+	}
+	if s.parentOwner == (common.Address{}) {
+		s.kind = "none (parent does not exist in ENS)"
+		s.action = "refuse: parent name does not exist in ENS"
+		return s, nil
+	}
+
+	var o *oracle.Oracle
+	reg, err := registrar.New(s.parentOwner, conn)
+	var r *root.Root
+	switch {
+	case err == nil:
+		s.kind = "DNS registrar"
+		if reg.Legacy() {
+			s.kind = "legacy DNS registrar"
+		}
+		s.record = "_ens." + name
+		o, err = reg.GetOracle()
+	case err != registrar.InterfaceNotSupportedError:
+		return nil, err
+	default:
+		if r, err = root.New(s.parentOwner, conn); err != nil {
+			s.kind = "unknown (not a DNS registrar or root)"
+			s.action = "refuse: parent is not a DNS registrar or root"
+			return s, nil
+		}
+		s.kind = "root"
+		s.record = "_ens.nic." + name
+		o, err = r.GetOracle()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.version = o.Version().String()
+
+	paths, found, err := getProofPaths(dns.TypeTXT, s.record)
+	if err != nil && !(r != nil && notDNSSECEnabled(err)) {
+		s.dnsErr = err
+		s.action = "refuse: TXT record cannot be proven"
+		return s, nil
+	}
+	s.found = found
+	s.unsigned = err != nil
+	var sets []proofs.SignedSet
+	if paths != nil {
+		if sets, err = bestPath(o, paths); err != nil {
+			s.dnsErr = err
+			s.action = "refuse: " + err.Error()
+			return s, nil
+		}
+	}
+	if found {
+		s.txtSet = &sets[len(sets)-1]
+		for _, rr := range sets[len(sets)-1].Rrs {
+			if txt, ok := rr.(*dns.TXT); ok {
+				s.txt = append(s.txt, txt.Txt...)
+			}
+		}
+		s.dnsOwner, s.hasDNSOwner = registrar.ParseOwner(sets[len(sets)-1].Rrs)
+	}
+
+	if len(sets) > 0 {
+		if s.oracleState, err = oracleState(o, sets, found); err != nil {
+			return nil, err
+		}
+	}
+
+	if reg != nil {
+		s.action, err = registrarAction(reg, o, s, sets)
+	} else {
+		s.action, err = rootAction(o, s, sets)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// statusReport is the JSON form of a claimStatus.
+type statusReport struct {
+	Owner       common.Address  `json:"owner"`
+	Parent      string          `json:"parent"`
+	ParentOwner common.Address  `json:"parentOwner"`
+	Registrar   string          `json:"registrar"`
+	Record      string          `json:"record,omitempty"`
+	TXT         []string        `json:"txt,omitempty"`
+	Found       bool            `json:"found"`
+	DNSError    string          `json:"dnsError,omitempty"`
+	DNSOwner    *common.Address `json:"dnsOwner,omitempty"`
+	Oracle      string          `json:"oracleVersion,omitempty"`
+	OracleState string          `json:"oracleState,omitempty"`
+	InSync      bool            `json:"inSync"`
+	Action      string          `json:"action"`
+}
+
+func (s *claimStatus) report() *statusReport {
+	r := &statusReport{
+		Owner:       s.owner,
+		Parent:      s.parent,
+		ParentOwner: s.parentOwner,
+		Registrar:   s.kind,
+		Record:      s.record,
+		TXT:         s.txt,
+		Found:       s.found,
+		Oracle:      s.version,
+		OracleState: s.oracleState,
+		InSync:      s.inSync(),
+		Action:      s.action,
+	}
+	if s.dnsErr != nil {
+		r.DNSError = s.dnsErr.Error()
+	}
+	if s.hasDNSOwner {
+		owner := s.dnsOwner
+		r.DNSOwner = &owner
+	}
+	return r
+}
+
+func (s *claimStatus) print() {
+	fmt.Printf("%s\n", s.name)
+	fmt.Printf("  ENS owner:    %s\n", s.owner.String())
+	fmt.Printf("  Parent:       %q owned by %s\n", s.parent, s.parentOwner.String())
+	fmt.Printf("  Registrar:    %s\n", s.kind)
+	if s.record != "" {
+		switch {
+		case s.dnsErr != nil:
+			fmt.Printf("  DNS:          %s TXT not DNSSEC-valid: %v\n", s.record, s.dnsErr)
+		case s.found:
+			fmt.Printf("  DNS:          %s TXT is DNSSEC-valid\n", s.record)
+			for _, txt := range s.txt {
+				fmt.Printf("                  %q\n", txt)
+			}
+			if s.hasDNSOwner {
+				fmt.Printf("  DNS owner:    %s\n", s.dnsOwner.String())
+			} else {
+				fmt.Printf("  DNS owner:    none\n")
+			}
+		case s.unsigned:
+			fmt.Printf("  DNS:          %s TXT is in an unsigned zone\n", s.record)
+		default:
+			fmt.Printf("  DNS:          %s TXT proven not to exist\n", s.record)
+		}
+	}
+	if s.version != "" {
+		fmt.Printf("  Oracle:       %s oracle", s.version)
+		if s.oracleState != "" {
+			fmt.Printf("; %s", s.oracleState)
+		}
+		fmt.Printf("\n")
+	}
+	if s.record != "" && s.dnsErr == nil {
+		fmt.Printf("  In sync:      %t\n", s.inSync())
+	}
+	fmt.Printf("\nClaim would: %s\n", s.action)
+	if s.found && s.hasDNSOwner && !strings.HasPrefix(s.action, "refuse:") {
+		fmt.Printf("  (sent from %s, or with -force)\n", s.dnsOwner.String())
+	}
+}
+
+func statusCommand(args []string) {
+	statusFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] status [status options] name\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nStatus command options:\n")
+		statusFlags.PrintDefaults()
+	}
+	statusFlags.Parse(args)
+	startReport("status", *statusOutput)
+
+	if statusFlags.NArg() != 1 {
+		statusFlags.Usage()
+		return
+	}
+	name, err := ens.ToASCII(statusFlags.Arg(0))
+	if err != nil {
+		report.fail(ExitError, "Invalid name", "err", err)
+	}
+	report.setTarget(0, name)
+
+	conn, err := dial()
+	if err != nil {
+		report.fail(ExitChain, "Error connecting to Ethereum node", "err", err)
+	}
+
+	registry, err := openRegistry(conn, *statusRegistryAddress)
+	if err != nil {
+		report.fail(ExitChain, "Error instantiating registry", "err", err)
+	}
+
+	status, err := getClaimStatus(conn, registry, name)
+	if err != nil {
+		report.fail(ExitChain, "Error getting claim status", "name", name, "err", err)
+	}
+	if report.JSON() {
+		report.result.Status = status.report()
+		report.result.Decisions = []string{status.action}
+	} else {
+		status.print()
+	}
+	report.finish(ExitOK, "reported", "")
+}
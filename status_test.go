@@ -0,0 +1,213 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/arachnid/dnsprove/chaintest"
+	"github.com/arachnid/dnsprove/dnstest"
+	"github.com/arachnid/dnsprove/ens"
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/arachnid/dnsprove/proofs"
+	"github.com/arachnid/dnsprove/registrar"
+	"github.com/arachnid/dnsprove/root"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/miekg/dns"
+)
+
+func TestClaimStatusInSync(t *testing.T) {
+	owner := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	txt := func(record string) *proofs.SignedSet {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &proofs.SignedSet{Rrs: []dns.RR{rr}}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		kind   string
+		owner  common.Address
+		set    *proofs.SignedSet
+		inSync bool
+		action string
+	}{
+		{"owner matches", "DNS registrar", owner, txt(testTXT), true, "none: ENS owner already matches DNS"},
+		{"owner differs", "DNS registrar", other, txt(testTXT), false, ""},
+		{"record names no owner", "DNS registrar", common.Address{}, txt(`_ens.example.com. 3600 IN TXT "hello"`), false, ""},
+		{"unclaimed with no record", "DNS registrar", common.Address{}, nil, true, "none: name is not claimed and has no TXT record"},
+		{"claimed with no record", "DNS registrar", owner, nil, false, ""},
+		{"root with no record", "root", common.Address{}, nil, false, ""},
+		{"root owner matches", "root", owner, txt(testTXT), true, "none: ENS owner already matches DNS"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &claimStatus{kind: tc.kind, owner: tc.owner, txtSet: tc.set, found: tc.set != nil}
+			if got := s.inSync(); got != tc.inSync {
+				t.Errorf("inSync() = %v, want %v", got, tc.inSync)
+			}
+			if got := ownerInSync(tc.owner, tc.set); tc.kind != "root" && got != tc.inSync {
+				t.Errorf("ownerInSync() = %v, but claim's status says %v", got, tc.inSync)
+			}
+			if tc.inSync {
+				// Both actions check for this before looking at the chain.
+				action, err := registrarAction(nil, nil, s, nil)
+				if tc.kind == "root" {
+					action, err = rootAction(nil, s, nil)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(action, tc.action) {
+					t.Errorf("action = %q, want %q", action, tc.action)
+				}
+			}
+		})
+	}
+}
+
+func TestRootStatusUnsignedTXT(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		inOracle bool
+		action   string
+	}{
+		{"oracle holds no TXT record", false, "call proveAndRegisterDefaultTLD()"},
+		{"oracle holds the old TXT record", true, "refuse: " + root.UndeletableRecordError.Error()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := dnstest.NewServer(dns.RSASHA256, "xyz.")
+			if err != nil {
+				t.Fatal(err)
+			}
+			serve(t, s)
+			if err := s.Zone("xyz.").Add(`_ens.nic.xyz. 3600 IN TXT "a=0x0000000000000000000000000000000000000001"`); err != nil {
+				t.Fatal(err)
+			}
+			signed, _, err := getProofs(dns.TypeTXT, "_ens.nic.xyz")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b := chaintest.NewBackend()
+			oracleAddr, err := b.DeployOracle(s.Anchors()[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensAddr := b.DeployENS(common.Address{})
+			if _, err := b.DeployRoot(oracleAddr, ensAddr, common.HexToAddress("0xdef")); err != nil {
+				t.Fatal(err)
+			}
+			if tc.inOracle {
+				o, err := oracle.New(oracleAddr, b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				key, _ := crypto.GenerateKey()
+				opts := bind.NewKeyedTransactor(key)
+				opts.Nonce = big.NewInt(0)
+				if _, err := o.SendProofs(opts, signed, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// nic.xyz. is now an unsigned zone, so the record is neither
+			// proven nor disproven.
+			nic, err := dnstest.NewZone("nic.xyz.", dns.RSASHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nic.SetUnsigned(true)
+			if err := s.AddZone(nic, false); err != nil {
+				t.Fatal(err)
+			}
+
+			registry, err := ens.New(ensAddr, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			status, err := getClaimStatus(b, registry, "xyz")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !status.unsigned || status.found {
+				t.Errorf("unsigned = %v, found = %v; want an unsigned record", status.unsigned, status.found)
+			}
+			if !strings.HasPrefix(status.action, tc.action) {
+				t.Errorf("action = %q, want %q", status.action, tc.action)
+			}
+		})
+	}
+}
+
+func TestClaimStatusIDN(t *testing.T) {
+	s, err := dnstest.NewServer(dns.RSASHA256, "com.", "xn--bcher-kva.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(t, s)
+	key, _ := crypto.GenerateKey()
+	opts := bind.NewKeyedTransactor(key)
+	opts.Nonce = big.NewInt(0)
+	if err := s.Zone("xn--bcher-kva.com.").Add(`_ens.xn--bcher-kva.com. 3600 IN TXT "a=` + opts.From.Hex() + `"`); err != nil {
+		t.Fatal(err)
+	}
+
+	b := chaintest.NewBackend()
+	oracleAddr, err := b.DeployOracle(s.Anchors()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensAddr := b.DeployENS(common.Address{})
+	registrarAddr, err := b.DeployRegistrar(oracleAddr, ensAddr, "com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := registrar.New(registrarAddr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := ens.New(ensAddr, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// claim and status take the name in either form.
+	name, err := ens.ToASCII("bücher.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets, _, err := getProofs(dns.TypeTXT, "_ens."+name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Claim(opts, name, sets); err != nil {
+		t.Fatal(err)
+	}
+
+	// The registrar assigns the node for the name's A-labels, as in DNS.
+	current, err := registry.Owner(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != opts.From {
+		t.Fatalf("owner of %s is %s, want %s", name, current.Hex(), opts.From.Hex())
+	}
+	if !ownerInSync(current, &sets[len(sets)-1]) {
+		t.Errorf("claim does not see %s as in sync after claiming it", name)
+	}
+	status, err := getClaimStatus(b, registry, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.owner != opts.From || !status.inSync() {
+		t.Errorf("status: owner = %s, inSync = %v; want %s, true", status.owner.Hex(), status.inSync(), opts.From.Hex())
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Trace results.
+const (
+	traceAccepted = "accepted"
+	traceRejected = "rejected"
+	traceSkipped  = "skipped"
+)
+
+// traceNode is one step taken while validating a proof chain: a DNS query, or
+// a signature, key, DS record or trust anchor that was tried. Steps taken to
+// check a candidate are its children.
+type traceNode struct {
+	Kind     string       `json:"kind"`
+	Name     string       `json:"name,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Detail   string       `json:"detail,omitempty"`
+	Result   string       `json:"result"`
+	Reason   string       `json:"reason,omitempty"`
+	Children []*traceNode `json:"children,omitempty"`
+}
+
+func (n *traceNode) accept(reason string) {
+	n.Result, n.Reason = traceAccepted, reason
+}
+
+func (n *traceNode) reject(reason string) {
+	n.Result, n.Reason = traceRejected, reason
+}
+
+func (n *traceNode) skip(reason string) {
+	n.Result, n.Reason = traceSkipped, reason
+}
+
+// enter adds node to the client's trace, if it has one, and makes it the
+// parent of subsequent steps until the returned function is called.
+func (client *Client) enter(node *traceNode) func() {
+	parent := client.trace
+	if parent == nil {
+		return func() {}
+	}
+	parent.Children = append(parent.Children, node)
+	client.trace = node
+	return func() { client.trace = parent }
+}
+
+// note adds node to the client's trace, if it has one.
+func (client *Client) note(node *traceNode) {
+	if client.trace != nil {
+		client.trace.Children = append(client.trace.Children, node)
+	}
+}
+
+func (n *traceNode) String() string {
+	parts := []string{n.Kind}
+	if n.Type != "" {
+		parts = append(parts, n.Type)
+	}
+	if n.Name != "" {
+		parts = append(parts, n.Name)
+	}
+	line := strings.Join(parts, " ")
+	if n.Detail != "" {
+		line += " [" + n.Detail + "]"
+	}
+	result := n.Result
+	if result == "" {
+		result = "incomplete"
+	}
+	line += ": " + result
+	if n.Reason != "" {
+		line += " (" + n.Reason + ")"
+	}
+	return line
+}
+
+// renderTrace writes the steps under root as an indented tree.
+func renderTrace(w io.Writer, root *traceNode) {
+	for _, child := range root.Children {
+		fmt.Fprintln(w, child)
+		renderTraceChildren(w, child, "")
+	}
+}
+
+func renderTraceChildren(w io.Writer, node *traceNode, prefix string) {
+	for i, child := range node.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, child)
+		renderTraceChildren(w, child, prefix+indent)
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/arachnid/dnsprove/oracle"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+const (
+	minWatchBackoff = time.Minute
+	maxWatchBackoff = 6 * time.Hour
+)
+
+// watchRecord is the persisted state of a single watched record.
+type watchRecord struct {
+	LastChecked   time.Time `json:"lastChecked"`
+	LastSubmitted time.Time `json:"lastSubmitted,omitempty"`
+	LastTxs       []string  `json:"lastTxs,omitempty"`
+	NextCheck     time.Time `json:"nextCheck"`
+	Failures      int       `json:"failures"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// watchState is the persisted state of the watch command, keyed by
+// "qtype qname".
+type watchState struct {
+	Records map[string]*watchRecord `json:"records"`
+}
+
+func loadWatchState(path string) (*watchState, error) {
+	state := &watchState{Records: make(map[string]*watchRecord)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.Records == nil {
+		state.Records = make(map[string]*watchRecord)
+	}
+	return state, nil
+}
+
+// save writes the state to path atomically, so an interrupted write never
+// leaves a corrupt state file behind.
+func (s *watchState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func watchKey(qtype uint16, name string) string {
+	return fmt.Sprintf("%s %s", dns.TypeToString[qtype], name)
+}
+
+// backoff returns how long to wait after the given number of consecutive
+// failures.
+func backoff(failures int) time.Duration {
+	delay := minWatchBackoff
+	for i := 1; i < failures && delay < maxWatchBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxWatchBackoff {
+		delay = maxWatchBackoff
+	}
+	return delay
+}
+
+type watcher struct {
+	o        *oracle.Oracle
+	conn     *chainClient
+	auth     *bind.TransactOpts
+	interval time.Duration
+	margin   time.Duration
+}
+
+// check brings a single record in the oracle up to date, returning any
+// transactions sent.
+func (w *watcher) check(qtype uint16, name string) ([]string, error) {
+	paths, found, err := getProofPaths(qtype, name)
+	if err != nil {
+		return nil, err
+	}
+	sets, err := bestPath(w.o, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		// We're deleting a record. If it's not already there, there's nothing to do.
+		_, _, hash, err := w.o.Rrdata(nil, qtype, name)
+		if err != nil {
+			return nil, err
+		}
+		if hash == [20]byte{} {
+			log.Debug("Record absent from DNS and oracle", "qtype", dns.TypeToString[qtype], "name", name)
+			return nil, nil
+		}
+	}
+
+	plan, err := w.o.PlanProofs(sets)
+	if err != nil {
+		return nil, err
+	}
+	plan.Log()
+
+	var sigExpires, expires time.Time
+	for _, set := range sets {
+		if t := time.Unix(int64(set.Sig.Expiration), 0); sigExpires.IsZero() || t.Before(sigExpires) {
+			sigExpires = t
+		}
+	}
+	if status := plan.Steps[len(sets)-1].Status; found && status.State.Usable() {
+		expires = time.Unix(int64(status.Inserted)+int64(sets[len(sets)-1].Rrs[0].Header().Ttl), 0)
+	}
+	recordExpiry(qtype, name, sigExpires, expires)
+
+	known := plan.First
+	if found && known == len(sets) {
+		// Refresh the record if it will go stale before our next check.
+		if time.Until(expires) > w.interval+w.margin {
+			log.Debug("Record is up to date", "qtype", dns.TypeToString[qtype], "name", name, "expires", expires)
+			return nil, nil
+		}
+		log.Info("Record nearing expiry; resubmitting", "qtype", dns.TypeToString[qtype], "name", name, "expires", expires)
+		recordsRefreshed.Inc()
+		known = len(sets) - 1
+		for known > 0 && !plan.Steps[known-1].Status.State.Usable() {
+			known--
+		}
+	}
+
+	if err := updateNonce(w.conn, w.auth); err != nil {
+		return nil, err
+	}
+	txs, err := sendProof(w.o, w.auth, qtype, name, sets, found, known)
+	txids := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		txids = append(txids, tx.Hash().String())
+	}
+	if len(txids) > 0 {
+		log.Info("Transactions sent", "qtype", dns.TypeToString[qtype], "name", name, "txids", txids)
+	}
+	return txids, err
+}
+
+func watchCommand(args []string) {
+	watchFlags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] watch [watch options] records\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nThe records file lists \"qtype qname\" pairs to keep up to date, one per line.\n")
+		fmt.Fprintf(os.Stderr, "\nGeneral options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nWatch command options:\n")
+		watchFlags.PrintDefaults()
+	}
+	watchFlags.Parse(args)
+
+	if watchFlags.NArg() != 1 {
+		watchFlags.Usage()
+		return
+	}
+
+	r, err := openBatch(watchFlags.Arg(0))
+	if err != nil {
+		log.Crit("Could not open records file", "path", watchFlags.Arg(0), "err", err)
+		exit(1)
+	}
+	entries, err := readBatch(r)
+	r.Close()
+	if err != nil {
+		log.Crit("Could not read records file", "path", watchFlags.Arg(0), "err", err)
+		exit(1)
+	}
+
+	state, err := loadWatchState(*watchStateFile)
+	if err != nil {
+		log.Crit("Could not load watch state", "path", *watchStateFile, "err", err)
+		exit(1)
+	}
+
+	conn, err := dial()
+	if err != nil {
+		log.Crit("Error connecting to Ethereum node", "err", err)
+		exit(1)
+	}
+
+	o, err := openOracle(conn, *watchOracleAddress, "")
+	if err != nil {
+		log.Crit("Error creating oracle", "err", err)
+		exit(1)
+	}
+	if o.Stateless() {
+		log.Crit("Oracle is stateless and holds no records; there is nothing to keep fresh")
+		exit(1)
+	}
+
+	auth, err := makeTransactor(conn)
+	if err != nil {
+		log.Crit("Could not create transactor", "err", err)
+		exit(1)
+	}
+
+	w := &watcher{o, conn, auth, *watchInterval, *watchMargin}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Info("Shutting down", "signal", sig)
+		cancel()
+	}()
+
+	log.Info("Watching records", "count", len(entries), "interval", *watchInterval, "state", *watchStateFile)
+	for {
+		// Transactions from the last round have most likely been mined.
+		recordGasUsed(ctx)
+		next := time.Now().Add(*watchInterval)
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				break
+			}
+
+			key := watchKey(entry.qtype, entry.name)
+			rec, ok := state.Records[key]
+			if !ok {
+				rec = &watchRecord{}
+				state.Records[key] = rec
+			}
+
+			if time.Now().Before(rec.NextCheck) {
+				if rec.NextCheck.Before(next) {
+					next = rec.NextCheck
+				}
+				continue
+			}
+
+			txids, err := w.check(entry.qtype, entry.name)
+			rec.LastChecked = time.Now()
+			if len(txids) > 0 {
+				rec.LastSubmitted = rec.LastChecked
+				rec.LastTxs = txids
+			}
+			if err != nil {
+				rec.Failures++
+				rec.LastError = err.Error()
+				rec.NextCheck = rec.LastChecked.Add(backoff(rec.Failures))
+				log.Error("Error updating record", "qtype", dns.TypeToString[entry.qtype], "name", entry.name, "failures", rec.Failures, "retry", rec.NextCheck, "err", err)
+			} else {
+				rec.Failures = 0
+				rec.LastError = ""
+				rec.NextCheck = rec.LastChecked.Add(*watchInterval)
+			}
+			if rec.NextCheck.Before(next) {
+				next = rec.NextCheck
+			}
+
+			if err := state.save(*watchStateFile); err != nil {
+				log.Error("Could not save watch state", "path", *watchStateFile, "err", err)
+			}
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		log.Debug("Sleeping until next check", "next", next)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if err := state.save(*watchStateFile); err != nil {
+		log.Crit("Could not save watch state", "path", *watchStateFile, "err", err)
+		exit(1)
+	}
+}
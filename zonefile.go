@@ -0,0 +1,175 @@
+// Copyright 2019 Nick Johnson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/miekg/dns"
+)
+
+// offlineZones holds the zone files given with -zones, if any.
+var offlineZones *zoneFiles
+
+// loadOffline loads the zone files and trust anchors given with -zones and
+// -anchors.
+func loadOffline() {
+	if *zonesFlag != "" {
+		zones, err := loadZoneFiles(strings.Split(*zonesFlag, ","))
+		if err != nil {
+			log.Crit("Could not load zone files", "err", err)
+			exit(ExitError)
+		}
+		offlineZones = zones
+	}
+
+	if *anchorsFile != "" {
+		anchors, err := readAnchors(*anchorsFile)
+		if err != nil {
+			log.Crit("Could not load trust anchors", "file", *anchorsFile, "err", err)
+			exit(ExitError)
+		}
+		trustAnchors = anchors
+	}
+}
+
+// readAnchors reads DS records from a file in zone file format.
+func readAnchors(path string) ([]*dns.DS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var anchors []*dns.DS
+	parser := dns.NewZoneParser(f, ".", path)
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		ds, ok := rr.(*dns.DS)
+		if !ok {
+			return nil, fmt.Errorf("%s: trust anchor %s is not a DS record", path, rr.Header().Name)
+		}
+		anchors = append(anchors, ds)
+	}
+	if err := parser.Err(); err != nil {
+		return nil, err
+	}
+	if len(anchors) == 0 {
+		return nil, fmt.Errorf("%s: no DS records", path)
+	}
+	return anchors, nil
+}
+
+// zoneFiles answers queries from signed zone files instead of a DNS server,
+// so proofs can be built for zones that aren't published, such as test zones.
+type zoneFiles struct {
+	zones map[string][]dns.RR
+}
+
+// loadZoneFiles parses signed zone files. Each must contain an SOA record,
+// whose owner is taken as the zone's origin.
+func loadZoneFiles(paths []string) (*zoneFiles, error) {
+	z := &zoneFiles{make(map[string][]dns.RR)}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		var rrs []dns.RR
+		origin := ""
+		parser := dns.NewZoneParser(f, "", path)
+		for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+			rr.Header().Name = strings.ToLower(rr.Header().Name)
+			if rr.Header().Rrtype == dns.TypeSOA {
+				origin = rr.Header().Name
+			}
+			rrs = append(rrs, rr)
+		}
+		err = parser.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if origin == "" {
+			return nil, fmt.Errorf("%s: no SOA record", path)
+		}
+		if _, ok := z.zones[origin]; ok {
+			return nil, fmt.Errorf("%s: zone %s is already loaded", path, origin)
+		}
+		z.zones[origin] = rrs
+	}
+	return z, nil
+}
+
+// closest returns the records of the zone authoritative for name. Unless apex
+// is set, a zone whose origin is name is passed over for its parent, as is
+// right for DS queries.
+func (z *zoneFiles) closest(name string, apex bool) (string, []dns.RR) {
+	labels := dns.SplitDomainName(name)
+	for i := 0; i <= len(labels); i++ {
+		origin := dns.Fqdn(strings.Join(labels[i:], "."))
+		if !apex && origin == name && name != "." {
+			continue
+		}
+		if rrs, ok := z.zones[origin]; ok {
+			return origin, rrs
+		}
+	}
+	return "", nil
+}
+
+// Answer returns a response as a DNSSEC-aware server would give it: the
+// records and their signatures, or the NSEC record covering the name and its
+// signatures.
+func (z *zoneFiles) Answer(qtype uint16, name string) (*dns.Msg, error) {
+	name = strings.ToLower(dns.Fqdn(name))
+	origin, rrs := z.closest(name, qtype != dns.TypeDS)
+	if origin == "" {
+		return nil, fmt.Errorf("no zone file contains %s", name)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.Response = true
+	for _, rr := range rrs {
+		if rr.Header().Name != name {
+			continue
+		}
+		if rr.Header().Rrtype == qtype {
+			msg.Answer = append(msg.Answer, rr)
+		} else if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == qtype {
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
+	if len(msg.Answer) > 0 {
+		return msg, nil
+	}
+
+	// An NSEC record at the name itself takes precedence over the one before
+	// it, whose next name it is.
+	var covering *dns.NSEC
+	for _, rr := range rrs {
+		nsec, ok := rr.(*dns.NSEC)
+		if !ok || !nsecCovers(nsec.Hdr.Name, name, nsec.NextDomain) {
+			continue
+		}
+		if covering == nil || nsec.Hdr.Name == name {
+			covering = nsec
+		}
+	}
+	if covering != nil {
+		msg.Ns = append(msg.Ns, covering)
+		for _, rr := range rrs {
+			if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == dns.TypeNSEC && sig.Hdr.Name == covering.Hdr.Name {
+				msg.Ns = append(msg.Ns, sig)
+			}
+		}
+	}
+	if len(msg.Ns) == 0 || !strings.EqualFold(msg.Ns[0].Header().Name, name) {
+		msg.Rcode = dns.RcodeNameError
+	}
+	return msg, nil
+}